@@ -1,13 +1,21 @@
 package database
 
 import (
+	"os"
+
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	_ "modernc.org/sqlite" // Pure Go SQLite driver (no CGO required)
 )
 
-// Connect establishes database connection using Pure Go SQLite
+// Connect establishes database connection. DATABASE_URL selects Postgres;
+// without it the server falls back to a local SQLite file.
 func Connect() (*gorm.DB, error) {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	}
+
 	// SQLite database file (will be created automatically)
 	dsn := "luvy.db"
 
@@ -18,3 +26,9 @@ func Connect() (*gorm.DB, error) {
 
 	return db, nil
 }
+
+// IsPostgres reports whether db talks to Postgres. The outbox, push and audit
+// tables only exist there (see migrations/).
+func IsPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
@@ -0,0 +1,493 @@
+// Package account covers the lifecycle around a user row: logging in with
+// the password, verifying the email address at signup and on every change,
+// resetting a forgotten password, and deactivating or deleting the account.
+// Links go out as single-use tokens through the outbox; sessions are tokens
+// of their own purpose.
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/internal/audit"
+	"luvy-go-backend/internal/gdpr"
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/src/models"
+)
+
+// Token purposes.
+const (
+	PurposeSignup        = "signup" // verifying the signup address; WELCOME_V1 follows
+	PurposeEmailChange   = "email_change"
+	PurposePasswordReset = "password_reset"
+	PurposeSession       = "session" // a login; used_at marks it ended
+)
+
+const (
+	VerifyTTL = 48 * time.Hour
+	ResetTTL  = time.Hour
+	// ResetCooldown is the least time between two reset mails to a user.
+	ResetCooldown = time.Minute
+	SessionTTL    = 30 * 24 * time.Hour
+)
+
+var (
+	ErrNotFound        = errors.New("user not found")
+	ErrInvalidToken    = errors.New("invalid or expired token")
+	ErrEmailTaken      = errors.New("email already in use")
+	ErrAlreadyVerified = errors.New("email is already verified")
+	ErrUnavailable     = errors.New("account deletion is not available")
+	ErrBadCredentials  = errors.New("wrong email or password")
+	ErrDeactivated     = errors.New("account is deactivated")
+)
+
+// missHash is checked when no account has the address, so a miss costs as
+// much as a wrong password and does not give away who has an account.
+var missHash, _ = bcrypt.GenerateFromPassword([]byte("no account has this address"), 10)
+
+type Service struct {
+	DB       *gorm.DB
+	Outbox   *outbox.Repo  // nil without Postgres: tokens are issued but not mailed
+	Audit    *audit.Log    // nil without Postgres
+	Privacy  *gdpr.Service // nil without Postgres: deletion is unavailable
+	BaseURL  string        // verification links point here
+	ResetURL string        // the app page that takes a reset token
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db, BaseURL: "http://localhost:8080", ResetURL: "http://localhost:3000/reset-password"}
+}
+
+// NormalizeEmail is how addresses are compared and stored.
+func NormalizeEmail(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// SendVerification issues a token for address inside tx and mails the
+// link to it. Earlier unused tokens of the same purpose stop working.
+func (s *Service) SendVerification(tx *gorm.DB, user models.User, purpose, address string) error {
+	raw, err := s.issue(tx, user.ID, purpose, address, VerifyTTL)
+	if err != nil {
+		return err
+	}
+	return s.mail(tx, address, user, email.EmailVerifyV1, map[string]string{
+		"name":       user.Name,
+		"verify_url": s.BaseURL + "/api/auth/verify-email?token=" + raw,
+	})
+}
+
+// RequestEmailChange keeps the current address until the new one is
+// verified through the link sent to it.
+func (s *Service) RequestEmailChange(userID uint, address string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.RequestEmailChangeTx(tx, userID, address)
+	})
+}
+
+// RequestEmailChangeTx is RequestEmailChange inside the caller's
+// transaction, so an ErrEmailTaken can roll back the rest of an update.
+func (s *Service) RequestEmailChangeTx(tx *gorm.DB, userID uint, address string) error {
+	address = NormalizeEmail(address)
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return notFound(err)
+	}
+	if address == NormalizeEmail(user.Email) {
+		return nil
+	}
+	if taken(tx, address, userID) {
+		return ErrEmailTaken
+	}
+	return s.SendVerification(tx, user, PurposeEmailChange, address)
+}
+
+// ResendVerification mails a fresh link for a pending email change or,
+// failing that, for a still unverified signup address.
+func (s *Service) ResendVerification(userID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return notFound(err)
+		}
+		var pending models.AccountToken
+		err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", userID, PurposeEmailChange, time.Now()).
+			Order("id DESC").First(&pending).Error
+		if err == nil {
+			return s.SendVerification(tx, user, PurposeEmailChange, pending.Email)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return ErrAlreadyVerified
+		}
+		return s.SendVerification(tx, user, PurposeSignup, user.Email)
+	})
+}
+
+// VerifyEmail redeems a verification link. A signup verification sends the
+// welcome email; an email change switches the address.
+func (s *Service) VerifyEmail(ctx context.Context, raw string) (models.User, error) {
+	var user models.User
+	changed := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		t, err := consume(tx, raw, PurposeSignup, PurposeEmailChange)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, t.UserID).Error; err != nil {
+			return notFound(err)
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"email_verified_at": now}
+		switch t.Purpose {
+		case PurposeSignup:
+			if user.Email != t.Email {
+				// the address changed since the link was sent
+				return ErrInvalidToken
+			}
+		case PurposeEmailChange:
+			if taken(tx, t.Email, user.ID) {
+				return ErrEmailTaken
+			}
+			changed = true
+			updates["email"] = t.Email
+		}
+		first := user.EmailVerifiedAt == nil
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		user.EmailVerifiedAt = &now
+		if changed {
+			user.Email = t.Email
+		}
+
+		if t.Purpose == PurposeSignup && first && s.Outbox != nil {
+			return s.Outbox.EnqueueEmailTx(tx.Statement.Context, tx.Statement.ConnPool, outbox.EmailSendPayload{
+				To:       user.Email,
+				UserID:   strconv.FormatUint(uint64(user.ID), 10),
+				Template: string(email.WelcomeV1),
+				Locale:   user.Locale,
+				Data:     map[string]string{"name": user.Name},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return user, err
+	}
+	if changed {
+		// the addresses stay out of the append-only log; erasure cannot reach it
+		s.audit(ctx, user.ID, audit.ActionAccountEmailChange, nil)
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// Login checks the password and opens a session. A deactivated account gets
+// ErrDeactivated; it comes back through Reactivate with the same
+// credentials.
+func (s *Service) Login(address, password string) (string, models.User, error) {
+	user, err := s.authenticate(address, password)
+	if err != nil {
+		return "", models.User{}, err
+	}
+	if user.DeactivatedAt != nil {
+		return "", models.User{}, ErrDeactivated
+	}
+	return s.openSession(user)
+}
+
+// Session returns the user of a session token from Login or Reactivate.
+func (s *Service) Session(raw string) (uint, error) {
+	var t models.AccountToken
+	err := s.DB.Select("user_id").
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hashToken(raw), PurposeSession, time.Now()).
+		First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrInvalidToken
+	}
+	return t.UserID, err
+}
+
+// Logout ends a session; ending one that is already over is not an error.
+func (s *Service) Logout(raw string) error {
+	return s.DB.Model(&models.AccountToken{}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL", hashToken(raw), PurposeSession).
+		Update("used_at", time.Now()).Error
+}
+
+// RequestPasswordReset mails a reset link if address belongs to an active
+// account. It reports nothing either way, so it cannot be used to find out
+// who has an account. The user row stays locked from the cooldown check to
+// the new token, so concurrent requests send one mail.
+func (s *Service) RequestPasswordReset(address string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("LOWER(email) = ? AND deactivated_at IS NULL", NormalizeEmail(address)).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var recent int64
+		err = tx.Model(&models.AccountToken{}).
+			Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, PurposePasswordReset, time.Now().Add(-ResetCooldown)).
+			Count(&recent).Error
+		if err != nil || recent > 0 {
+			return err
+		}
+
+		raw, err := s.issue(tx, user.ID, PurposePasswordReset, user.Email, ResetTTL)
+		if err != nil {
+			return err
+		}
+		return s.mail(tx, user.Email, user, email.PasswordResetV1, map[string]string{
+			"name":      user.Name,
+			"reset_url": s.ResetURL + "?token=" + raw,
+		})
+	})
+}
+
+// ResetPassword redeems a reset link. Redeeming it also proves the user
+// reads that mailbox, so the address counts as verified.
+func (s *Service) ResetPassword(ctx context.Context, raw, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return err
+	}
+	var userID uint
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		t, err := consume(tx, raw, PurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = t.UserID
+		res := tx.Model(&models.User{}).Where("id = ? AND email = ?", t.UserID, t.Email).Updates(map[string]interface{}{
+			"password":          string(hash),
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+		// whoever knew the old password is logged out
+		if err := revoke(tx, t.UserID, PurposeSession); err != nil {
+			return err
+		}
+		return revoke(tx, t.UserID, PurposePasswordReset)
+	})
+	if err != nil {
+		return err
+	}
+	s.audit(ctx, userID, audit.ActionAccountPasswordReset, nil)
+	return nil
+}
+
+// Deactivate suspends the account: the API refuses it and only security
+// notifications are sent until it is reactivated.
+func (s *Service) Deactivate(ctx context.Context, userID uint) error {
+	res := s.DB.Model(&models.User{}).Where("id = ? AND deactivated_at IS NULL", userID).Update("deactivated_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		s.audit(ctx, userID, audit.ActionAccountDeactivate, nil)
+	}
+	return nil
+}
+
+// Reactivate lifts a deactivation and withdraws a deletion that is still
+// in its grace period. A deactivated account cannot use the API, so the
+// owner proves themselves with the password; the answer is a new session.
+func (s *Service) Reactivate(ctx context.Context, address, password string) (string, models.User, error) {
+	user, err := s.authenticate(address, password)
+	if err != nil {
+		return "", models.User{}, err
+	}
+	if s.Privacy != nil {
+		if _, err := s.Privacy.CancelErasure(ctx, user.ID); err != nil && !errors.Is(err, gdpr.ErrNotFound) {
+			return "", models.User{}, err
+		}
+	}
+	res := s.DB.Model(&models.User{}).Where("id = ? AND deactivated_at IS NOT NULL", user.ID).Update("deactivated_at", nil)
+	if res.Error != nil {
+		return "", models.User{}, res.Error
+	}
+	if res.RowsAffected > 0 {
+		s.audit(ctx, user.ID, audit.ActionAccountReactivate, nil)
+	}
+	user.DeactivatedAt = nil
+	return s.openSession(user)
+}
+
+// Delete deactivates the account now and schedules its GDPR erasure; the
+// user can change their mind by reactivating within the grace period.
+func (s *Service) Delete(ctx context.Context, userID uint) (gdpr.Request, error) {
+	if s.Privacy == nil {
+		return gdpr.Request{}, ErrUnavailable
+	}
+	req, err := s.Privacy.RequestErasure(ctx, userID)
+	if err != nil {
+		return req, err
+	}
+	return req, s.Deactivate(ctx, userID)
+}
+
+// authenticate finds the account for address and checks its password.
+// Erased accounts and ones without a password never match.
+func (s *Service) authenticate(address, password string) (models.User, error) {
+	var user models.User
+	err := s.DB.Where("LOWER(email) = ? AND erased_at IS NULL", NormalizeEmail(address)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = bcrypt.CompareHashAndPassword(missHash, []byte(password))
+		return user, ErrBadCredentials
+	}
+	if err != nil {
+		return user, err
+	}
+	if user.Password == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return models.User{}, ErrBadCredentials
+	}
+	return user, nil
+}
+
+// openSession issues a session token. Other sessions of the user stay
+// open, one per device.
+func (s *Service) openSession(user models.User) (string, models.User, error) {
+	raw, err := newToken(s.DB, user.ID, PurposeSession, user.Email, SessionTTL)
+	if err != nil {
+		return "", models.User{}, err
+	}
+	user.Password = ""
+	return raw, user, nil
+}
+
+// issue stores a new token and returns it, retiring the user's earlier
+// ones of the same purpose.
+func (s *Service) issue(tx *gorm.DB, userID uint, purpose, address string, ttl time.Duration) (string, error) {
+	if err := revoke(tx, userID, purpose); err != nil {
+		return "", err
+	}
+	return newToken(tx, userID, purpose, address, ttl)
+}
+
+// newToken stores a token and returns it; only its hash is kept.
+func newToken(tx *gorm.DB, userID uint, purpose, address string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := hex.EncodeToString(b)
+	err := tx.Create(&models.AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		Email:     address,
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+	return raw, err
+}
+
+// consume marks a valid token of one of purposes used and returns it. The
+// conditional update makes a token work once even under concurrent use.
+func consume(tx *gorm.DB, raw string, purposes ...string) (models.AccountToken, error) {
+	var t models.AccountToken
+	err := tx.Where("token_hash = ? AND purpose IN ?", hashToken(raw), purposes).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return t, ErrInvalidToken
+	}
+	if err != nil {
+		return t, err
+	}
+	now := time.Now()
+	if t.UsedAt != nil || now.After(t.ExpiresAt) {
+		return t, ErrInvalidToken
+	}
+	res := tx.Model(&models.AccountToken{}).Where("id = ? AND used_at IS NULL", t.ID).Update("used_at", now)
+	if res.Error != nil {
+		return t, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return t, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// revoke retires the user's unused tokens of purpose.
+func revoke(tx *gorm.DB, userID uint, purpose string) error {
+	return tx.Model(&models.AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// mail queues a security email in tx. Unlike EnqueueEmailTx it writes no
+// in-app copy: the link is a credential and belongs in the mailbox only.
+func (s *Service) mail(tx *gorm.DB, to string, user models.User, tmpl email.Template, data map[string]string) error {
+	if s.Outbox == nil {
+		return nil
+	}
+	if err := email.Validate(tmpl, data); err != nil {
+		return err
+	}
+	return s.Outbox.EnqueueTx(tx.Statement.Context, tx.Statement.ConnPool, "notification", nil, "EMAIL_SEND", outbox.EmailSendPayload{
+		To:       to,
+		UserID:   strconv.FormatUint(uint64(user.ID), 10),
+		Template: string(tmpl),
+		Locale:   email.NormalizeLocale(user.Locale),
+		Data:     data,
+	})
+}
+
+// audit records after the commit; a failed write does not undo the change.
+func (s *Service) audit(ctx context.Context, userID uint, action string, meta map[string]any) {
+	id := strconv.FormatUint(uint64(userID), 10)
+	err := s.Audit.Record(ctx, audit.Entry{
+		ActorID:   id,
+		ActorRole: audit.RoleCustomer,
+		Action:    action,
+		Resource:  "user:" + id,
+		Metadata:  meta,
+	})
+	if err != nil {
+		log.Printf("audit %s failed: %v", action, err)
+	}
+}
+
+// taken compares case-insensitively: addresses stored before they were
+// normalised may still have capitals.
+func taken(tx *gorm.DB, address string, userID uint) bool {
+	var n int64
+	tx.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", NormalizeEmail(address), userID).Count(&n)
+	return n > 0
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,296 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"luvy-go-backend/src/models"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "account.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.AccountToken{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db)
+}
+
+func createUser(t *testing.T, s *Service, address string) models.User {
+	t.Helper()
+	u := models.User{Name: "Test", Email: address}
+	if err := s.DB.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestEmailChangeTreatsStoredCapitalsAsTaken(t *testing.T) {
+	s := newTestService(t)
+	createUser(t, s, "Ana@Example.com") // stored before addresses were normalised
+	bo := createUser(t, s, "bo@example.com")
+
+	if err := s.RequestEmailChange(bo.ID, "ana@example.com"); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("got %v, want ErrEmailTaken", err)
+	}
+	if err := s.RequestEmailChange(bo.ID, "BO@example.com"); err != nil {
+		t.Fatalf("own address in other case: %v", err)
+	}
+	var n int64
+	s.DB.Model(&models.AccountToken{}).Where("user_id = ?", bo.ID).Count(&n)
+	if n != 0 {
+		t.Fatalf("%d tokens issued for addresses that are no change or taken", n)
+	}
+}
+
+func TestVerificationTokenWorksOnce(t *testing.T) {
+	s := newTestService(t)
+	u := createUser(t, s, "ana@example.com")
+	raw, err := s.issue(s.DB, u.ID, PurposeSignup, u.Email, VerifyTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.VerifyEmail(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EmailVerifiedAt == nil {
+		t.Fatal("address not marked verified")
+	}
+	if _, err := s.VerifyEmail(context.Background(), raw); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("second use: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestExpiredTokensAreRefused(t *testing.T) {
+	s := newTestService(t)
+	u := createUser(t, s, "ana@example.com")
+	verify, err := s.issue(s.DB, u.ID, PurposeSignup, u.Email, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, err := s.issue(s.DB, u.ID, PurposePasswordReset, u.Email, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.VerifyEmail(context.Background(), verify); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired verification: got %v", err)
+	}
+	if err := s.ResetPassword(context.Background(), reset, "new-password"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired reset: got %v", err)
+	}
+}
+
+func TestTokensOnlyRedeemTheirPurpose(t *testing.T) {
+	s := newTestService(t)
+	u := createUser(t, s, "ana@example.com")
+	reset, err := s.issue(s.DB, u.ID, PurposePasswordReset, u.Email, ResetTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyEmail(context.Background(), reset); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reset token verified an address: %v", err)
+	}
+	if err := s.ResetPassword(context.Background(), reset, "new-password"); err != nil {
+		t.Fatalf("reset token refused after a wrong-purpose attempt: %v", err)
+	}
+}
+
+func TestResetTokenWorksOnceAndRevokesOthers(t *testing.T) {
+	s := newTestService(t)
+	u := createUser(t, s, "ana@example.com")
+	older, err := s.issue(s.DB, u.ID, PurposePasswordReset, u.Email, ResetTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// issuing a new link retires the older one
+	newer, err := s.issue(s.DB, u.ID, PurposePasswordReset, u.Email, ResetTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(context.Background(), older, "new-password"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("superseded link: got %v", err)
+	}
+	if err := s.ResetPassword(context.Background(), newer, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(context.Background(), newer, "other-password"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("second use: got %v", err)
+	}
+
+	var stored models.User
+	s.DB.First(&stored, u.ID)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("new-password")) != nil {
+		t.Fatal("password not changed by the valid link")
+	}
+}
+
+func TestResetRequestsRespectCooldown(t *testing.T) {
+	s := newTestService(t)
+	u := createUser(t, s, "Ana@Example.com")
+
+	for i := 0; i < 3; i++ {
+		if err := s.RequestPasswordReset("ana@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RequestPasswordReset("nobody@example.com"); err != nil {
+		t.Fatalf("unknown address must not be reported: %v", err)
+	}
+
+	var n int64
+	s.DB.Model(&models.AccountToken{}).Where("user_id = ? AND purpose = ?", u.ID, PurposePasswordReset).Count(&n)
+	if n != 1 {
+		t.Fatalf("%d reset tokens within the cooldown, want 1", n)
+	}
+}
+
+func createUserWithPassword(t *testing.T, s *Service, address, password string) models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := models.User{Name: "Test", Email: address, Password: string(hash)}
+	if err := s.DB.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestLoginOpensASessionUntilLogout(t *testing.T) {
+	s := newTestService(t)
+	u := createUserWithPassword(t, s, "ana@example.com", "correct-horse")
+	createUser(t, s, "nopass@example.com") // no password set
+
+	for _, c := range []struct{ address, password string }{
+		{"ana@example.com", "wrong-horse"},
+		{"nobody@example.com", "correct-horse"},
+		{"nopass@example.com", ""},
+	} {
+		if _, _, err := s.Login(c.address, c.password); !errors.Is(err, ErrBadCredentials) {
+			t.Fatalf("%s: got %v, want ErrBadCredentials", c.address, err)
+		}
+	}
+
+	token, got, err := s.Login(" Ana@Example.com", "correct-horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != u.ID || got.Password != "" {
+		t.Fatalf("got user %d with password %q", got.ID, got.Password)
+	}
+	other, _, err := s.Login("ana@example.com", "correct-horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.Session(token); err != nil || id != u.ID {
+		t.Fatalf("session: got %d, %v", id, err)
+	}
+
+	if err := s.Logout(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Session(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ended session: got %v", err)
+	}
+	if _, err := s.Session(other); err != nil {
+		t.Fatalf("logout ended the other device's session: %v", err)
+	}
+	if _, err := s.Session("not-a-session"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("unknown token: got %v", err)
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	s := newTestService(t)
+	u := createUserWithPassword(t, s, "ana@example.com", "correct-horse")
+	raw, err := newToken(s.DB, u.ID, PurposeSession, u.Email, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Session(raw); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got %v", err)
+	}
+	// other purposes are no session
+	reset, err := s.issue(s.DB, u.ID, PurposePasswordReset, u.Email, ResetTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Session(reset); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reset token used as a session: %v", err)
+	}
+}
+
+func TestReactivationNeedsThePassword(t *testing.T) {
+	s := newTestService(t)
+	u := createUserWithPassword(t, s, "ana@example.com", "correct-horse")
+	ctx := context.Background()
+	if err := s.Deactivate(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.Login("ana@example.com", "correct-horse"); !errors.Is(err, ErrDeactivated) {
+		t.Fatalf("login while deactivated: got %v", err)
+	}
+	if _, _, err := s.Reactivate(ctx, "ana@example.com", "wrong-horse"); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("wrong password: got %v", err)
+	}
+	var stored models.User
+	s.DB.First(&stored, u.ID)
+	if stored.DeactivatedAt == nil {
+		t.Fatal("reactivated with a wrong password")
+	}
+
+	token, got, err := s.Reactivate(ctx, "ana@example.com", "correct-horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var after models.User
+	s.DB.First(&after, u.ID)
+	if after.DeactivatedAt != nil || got.DeactivatedAt != nil {
+		t.Fatal("account still deactivated")
+	}
+	if id, err := s.Session(token); err != nil || id != u.ID {
+		t.Fatalf("session: got %d, %v", id, err)
+	}
+}
+
+func TestPasswordResetEndsSessions(t *testing.T) {
+	s := newTestService(t)
+	u := createUserWithPassword(t, s, "ana@example.com", "correct-horse")
+	session, _, err := s.Login("ana@example.com", "correct-horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, err := s.issue(s.DB, u.ID, PurposePasswordReset, u.Email, ResetTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(context.Background(), reset, "battery-staple"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Session(session); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("session survived the reset: %v", err)
+	}
+	if _, _, err := s.Login("ana@example.com", "correct-horse"); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("old password: got %v", err)
+	}
+	if _, _, err := s.Login("ana@example.com", "battery-staple"); err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+}
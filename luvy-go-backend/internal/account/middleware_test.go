@@ -0,0 +1,81 @@
+package account
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// guarded serves one route behind Authenticate and RequireActive and
+// answers with the user it saw.
+func guarded(s *Service, legacyHeader bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me", Authenticate(s, legacyHeader), RequireActive(s.DB), func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.FormatUint(uint64(c.GetUint("userID")), 10))
+	})
+	return r
+}
+
+func get(r http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthenticateWithSessions(t *testing.T) {
+	s := newTestService(t)
+	u := createUserWithPassword(t, s, "ana@example.com", "correct-horse")
+	token, _, err := s.Login("ana@example.com", "correct-horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := guarded(s, false)
+
+	w := get(r, map[string]string{"Authorization": "Bearer " + token})
+	if w.Code != http.StatusOK || w.Body.String() != strconv.FormatUint(uint64(u.ID), 10) {
+		t.Fatalf("session: %d %s", w.Code, w.Body)
+	}
+	for name, headers := range map[string]map[string]string{
+		"no session":      nil,
+		"header only":     {"X-User-ID": strconv.FormatUint(uint64(u.ID), 10)},
+		"unknown session": {"Authorization": "Bearer nope"},
+	} {
+		if w := get(r, headers); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", name, w.Code)
+		}
+	}
+
+	if err := s.Deactivate(context.Background(), u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(r, map[string]string{"Authorization": "Bearer " + token}); w.Code != http.StatusForbidden {
+		t.Fatalf("deactivated: got %d, want 403", w.Code)
+	}
+}
+
+func TestAuthenticateLegacyHeader(t *testing.T) {
+	s := newTestService(t)
+	createUser(t, s, "first@example.com")
+	bo := createUser(t, s, "bo@example.com")
+	r := guarded(s, true)
+
+	if w := get(r, map[string]string{"X-User-ID": strconv.FormatUint(uint64(bo.ID), 10)}); w.Body.String() != strconv.FormatUint(uint64(bo.ID), 10) {
+		t.Fatalf("header: %d %s", w.Code, w.Body)
+	}
+	if w := get(r, nil); w.Body.String() != "1" {
+		t.Fatalf("no header: %d %s, want the default user", w.Code, w.Body)
+	}
+	// a session still wins, and a bad one is refused rather than ignored
+	if w := get(r, map[string]string{"Authorization": "Bearer nope", "X-User-ID": "1"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad session with a header: got %d, want 401", w.Code)
+	}
+}
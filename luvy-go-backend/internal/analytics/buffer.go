@@ -0,0 +1,141 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Buffer collects events in memory and writes them to analytics_events in
+// batches from a background goroutine. Track never blocks: when the buffer
+// is full the event is dropped and counted, because losing an analytics
+// event is better than slowing down the request that produced it.
+type Buffer struct {
+	DB         *sql.DB
+	Log        *zap.Logger
+	BatchSize  int
+	FlushEvery time.Duration
+
+	ch      chan Event
+	dropped atomic.Int64
+}
+
+func NewBuffer(db *sql.DB, capacity int, log *zap.Logger) *Buffer {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &Buffer{
+		DB:         db,
+		Log:        log,
+		BatchSize:  500,
+		FlushEvery: 2 * time.Second,
+		ch:         make(chan Event, capacity),
+	}
+}
+
+// Track queues e; it reports false when e was dropped. A nil Buffer drops
+// everything, which is what the SQLite mode gets. Events failing their
+// schema are dropped with a warning.
+func (b *Buffer) Track(e Event) bool {
+	if b == nil {
+		return false
+	}
+	if err := Validate(e, false); err != nil {
+		b.Log.Warn("analytics event rejected", zap.String("event", e.Name), zap.Error(err))
+		return false
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	select {
+	case b.ch <- e:
+		return true
+	default:
+		b.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped counts events lost to a full buffer since start.
+func (b *Buffer) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Run writes batches until ctx is done, then flushes what is left.
+func (b *Buffer) Run(ctx context.Context) {
+	t := time.NewTicker(b.FlushEvery)
+	defer t.Stop()
+
+	batch := make([]Event, 0, b.BatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.write(ctx, batch); err != nil {
+			b.Log.Error("analytics write failed, batch dropped", zap.Int("events", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for len(b.ch) > 0 {
+				batch = append(batch, <-b.ch)
+				if len(batch) >= b.BatchSize {
+					flush(context.Background())
+				}
+			}
+			flush(context.Background())
+			return
+		case e := <-b.ch:
+			batch = append(batch, e)
+			if len(batch) >= b.BatchSize {
+				flush(ctx)
+			}
+		case <-t.C:
+			flush(ctx)
+		}
+	}
+}
+
+func (b *Buffer) write(ctx context.Context, events []Event) error {
+	var sb strings.Builder
+	sb.WriteString(`INSERT INTO analytics_events (user_id, merchant_id, event_name, properties, created_at) VALUES `)
+	args := make([]any, 0, len(events)*5)
+	for _, e := range events {
+		props := e.Properties
+		if props == nil {
+			props = map[string]any{}
+		}
+		p, err := json.Marshal(props)
+		if err != nil {
+			continue
+		}
+		if len(args) > 0 {
+			sb.WriteString(", ")
+		}
+		n := len(args)
+		sb.WriteString("($" + strconv.Itoa(n+1) + ", $" + strconv.Itoa(n+2) + ", $" + strconv.Itoa(n+3) +
+			", $" + strconv.Itoa(n+4) + "::jsonb, $" + strconv.Itoa(n+5) + ")")
+		args = append(args, nullable(e.UserID), nullable(e.MerchantID), e.Name, string(p), e.At.UTC())
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	_, err := b.DB.ExecContext(ctx, sb.String(), args...)
+	return err
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,141 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kind is the JSON type a property must have.
+type Kind string
+
+const (
+	KindString Kind = "string"
+	KindNumber Kind = "number"
+	KindBool   Kind = "bool"
+)
+
+// Schema describes one event name. Properties not listed are rejected, so
+// dashboards can rely on what they query. Client events may be sent to the
+// ingestion endpoint; the others are emitted by the server only.
+type Schema struct {
+	Client   bool            `json:"client"`
+	Required map[string]Kind `json:"required,omitempty"`
+	Optional map[string]Kind `json:"optional,omitempty"`
+}
+
+// Event names.
+const (
+	EventAppOpened          = "app_opened"
+	EventScreenViewed       = "screen_viewed"
+	EventSignupStarted      = "signup_started"
+	EventReceiptScanStarted = "receipt_scan_started"
+	EventReceiptScanFailed  = "receipt_scan_failed"
+	EventOfferViewed        = "offer_viewed"
+
+	EventSignupCompleted  = "signup_completed"
+	EventReceiptSubmitted = "receipt_submitted"
+	EventTokensEarned     = "tokens_earned"
+)
+
+var schemas = map[string]Schema{
+	EventAppOpened:          {Client: true, Optional: map[string]Kind{"platform": KindString, "app_version": KindString}},
+	EventScreenViewed:       {Client: true, Required: map[string]Kind{"screen": KindString}},
+	EventSignupStarted:      {Client: true, Optional: map[string]Kind{"source": KindString}},
+	EventReceiptScanStarted: {Client: true, Optional: map[string]Kind{"source": KindString}},
+	EventReceiptScanFailed:  {Client: true, Required: map[string]Kind{"reason": KindString}},
+	EventOfferViewed:        {Client: true, Required: map[string]Kind{"offer_id": KindString}},
+
+	EventSignupCompleted: {Optional: map[string]Kind{"referred": KindBool}},
+	EventReceiptSubmitted: {Required: map[string]Kind{
+		"receipt_id": KindNumber, "merchant": KindString, "category": KindString,
+		"amount": KindNumber, "tokens_earned": KindNumber,
+	}},
+	EventTokensEarned: {
+		Required: map[string]Kind{"amount": KindNumber, "type": KindString},
+		Optional: map[string]Kind{"receipt_id": KindNumber, "reference_type": KindString},
+	},
+}
+
+// Schemas returns the known event names and their schemas.
+func Schemas() map[string]Schema {
+	return schemas
+}
+
+// EventNames lists the known names in order.
+func EventNames() []string {
+	names := make([]string, 0, len(schemas))
+	for n := range schemas {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	ErrUnknownEvent = errors.New("unknown event name")
+	ErrServerOnly   = errors.New("event can only be emitted by the server")
+	ErrInvalidProps = errors.New("invalid event properties")
+)
+
+// Event is one analytics_events row. UserID and MerchantID are empty when
+// they do not apply.
+type Event struct {
+	UserID     string
+	MerchantID string
+	Name       string
+	Properties map[string]any
+	At         time.Time
+}
+
+// Validate checks e against its schema. fromClient rejects server-only
+// names.
+func Validate(e Event, fromClient bool) error {
+	s, ok := schemas[e.Name]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownEvent, e.Name)
+	}
+	if fromClient && !s.Client {
+		return fmt.Errorf("%w: %s", ErrServerOnly, e.Name)
+	}
+	for k, kind := range s.Required {
+		v, ok := e.Properties[k]
+		if !ok {
+			return fmt.Errorf("%w: %s is required", ErrInvalidProps, k)
+		}
+		if !kindOf(v, kind) {
+			return fmt.Errorf("%w: %s must be a %s", ErrInvalidProps, k, kind)
+		}
+	}
+	for k, v := range e.Properties {
+		if _, ok := s.Required[k]; ok {
+			continue
+		}
+		kind, ok := s.Optional[k]
+		if !ok {
+			return fmt.Errorf("%w: unknown property %s", ErrInvalidProps, k)
+		}
+		if !kindOf(v, kind) {
+			return fmt.Errorf("%w: %s must be a %s", ErrInvalidProps, k, kind)
+		}
+	}
+	return nil
+}
+
+func kindOf(v any, k Kind) bool {
+	switch k {
+	case KindString:
+		_, ok := v.(string)
+		return ok
+	case KindBool:
+		_, ok := v.(bool)
+		return ok
+	case KindNumber:
+		switch v.(type) {
+		case float64, float32, int, int64, int32, uint, uint64, uint32:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("invalid analytics query")
+
+// FunnelStep is how many users reached a step, in order, within the window
+// of their first step.
+type FunnelStep struct {
+	Event      string  `json:"event"`
+	Users      int     `json:"users"`
+	Conversion float64 `json:"conversion"` // of the first step, 0..1
+}
+
+// Funnel counts users who did steps[0] in [from, to) and then each following
+// step in order, all within window of the first.
+func (s *Store) Funnel(ctx context.Context, steps []string, from, to time.Time, window time.Duration) ([]FunnelStep, error) {
+	if len(steps) < 2 || len(steps) > 8 {
+		return nil, fmt.Errorf("%w: a funnel has 2 to 8 steps", ErrInvalidQuery)
+	}
+	for _, st := range steps {
+		if _, ok := schemas[st]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownEvent, st)
+		}
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: window must be positive", ErrInvalidQuery)
+	}
+
+	args := []any{from.UTC(), to.UTC(), window.Seconds()}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	// s0 is each user's first step; every later CTE keeps the first time a
+	// user did step i after their time at step i-1.
+	var ctes, counts []string
+	ctes = append(ctes, `s0 AS (
+  SELECT user_id, MIN(created_at) AS start, MIN(created_at) AS t
+  FROM analytics_events
+  WHERE event_name = `+arg(steps[0])+` AND user_id IS NOT NULL AND created_at >= $1 AND created_at < $2
+  GROUP BY user_id)`)
+	counts = append(counts, `(SELECT COUNT(*) FROM s0)`)
+	for i := 1; i < len(steps); i++ {
+		prev, cur := "s"+strconv.Itoa(i-1), "s"+strconv.Itoa(i)
+		ctes = append(ctes, cur+` AS (
+  SELECT p.user_id, p.start, MIN(e.created_at) AS t
+  FROM `+prev+` p
+  JOIN analytics_events e ON e.user_id = p.user_id AND e.event_name = `+arg(steps[i])+`
+   AND e.created_at >= p.t AND e.created_at <= p.start + make_interval(secs => $3)
+  GROUP BY p.user_id, p.start)`)
+		counts = append(counts, `(SELECT COUNT(*) FROM `+cur+`)`)
+	}
+
+	row := s.DB.QueryRowContext(ctx, `WITH `+strings.Join(ctes, ",\n")+`
+SELECT `+strings.Join(counts, ", "), args...)
+	users := make([]int, len(steps))
+	dest := make([]any, len(steps))
+	for i := range users {
+		dest[i] = &users[i]
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	out := make([]FunnelStep, len(steps))
+	for i, st := range steps {
+		out[i] = FunnelStep{Event: st, Users: users[i]}
+		if users[0] > 0 {
+			out[i].Conversion = float64(users[i]) / float64(users[0])
+		}
+	}
+	return out, nil
+}
+
+// Cohort is the users whose first cohort event fell in one period, and how
+// many of them did the return event in each period since (index 0 is the
+// cohort's own period).
+type Cohort struct {
+	Start    time.Time `json:"start"`
+	Size     int       `json:"size"`
+	Retained []int     `json:"retained"`
+}
+
+// Retention groups users by the period (day or week) of their first
+// cohortEvent in [from, to) and counts returnEvent activity over the
+// following periods.
+func (s *Store) Retention(ctx context.Context, cohortEvent, returnEvent, period string, periods int, from, to time.Time) ([]Cohort, error) {
+	var step time.Duration
+	switch period {
+	case "day":
+		step = 24 * time.Hour
+	case "week":
+		step = 7 * 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("%w: period must be day or week", ErrInvalidQuery)
+	}
+	if periods < 1 || periods > 52 {
+		return nil, fmt.Errorf("%w: periods must be 1 to 52", ErrInvalidQuery)
+	}
+	for _, n := range []string{cohortEvent, returnEvent} {
+		if _, ok := schemas[n]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownEvent, n)
+		}
+	}
+
+	out, err := s.cohorts(ctx, cohortEvent, period, periods, from, to)
+	if err != nil || len(out) == 0 {
+		return out, err
+	}
+	index := map[int64]int{}
+	for i, c := range out {
+		index[c.Start.Unix()] = i
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+WITH cohort AS (`+cohortSQL+`
+), activity AS (
+  SELECT DISTINCT co.user_id, co.c,
+         FLOOR(EXTRACT(EPOCH FROM date_trunc($1, e.created_at) - co.c) / $6)::int AS k
+  FROM cohort co
+  JOIN analytics_events e ON e.user_id = co.user_id AND e.event_name = $5 AND e.created_at >= co.c
+)
+SELECT c, k, COUNT(*) FROM activity WHERE k < $7 GROUP BY c, k
+`, period, cohortEvent, from.UTC(), to.UTC(), returnEvent, step.Seconds(), periods)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c time.Time
+		var k, users int
+		if err := rows.Scan(&c, &k, &users); err != nil {
+			return nil, err
+		}
+		if i, ok := index[c.Unix()]; ok {
+			out[i].Retained[k] = users
+		}
+	}
+	return out, rows.Err()
+}
+
+// cohortSQL assigns each user the period of their first $2 event, for users
+// whose first one lies in [$3, $4).
+const cohortSQL = `
+  SELECT user_id, date_trunc($1, MIN(created_at)) AS c
+  FROM analytics_events
+  WHERE event_name = $2 AND user_id IS NOT NULL
+  GROUP BY user_id
+  HAVING MIN(created_at) >= $3 AND MIN(created_at) < $4`
+
+func (s *Store) cohorts(ctx context.Context, cohortEvent, period string, periods int, from, to time.Time) ([]Cohort, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT c, COUNT(*) FROM (`+cohortSQL+`
+) x GROUP BY c ORDER BY c`, period, cohortEvent, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Cohort{}
+	for rows.Next() {
+		c := Cohort{Retained: make([]int, periods)}
+		if err := rows.Scan(&c.Start, &c.Size); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
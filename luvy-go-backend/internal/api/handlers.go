@@ -14,7 +14,7 @@ import (
 
 type Deps struct {
 DB        *sql.DB
-EmailSMTP *email.SMTPClient
+EmailSMTP email.Mailer
 }
 
 func AdminSummary(d Deps) http.HandlerFunc {
@@ -37,8 +37,10 @@ httpx.JSON(w, 200, out)
 
 func EmailTest(d Deps) http.HandlerFunc {
 type Req struct {
-Email    string `json:"email"`
-Template string `json:"template"` // WELCOME_V1 | POINTS_EARNED_V1
+Email    string            `json:"email"`
+Template string            `json:"template"` // WELCOME_V1 | POINTS_EARNED_V1 | BUDGET_ALERT_V1 | EXPORT_READY_V1 | EMAIL_VERIFY_V1 | PASSWORD_RESET_V1
+Locale   string            `json:"locale"`   // tr | de | en
+Data     map[string]string `json:"data"`
 }
 return func(w http.ResponseWriter, r *http.Request) {
 var req Req
@@ -49,17 +51,28 @@ httpx.Error(w, 400, "invalid json")
 return
 }
 
-sub, body := email.Render(email.Template(req.Template), map[string]string{
-"name":   "Begüm",
-"amount": "50",
-})
+spec, ok := email.Templates()[email.Template(req.Template)]
+if !ok {
+httpx.Error(w, 400, email.ErrUnknownTemplate.Error())
+return
+}
+data := req.Data
+if data == nil {
+data = spec.Sample
+}
+
+msg, err := email.Render(email.Template(req.Template), req.Locale, data)
+if err != nil {
+httpx.Error(w, 400, err.Error())
+return
+}
 
 if d.EmailSMTP == nil {
 httpx.Error(w, 500, "email client not configured")
 return
 }
 
-if err := d.EmailSMTP.SendHTML(req.Email, sub, body); err != nil {
+if err := d.EmailSMTP.Send(r.Context(), msg.Message(req.Email)); err != nil {
 httpx.Error(w, 500, err.Error())
 return
 }
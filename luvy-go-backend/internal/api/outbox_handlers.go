@@ -4,8 +4,10 @@ import (
 "context"
 "database/sql"
 "encoding/json"
+"errors"
 "net/http"
 
+"luvy-go-backend/internal/notifications/email"
 "luvy-go-backend/internal/outbox"
 "luvy-go-backend/internal/platform/httpx"
 )
@@ -19,6 +21,7 @@ func EnqueueEmail(d OutboxDeps) http.HandlerFunc {
 type Req struct {
 To       string            `json:"to"`
 Template string            `json:"template"`
+Locale   string            `json:"locale"`
 Data     map[string]string `json:"data"`
 }
 return func(w http.ResponseWriter, r *http.Request) {
@@ -34,14 +37,60 @@ httpx.Error(w, 400, "to and template are required")
 return
 }
 
+// locale verilmediyse alıcının profil tercihi
+if req.Locale == "" {
+_ = d.DB.QueryRowContext(r.Context(), `SELECT locale FROM users WHERE email=$1`, req.To).Scan(&req.Locale)
+}
+
 payload := outbox.EmailSendPayload{
 To:       req.To,
 Template: req.Template,
+Locale:   req.Locale,
 Data:     req.Data,
 }
 
 // aggregate info optional şimdilik
-if err := d.Repo.Enqueue(context.Background(), "notification", nil, "EMAIL_SEND", payload); err != nil {
+if err := d.Repo.EnqueueEmail(context.Background(), payload); err != nil {
+var missing *email.MissingDataError
+if errors.As(err, &missing) || errors.Is(err, email.ErrUnknownTemplate) {
+httpx.Error(w, 400, err.Error())
+return
+}
+httpx.Error(w, 500, err.Error())
+return
+}
+httpx.JSON(w, 201, map[string]any{"queued": true})
+}
+}
+
+func EnqueuePush(d OutboxDeps) http.HandlerFunc {
+type Req struct {
+UserID string            `json:"user_id"`
+Title  string            `json:"title"`
+Body   string            `json:"body"`
+Data   map[string]string `json:"data"`
+}
+return func(w http.ResponseWriter, r *http.Request) {
+var req Req
+dec := json.NewDecoder(r.Body)
+dec.DisallowUnknownFields()
+if err := dec.Decode(&req); err != nil {
+httpx.Error(w, 400, "invalid json")
+return
+}
+if req.UserID == "" || req.Title == "" {
+httpx.Error(w, 400, "user_id and title are required")
+return
+}
+
+payload := outbox.PushSendPayload{
+UserID: req.UserID,
+Title:  req.Title,
+Body:   req.Body,
+Data:   req.Data,
+}
+
+if err := d.Repo.Enqueue(context.Background(), "user", nil, "PUSH_SEND", payload); err != nil {
 httpx.Error(w, 500, err.Error())
 return
 }
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"luvy-go-backend/internal/pgtest"
+)
+
+func TestRecordDoesNotWaitForTheChainLock(t *testing.T) {
+	db := pgtest.Open(t)
+	l := NewLog(db)
+	ctx := context.Background()
+
+	// a chainer holds the lock for as long as this transaction is open
+	held, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Rollback()
+	if _, err := held.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, chainLock); err != nil {
+		t.Fatal(err)
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := l.Record(rctx, Entry{ActorID: "1", ActorRole: RoleAdmin, Action: ActionReceiptApprove}); err != nil {
+		t.Fatalf("Record blocked on the chain lock: %v", err)
+	}
+}
+
+func TestChainLinksRecordsInOrder(t *testing.T) {
+	db := pgtest.Open(t)
+	l := NewLog(db)
+	ctx := context.Background()
+
+	actions := []string{ActionReceiptApprove, ActionReceiptDelete, ActionAccountDeactivate}
+	for i, a := range actions {
+		e := Entry{ActorID: "7", ActorRole: RoleAdmin, Action: a, Resource: "/r", Metadata: map[string]any{"n": i, "b": "x", "a": true}}
+		if err := l.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if recs, _, err := l.List(ctx, Filter{}); err != nil || len(recs) != 0 {
+		t.Fatalf("chained before Chain ran: %v %v", recs, err)
+	}
+
+	if n, err := l.Chain(ctx); err != nil || n != len(actions) {
+		t.Fatalf("chain: %d %v", n, err)
+	}
+	if n, err := l.Chain(ctx); err != nil || n != 0 {
+		t.Fatalf("second chain moved %d %v, want nothing left", n, err)
+	}
+
+	recs, total, err := l.List(ctx, Filter{})
+	if err != nil || total != len(actions) {
+		t.Fatalf("list: %d %v", total, err)
+	}
+	// List is newest first
+	for i, r := range recs {
+		if want := actions[len(actions)-1-i]; r.Action != want {
+			t.Fatalf("record %d is %s, want %s", i, r.Action, want)
+		}
+	}
+	if recs[len(recs)-1].PrevHash != "" || recs[0].PrevHash != recs[1].Hash {
+		t.Fatal("records are not linked to their predecessors")
+	}
+
+	v, err := l.Verify(ctx)
+	if err != nil || !v.OK || v.Checked != len(actions) {
+		t.Fatalf("verify: %+v %v", v, err)
+	}
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const actionKey = "audit.action"
+
+// Tag names the action a route performs. Middleware records tagged reads;
+// writes are recorded either way, untagged ones as "<METHOD> <route>".
+func Tag(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(actionKey, action)
+		c.Next()
+	}
+}
+
+// Middleware records requests of a route group once they have been handled,
+// with the caller as actor in role. Request bodies are not recorded; they
+// may hold secrets.
+func Middleware(l *Log, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if l == nil {
+			return
+		}
+
+		action := c.GetString(actionKey)
+		if action == "" {
+			if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+				return
+			}
+			action = c.Request.Method + " " + c.FullPath()
+		}
+
+		params := map[string]string{}
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		e := Entry{
+			ActorID:   strconv.FormatUint(uint64(c.GetUint("userID")), 10),
+			ActorRole: role,
+			Action:    action,
+			Resource:  c.Request.URL.Path,
+			Metadata: map[string]any{
+				"method":     c.Request.Method,
+				"route":      c.FullPath(),
+				"params":     params,
+				"query":      c.Request.URL.RawQuery,
+				"status":     c.Writer.Status(),
+				"ip":         c.ClientIP(),
+				"user_agent": c.Request.UserAgent(),
+			},
+		}
+		if err := l.Record(c.Request.Context(), e); err != nil {
+			log.Printf("audit %s by %s failed: %v", e.Action, e.ActorID, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnavailable = errors.New("audit log is not available")
+
+// Filter narrows List. Action matches exactly, or as a prefix when it ends
+// in "." (e.g. "receipt."). Zero values do not filter.
+type Filter struct {
+	ActorID   string
+	ActorRole string
+	Action    string
+	Resource  string
+	From, To  time.Time
+	Limit     int
+	Offset    int
+}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+	// MaxExport caps a CSV export.
+	MaxExport = 100000
+)
+
+func (f Filter) where() (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if f.ActorID != "" {
+		add("actor_id = ?", f.ActorID)
+	}
+	if f.ActorRole != "" {
+		add("actor_role = ?", f.ActorRole)
+	}
+	if strings.HasSuffix(f.Action, ".") {
+		add("action LIKE ? || '%'", f.Action)
+	} else if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Resource != "" {
+		add("resource = ?", f.Resource)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= ?", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		add("created_at < ?", f.To.UTC())
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+const recordColumns = `seq, id, actor_id, actor_role, action, resource, COALESCE(metadata, '{}'::jsonb), created_at, prev_hash, hash`
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scanRecord(sc scanner, r *Record) error {
+	var meta []byte
+	if err := sc.Scan(&r.Seq, &r.ID, &r.ActorID, &r.ActorRole, &r.Action, &r.Resource, &meta, &r.CreatedAt, &r.PrevHash, &r.Hash); err != nil {
+		return err
+	}
+	r.Metadata = meta
+	return nil
+}
+
+// List returns matching records, newest first, and the total match count.
+func (l *Log) List(ctx context.Context, f Filter) ([]Record, int, error) {
+	if l == nil {
+		return nil, 0, ErrUnavailable
+	}
+	if f.Limit <= 0 || f.Limit > maxLimit {
+		f.Limit = defaultLimit
+	}
+	where, args := f.where()
+
+	var total int
+	if err := l.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_logs`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	out := []Record{}
+	err := l.each(ctx, `SELECT `+recordColumns+` FROM audit_logs`+where+
+		` ORDER BY seq DESC LIMIT `+strconv.Itoa(f.Limit)+` OFFSET `+strconv.Itoa(f.Offset), args, func(r Record) error {
+		out = append(out, r)
+		return nil
+	})
+	return out, total, err
+}
+
+func (l *Log) each(ctx context.Context, query string, args []any, fn func(Record) error) error {
+	rows, err := l.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Record
+		if err := scanRecord(rows, &r); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportCSV streams matching records, oldest first, to w.
+func (l *Log) ExportCSV(ctx context.Context, f Filter, w io.Writer) error {
+	if l == nil {
+		return ErrUnavailable
+	}
+	where, args := f.where()
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"seq", "id", "created_at", "actor_id", "actor_role", "action", "resource", "metadata", "hash"})
+	err := l.each(ctx, `SELECT `+recordColumns+` FROM audit_logs`+where+
+		` ORDER BY seq LIMIT `+strconv.Itoa(MaxExport), args, func(r Record) error {
+		var actor, resource string
+		if r.ActorID != nil {
+			actor = *r.ActorID
+		}
+		if r.Resource != nil {
+			resource = *r.Resource
+		}
+		return cw.Write([]string{
+			strconv.FormatInt(r.Seq, 10), r.ID, r.CreatedAt.UTC().Format(time.RFC3339Nano),
+			actor, r.ActorRole, r.Action, resource, string(r.Metadata), r.Hash,
+		})
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Verification is the result of walking the chain.
+type Verification struct {
+	Checked  int    `json:"checked"`
+	OK       bool   `json:"ok"`
+	BrokenAt *int64 `json:"broken_at,omitempty"` // seq of the first bad row
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify recomputes every hash in seq order and checks each row links to
+// the one before it.
+func (l *Log) Verify(ctx context.Context) (Verification, error) {
+	if l == nil {
+		return Verification{}, ErrUnavailable
+	}
+	v := Verification{OK: true}
+	prev := ""
+	errBroken := errors.New("broken")
+	err := l.each(ctx, `SELECT `+recordColumns+` FROM audit_logs ORDER BY seq`, nil, func(r Record) error {
+		v.Checked++
+		meta, err := canonicalJSON(r.Metadata)
+		if err != nil {
+			return err
+		}
+		r.Metadata = meta
+		switch {
+		case r.PrevHash != prev:
+			v.Reason = "prev_hash does not match the previous row"
+		case r.computeHash() != r.Hash:
+			v.Reason = "row content does not match its hash"
+		default:
+			prev = r.Hash
+			return nil
+		}
+		v.OK = false
+		seq := r.Seq
+		v.BrokenAt = &seq
+		return errBroken
+	})
+	if errors.Is(err, errBroken) {
+		err = nil
+	}
+	return v, err
+}
@@ -0,0 +1,353 @@
+// Package budgets tracks monthly per-category spending limits against the
+// user's receipts and alerts when spending approaches or passes them.
+package budgets
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/streaks"
+	"luvy-go-backend/internal/timebucket"
+	"luvy-go-backend/src/models"
+)
+
+// Rollover modes. Unspent carries what is left of a month into the next;
+// All also carries overspending, lowering next month's limit.
+const (
+	RolloverNone    = "none"
+	RolloverUnspent = "unspent"
+	RolloverAll     = "all"
+)
+
+// MaxRolloverMonths is how far back carried amounts are added up.
+const MaxRolloverMonths = 12
+
+// Thresholds are the percentages of a month's limit that trigger an alert.
+var Thresholds = []int{80, 100}
+
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusOver    = "over"
+)
+
+const monthLayout = "2006-01"
+
+var (
+	ErrInvalidBudget = errors.New("invalid budget")
+	ErrNotFound      = errors.New("budget not found")
+)
+
+type Service struct {
+	DB     *gorm.DB
+	Outbox *outbox.Repo // nil without Postgres: alerts are recorded but not sent
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+// CategoryTotal is one row of the per-category receipt breakdown.
+type CategoryTotal struct {
+	Category string  `json:"category"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+}
+
+// SpendByCategory is the receipt breakdown behind the categories chart and
+// budget progress, largest first. A zero range covers all receipts.
+func SpendByCategory(db *gorm.DB, userID uint, r timebucket.Range) ([]CategoryTotal, error) {
+	q := db.Model(&models.Receipt{}).
+		Select("category, SUM(amount) as total, COUNT(*) as count").
+		Where("user_id = ?", userID)
+	if !r.From.IsZero() {
+		cond, args := timebucket.RangeCond(timebucket.DialectOf(db), "created_at", r)
+		q = q.Where(cond, args...)
+	}
+
+	results := []CategoryTotal{}
+	err := q.Group("category").Order("total DESC").Scan(&results).Error
+	return results, err
+}
+
+// Progress is a budget's state for one month.
+type Progress struct {
+	Budget    models.Budget `json:"budget"`
+	Month     string        `json:"month"`
+	Carried   float64       `json:"carried"` // rolled over from earlier months
+	Limit     float64       `json:"limit"`   // Amount plus Carried
+	Spent     float64       `json:"spent"`
+	Count     int           `json:"count"`
+	Remaining float64       `json:"remaining"`
+	Percent   float64       `json:"percent"`
+	Status    string        `json:"status"`
+}
+
+func (p *Progress) compute() {
+	p.Limit = p.Budget.Amount + p.Carried
+	p.Remaining = p.Limit - p.Spent
+	switch {
+	case p.Limit > 0:
+		p.Percent = p.Spent / p.Limit * 100
+	case p.Spent > 0:
+		// overspending carried in left nothing to spend
+		p.Percent = 100
+	}
+	p.Status = StatusOK
+	if p.Percent >= 100 {
+		p.Status = StatusOver
+	} else if p.Percent >= float64(Thresholds[0]) {
+		p.Status = StatusWarning
+	}
+}
+
+// Summary is the dashboard view of a user's budgets for one month.
+type Summary struct {
+	Month      string               `json:"month"`
+	TimeZone   string               `json:"time_zone"`
+	Budgets    []Progress           `json:"budgets"`
+	Budgeted   float64              `json:"budgeted"`
+	Spent      float64              `json:"spent"`
+	Remaining  float64              `json:"remaining"`
+	Warning    int                  `json:"warning"`
+	Over       int                  `json:"over"`
+	Unbudgeted []CategoryTotal      `json:"unbudgeted"` // spending in categories without a budget
+	Alerts     []models.BudgetAlert `json:"alerts"`
+}
+
+func validRollover(s string) bool {
+	return s == RolloverNone || s == RolloverUnspent || s == RolloverAll
+}
+
+// Set creates the user's budget for category or replaces its amount and
+// rollover mode. An empty rollover keeps the current one.
+func (s *Service) Set(userID uint, category string, amount float64, rollover string) (models.Budget, error) {
+	category = strings.TrimSpace(category)
+	if category == "" || amount <= 0 || (rollover != "" && !validRollover(rollover)) {
+		return models.Budget{}, ErrInvalidBudget
+	}
+
+	b := models.Budget{UserID: userID, Category: category, Rollover: RolloverNone}
+	if err := s.DB.Where(models.Budget{UserID: userID, Category: category}).FirstOrInit(&b).Error; err != nil {
+		return b, err
+	}
+	b.Amount = amount
+	if rollover != "" {
+		b.Rollover = rollover
+	}
+	return b, s.DB.Save(&b).Error
+}
+
+// Update changes a budget's amount (when > 0) and rollover (when set).
+func (s *Service) Update(userID, id uint, amount float64, rollover string) (models.Budget, error) {
+	var b models.Budget
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&b).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return b, ErrNotFound
+		}
+		return b, err
+	}
+	if amount < 0 || (rollover != "" && !validRollover(rollover)) {
+		return b, ErrInvalidBudget
+	}
+	if amount > 0 {
+		b.Amount = amount
+	}
+	if rollover != "" {
+		b.Rollover = rollover
+	}
+	return b, s.DB.Save(&b).Error
+}
+
+func (s *Service) Delete(userID, id uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Budget{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("budget_id = ?", id).Delete(&models.BudgetAlert{}).Error
+	})
+}
+
+// Summary reports every budget of the user for the month containing at, in
+// the user's time zone.
+func (s *Service) Summary(userID uint, at time.Time) (Summary, error) {
+	var user models.User
+	if err := s.DB.Select("id, time_zone").Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return Summary{}, err
+	}
+	loc := streaks.Location(user.TimeZone)
+	month := monthOf(at.In(loc))
+
+	var list []models.Budget
+	if err := s.DB.Where("user_id = ?", userID).Order("category").Find(&list).Error; err != nil {
+		return Summary{}, err
+	}
+	spend, err := SpendByCategory(s.DB, userID, month)
+	if err != nil {
+		return Summary{}, err
+	}
+	byCategory := map[string]CategoryTotal{}
+	for _, t := range spend {
+		byCategory[t.Category] = t
+	}
+
+	sum := Summary{
+		Month:      month.From.Format(monthLayout),
+		TimeZone:   loc.String(),
+		Budgets:    []Progress{},
+		Unbudgeted: []CategoryTotal{},
+		Alerts:     []models.BudgetAlert{},
+	}
+	ids := make([]uint, 0, len(list))
+	for _, b := range list {
+		p := Progress{Budget: b, Month: sum.Month, Spent: byCategory[b.Category].Total, Count: byCategory[b.Category].Count}
+		if p.Carried, err = s.carried(s.DB, b, loc, month.From); err != nil {
+			return Summary{}, err
+		}
+		p.compute()
+		delete(byCategory, b.Category)
+		ids = append(ids, b.ID)
+
+		sum.Budgets = append(sum.Budgets, p)
+		sum.Budgeted += p.Limit
+		sum.Spent += p.Spent
+		switch p.Status {
+		case StatusWarning:
+			sum.Warning++
+		case StatusOver:
+			sum.Over++
+		}
+	}
+	sum.Remaining = sum.Budgeted - sum.Spent
+
+	for _, t := range byCategory {
+		sum.Unbudgeted = append(sum.Unbudgeted, t)
+	}
+	sort.Slice(sum.Unbudgeted, func(i, j int) bool { return sum.Unbudgeted[i].Total > sum.Unbudgeted[j].Total })
+
+	if len(ids) > 0 {
+		err = s.DB.Where("budget_id IN ? AND month = ?", ids, sum.Month).Order("created_at").Find(&sum.Alerts).Error
+	}
+	return sum, err
+}
+
+// OnReceiptApproved checks the budget of the receipt's category for the
+// receipt's month and alerts once per crossed threshold and month. When one
+// receipt crosses several thresholds, only the highest is sent.
+func (s *Service) OnReceiptApproved(tx *gorm.DB, receipt *models.Receipt) error {
+	var b models.Budget
+	err := tx.Where("user_id = ? AND category = ?", receipt.UserID, receipt.Category).Limit(1).Find(&b).Error
+	if err != nil || b.ID == 0 {
+		return err
+	}
+
+	var user models.User
+	if err := tx.Select("id, email, name, locale, time_zone").Where("id = ?", receipt.UserID).Limit(1).Find(&user).Error; err != nil {
+		return err
+	}
+	loc := streaks.Location(user.TimeZone)
+	month := monthOf(receipt.CreatedAt.In(loc))
+
+	p := Progress{Budget: b, Month: month.From.Format(monthLayout)}
+	spend, err := SpendByCategory(tx.Where("category = ?", b.Category), receipt.UserID, month)
+	if err != nil {
+		return err
+	}
+	if len(spend) > 0 {
+		p.Spent, p.Count = spend[0].Total, spend[0].Count
+	}
+	if p.Carried, err = s.carried(tx, b, loc, month.From); err != nil {
+		return err
+	}
+	p.compute()
+
+	crossed := 0
+	for _, t := range Thresholds {
+		if p.Percent < float64(t) {
+			break
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.BudgetAlert{
+			BudgetID:  b.ID,
+			Month:     p.Month,
+			Threshold: t,
+			Spent:     p.Spent,
+			Budgeted:  p.Limit,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			crossed = t
+		}
+	}
+	if crossed == 0 || s.Outbox == nil || user.Email == "" {
+		return nil
+	}
+
+	return s.Outbox.EnqueueEmailTx(tx.Statement.Context, tx.Statement.ConnPool, outbox.EmailSendPayload{
+		To:       user.Email,
+		UserID:   strconv.FormatUint(uint64(user.ID), 10),
+		Template: string(email.BudgetAlertV1),
+		Locale:   user.Locale,
+		Data: map[string]string{
+			"name":      user.Name,
+			"category":  b.Category,
+			"threshold": strconv.Itoa(crossed),
+			"spent":     money(p.Spent),
+			"limit":     money(p.Limit),
+			"month":     p.Month,
+		},
+	})
+}
+
+// carried is what earlier months roll into the month starting at start.
+// Months are replayed from the budget's creation with its current amount,
+// at most MaxRolloverMonths back.
+func (s *Service) carried(db *gorm.DB, b models.Budget, loc *time.Location, start time.Time) (float64, error) {
+	if b.Rollover != RolloverUnspent && b.Rollover != RolloverAll {
+		return 0, nil
+	}
+	from := timebucket.Month.Truncate(b.CreatedAt.In(loc))
+	if earliest := timebucket.Month.Add(start, -MaxRolloverMonths); from.Before(earliest) {
+		from = earliest
+	}
+	if !from.Before(start) {
+		return 0, nil
+	}
+
+	q := db.Model(&models.Receipt{}).Where("user_id = ? AND category = ?", b.UserID, b.Category)
+	months, err := timebucket.Series(q, timebucket.Month, "created_at", "amount", loc, timebucket.Range{From: from, To: start})
+	if err != nil {
+		return 0, err
+	}
+
+	carry := 0.0
+	for _, m := range months {
+		carry = b.Amount + carry - m.Total
+		if b.Rollover == RolloverUnspent && carry < 0 {
+			carry = 0
+		}
+	}
+	return carry, nil
+}
+
+func monthOf(t time.Time) timebucket.Range {
+	start := timebucket.Month.Truncate(t)
+	return timebucket.Range{From: start, To: timebucket.Month.Add(start, 1)}
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
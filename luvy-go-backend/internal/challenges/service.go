@@ -0,0 +1,229 @@
+package challenges
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/src/models"
+)
+
+// Goal types a challenge can be measured by.
+const (
+	GoalCount     = "count"
+	GoalAmount    = "amount"
+	GoalMerchants = "distinct_merchants"
+)
+
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusExpired   = "expired"
+)
+
+var (
+	ErrInvalidChallenge = errors.New("invalid challenge")
+	ErrDuplicateCode    = errors.New("challenge code already exists")
+)
+
+type Service struct {
+	DB *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+// Validate checks an admin-defined challenge before it is stored.
+func Validate(ch *models.Challenge) error {
+	switch {
+	case ch.Code == "" || ch.Name == "":
+		return ErrInvalidChallenge
+	case ch.GoalType != GoalCount && ch.GoalType != GoalAmount && ch.GoalType != GoalMerchants:
+		return ErrInvalidChallenge
+	case ch.GoalValue <= 0:
+		return ErrInvalidChallenge
+	case !ch.EndsAt.After(ch.StartsAt):
+		return ErrInvalidChallenge
+	case ch.RewardLuvy < 0:
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+func (s *Service) Create(ch *models.Challenge) error {
+	if err := Validate(ch); err != nil {
+		return err
+	}
+	res := s.DB.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "code"}}, DoNothing: true}).Create(ch)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDuplicateCode
+	}
+	return nil
+}
+
+// OnReceiptApproved recomputes progress for every running challenge the
+// receipt counts towards and pays out the ones it completes.
+func (s *Service) OnReceiptApproved(tx *gorm.DB, receipt *models.Receipt) ([]models.Challenge, error) {
+	at := receipt.CreatedAt
+	q := tx.Where("is_active = ? AND starts_at <= ? AND ends_at >= ?", true, at, at).
+		Where("category = '' OR category = ?", receipt.Category).
+		Where("merchant = '' OR merchant = ?", receipt.Merchant)
+
+	var running []models.Challenge
+	if err := q.Find(&running).Error; err != nil {
+		return nil, err
+	}
+
+	completed := []models.Challenge{}
+	for _, ch := range running {
+		done, err := s.advance(tx, receipt.UserID, ch)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			completed = append(completed, ch)
+		}
+	}
+	return completed, nil
+}
+
+func (s *Service) advance(tx *gorm.DB, userID uint, ch models.Challenge) (bool, error) {
+	uc := models.UserChallenge{UserID: userID, ChallengeID: ch.ID, Target: ch.GoalValue, Status: StatusActive}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&uc).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Where("user_id = ? AND challenge_id = ?", userID, ch.ID).First(&uc).Error; err != nil {
+		return false, err
+	}
+	if uc.Status != StatusActive {
+		return false, nil
+	}
+
+	progress, err := measure(tx, userID, ch)
+	if err != nil {
+		return false, err
+	}
+
+	updates := map[string]interface{}{"progress": progress}
+	done := progress >= uc.Target
+	if done {
+		now := time.Now()
+		updates["status"] = StatusCompleted
+		updates["completed_at"] = &now
+	}
+
+	// Guard on status so a challenge is only ever paid out once.
+	res := tx.Model(&models.UserChallenge{}).
+		Where("id = ? AND status = ?", uc.ID, StatusActive).
+		Updates(updates)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if !done || res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if ch.RewardLuvy > 0 {
+		if err := ledger.Credit(tx, &models.Transaction{
+			UserID:        userID,
+			Type:          ledger.TypeBonus,
+			Amount:        ch.RewardLuvy,
+			Description:   "Challenge completed: " + ch.Name,
+			ReferenceType: "challenge",
+			ReferenceID:   ch.ID,
+		}); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// measure derives progress from the receipts themselves, so replays and
+// deleted receipts never leave a counter out of sync.
+func measure(tx *gorm.DB, userID uint, ch models.Challenge) (float64, error) {
+	q := tx.Model(&models.Receipt{}).
+		Where("user_id = ? AND status = ?", userID, "completed").
+		Where("created_at >= ? AND created_at <= ?", ch.StartsAt, ch.EndsAt)
+	if ch.Category != "" {
+		q = q.Where("category = ?", ch.Category)
+	}
+	if ch.Merchant != "" {
+		q = q.Where("merchant = ?", ch.Merchant)
+	}
+
+	switch ch.GoalType {
+	case GoalAmount:
+		var total float64
+		err := q.Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+		return total, err
+	case GoalMerchants:
+		var n int64
+		err := q.Distinct("merchant").Count(&n).Error
+		return float64(n), err
+	default:
+		var n int64
+		err := q.Count(&n).Error
+		return float64(n), err
+	}
+}
+
+type Progress struct {
+	models.Challenge
+	Progress    float64    `json:"progress"`
+	Target      float64    `json:"target"`
+	Percent     float64    `json:"percent"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// ForUser lists current challenges plus any the user already joined, with
+// their progress.
+func (s *Service) ForUser(userID uint) ([]Progress, error) {
+	now := time.Now()
+
+	var list []models.Challenge
+	err := s.DB.Where("is_active = ? AND ends_at >= ?", true, now).
+		Or("id IN (?)", s.DB.Model(&models.UserChallenge{}).Select("challenge_id").Where("user_id = ?", userID)).
+		Order("ends_at ASC").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var mine []models.UserChallenge
+	if err := s.DB.Where("user_id = ?", userID).Find(&mine).Error; err != nil {
+		return nil, err
+	}
+	byChallenge := make(map[uint]models.UserChallenge, len(mine))
+	for _, uc := range mine {
+		byChallenge[uc.ChallengeID] = uc
+	}
+
+	out := make([]Progress, 0, len(list))
+	for _, ch := range list {
+		p := Progress{Challenge: ch, Target: ch.GoalValue, Status: StatusActive}
+		if uc, ok := byChallenge[ch.ID]; ok {
+			p.Progress = uc.Progress
+			p.Status = uc.Status
+			p.CompletedAt = uc.CompletedAt
+		}
+		if p.Status == StatusActive && now.After(ch.EndsAt) {
+			p.Status = StatusExpired
+		}
+		if p.Target > 0 {
+			p.Percent = p.Progress / p.Target * 100
+			if p.Percent > 100 {
+				p.Percent = 100
+			}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
@@ -0,0 +1,163 @@
+package challenges
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"luvy-go-backend/src/models"
+)
+
+// newTestService enforces foreign keys, so a reward credit pointing at a
+// receipt that does not exist fails here as it would on Postgres.
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "challenges.db") + "?_foreign_keys=on"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Receipt{}, &models.Transaction{}, &models.Challenge{}, &models.UserChallenge{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db)
+}
+
+func createChallenge(t *testing.T, s *Service, ch models.Challenge) models.Challenge {
+	t.Helper()
+	if ch.Code == "" {
+		ch.Code = ch.Name
+	}
+	ch.IsActive = true
+	if err := s.Create(&ch); err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+// approve stores a receipt made at at and runs the challenge hook on it, as
+// SubmitReceipt does.
+func approve(t *testing.T, s *Service, userID uint, merchant, category string, amount float64, at time.Time) []models.Challenge {
+	t.Helper()
+	var completed []models.Challenge
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		r := models.Receipt{UserID: userID, Merchant: merchant, Category: category, Amount: amount, Status: "completed", CreatedAt: at}
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+		var err error
+		completed, err = s.OnReceiptApproved(tx, &r)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return completed
+}
+
+func progress(t *testing.T, s *Service, userID, challengeID uint) models.UserChallenge {
+	t.Helper()
+	var uc models.UserChallenge
+	if err := s.DB.Where("user_id = ? AND challenge_id = ?", userID, challengeID).First(&uc).Error; err != nil {
+		t.Fatal(err)
+	}
+	return uc
+}
+
+func TestProgressCountsOnlyMatchingReceiptsInsideTheWindow(t *testing.T) {
+	s := newTestService(t)
+	now := time.Now()
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+
+	cases := []struct {
+		goal string
+		want float64
+	}{
+		{GoalCount, 2},
+		{GoalAmount, 30},
+		{GoalMerchants, 1},
+	}
+	for i, c := range cases {
+		userID := uint(i + 1)
+		ch := createChallenge(t, s, models.Challenge{Name: c.goal, GoalType: c.goal, GoalValue: 100, Category: "food", StartsAt: start, EndsAt: end})
+
+		approve(t, s, userID, "Migros", "food", 1000, start.Add(-time.Minute)) // before the window
+		approve(t, s, userID, "Zara", "fashion", 1000, now)                    // other category
+		approve(t, s, userID, "Migros", "food", 10, now)
+		approve(t, s, userID, "Migros", "food", 20, now)
+
+		if uc := progress(t, s, userID, ch.ID); uc.Progress != c.want || uc.Status != StatusActive {
+			t.Errorf("%s: progress %v status %s, want %v active", c.goal, uc.Progress, uc.Status, c.want)
+		}
+	}
+}
+
+func TestReceiptOutsideTheWindowDoesNotJoin(t *testing.T) {
+	s := newTestService(t)
+	now := time.Now()
+	ch := createChallenge(t, s, models.Challenge{Name: "later", GoalType: GoalCount, GoalValue: 1, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)})
+
+	if done := approve(t, s, 1, "Migros", "food", 10, now); len(done) != 0 {
+		t.Fatalf("completed %v before it started", done)
+	}
+	var n int64
+	s.DB.Model(&models.UserChallenge{}).Where("challenge_id = ?", ch.ID).Count(&n)
+	if n != 0 {
+		t.Fatal("user joined a challenge that has not started")
+	}
+}
+
+func TestCompletedChallengePaysOutOnce(t *testing.T) {
+	s := newTestService(t)
+	user := models.User{Name: "Test", Email: "ana@example.com"}
+	if err := s.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ch := createChallenge(t, s, models.Challenge{Name: "two", GoalType: GoalCount, GoalValue: 2, RewardLuvy: 10, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)})
+
+	if done := approve(t, s, user.ID, "Migros", "food", 10, now); len(done) != 0 {
+		t.Fatalf("completed after one of two receipts: %v", done)
+	}
+	if done := approve(t, s, user.ID, "Migros", "food", 10, now); len(done) != 1 || done[0].ID != ch.ID {
+		t.Fatalf("second receipt completed %v", done)
+	}
+	if done := approve(t, s, user.ID, "Migros", "food", 10, now); len(done) != 0 {
+		t.Fatalf("completed again: %v", done)
+	}
+
+	uc := progress(t, s, user.ID, ch.ID)
+	if uc.Status != StatusCompleted || uc.CompletedAt == nil || uc.Progress != 2 {
+		t.Fatalf("user challenge %+v", uc)
+	}
+	var rewards []models.Transaction
+	s.DB.Where("user_id = ? AND reference_type = ?", user.ID, "challenge").Find(&rewards)
+	if len(rewards) != 1 || rewards[0].Amount != 10 || rewards[0].ReceiptID != nil {
+		t.Fatalf("reward entries %+v, want one of 10 without a receipt", rewards)
+	}
+	if err := s.DB.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.LuvyBalance != 10 {
+		t.Fatalf("balance %v, want 10", user.LuvyBalance)
+	}
+}
+
+func TestCreateRejectsDuplicateCode(t *testing.T) {
+	s := newTestService(t)
+	now := time.Now()
+	ch := models.Challenge{Code: "RAMADAN", Name: "Ramadan", GoalType: GoalCount, GoalValue: 3, StartsAt: now, EndsAt: now.Add(time.Hour)}
+	if err := s.Create(&ch); err != nil {
+		t.Fatal(err)
+	}
+	again := ch
+	again.ID = 0
+	if err := s.Create(&again); !errors.Is(err, ErrDuplicateCode) {
+		t.Fatalf("got %v, want ErrDuplicateCode", err)
+	}
+}
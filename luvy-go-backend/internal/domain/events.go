@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/src/models"
+)
+
+// Domain event types. They are facts about committed writes; Reactions and
+// other consumers turn them into notifications.
+const (
+	ReceiptSubmitted = "RECEIPT_SUBMITTED"
+	TokensEarned     = "TOKENS_EARNED"
+	UserRegistered   = "USER_REGISTERED"
+)
+
+// Aggregate types. Events of one aggregate are delivered in order.
+const (
+	AggregateReceipt = "receipt"
+	AggregateUser    = "user"
+)
+
+type ReceiptSubmittedPayload struct {
+	ReceiptID    uint      `json:"receipt_id"`
+	UserID       uint      `json:"user_id"`
+	Merchant     string    `json:"merchant"`
+	Category     string    `json:"category"`
+	Amount       float64   `json:"amount"`
+	TokensEarned float64   `json:"tokens_earned"`
+	Status       string    `json:"status"`
+	ReceiptDate  time.Time `json:"receipt_date"`
+}
+
+// TokensEarnedPayload is one ledger credit. ReceiptID is 0 for bonuses.
+type TokensEarnedPayload struct {
+	TransactionID uint    `json:"transaction_id"`
+	UserID        uint    `json:"user_id"`
+	ReceiptID     uint    `json:"receipt_id,omitempty"`
+	Type          string  `json:"type"`
+	Amount        float64 `json:"amount"`
+	ReferenceType string  `json:"reference_type,omitempty"`
+	ReferenceID   uint    `json:"reference_id,omitempty"`
+}
+
+type UserRegisteredPayload struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Locale string `json:"locale"`
+}
+
+// Recorder is a gorm plugin that writes a domain event to outbox_events for
+// every receipt, ledger credit and user created through gorm, one by one or
+// as a slice. The insert goes through the creating statement's connection,
+// so inside db.Transaction it commits or rolls back with the row. gorm may
+// skip its implicit transaction for a bare Create, which is why these rows
+// are only created inside one.
+type Recorder struct {
+	Repo *outbox.Repo
+}
+
+func (Recorder) Name() string { return "domain:recorder" }
+
+func (r Recorder) Initialize(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:create").Register("domain:record", r.record)
+}
+
+func (r Recorder) record(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	rows := []reflect.Value{rv}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		rows = rows[:0]
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, rv.Index(i))
+		}
+	}
+	for _, row := range rows {
+		if row.Kind() != reflect.Pointer {
+			if !row.CanAddr() {
+				continue
+			}
+			row = row.Addr()
+		}
+		if err := r.enqueue(db, row.Interface()); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+// enqueue writes the event for one created row, if it has one.
+func (r Recorder) enqueue(db *gorm.DB, row any) error {
+	var (
+		id      uint
+		aggType string
+		aggID   uint
+		evType  string
+		payload any
+	)
+	switch v := row.(type) {
+	case *models.Receipt:
+		id = v.ID
+		aggType, aggID, evType = AggregateReceipt, v.ID, ReceiptSubmitted
+		payload = ReceiptSubmittedPayload{
+			ReceiptID:    v.ID,
+			UserID:       v.UserID,
+			Merchant:     v.Merchant,
+			Category:     v.Category,
+			Amount:       v.Amount,
+			TokensEarned: v.TokensEarned,
+			Status:       v.Status,
+			ReceiptDate:  v.ReceiptDate,
+		}
+	case *models.Transaction:
+		// only credits are earnings; other entry types have no event yet
+		if v.Type != ledger.TypeEarn && v.Type != ledger.TypeBonus {
+			return nil
+		}
+		id = v.ID
+		var receiptID uint
+		if v.ReceiptID != nil {
+			receiptID = *v.ReceiptID
+		}
+		// ordered per user, so a balance replayed from events adds up
+		aggType, aggID, evType = AggregateUser, v.UserID, TokensEarned
+		payload = TokensEarnedPayload{
+			TransactionID: v.ID,
+			UserID:        v.UserID,
+			ReceiptID:     receiptID,
+			Type:          v.Type,
+			Amount:        v.Amount,
+			ReferenceType: v.ReferenceType,
+			ReferenceID:   v.ReferenceID,
+		}
+	case *models.User:
+		id = v.ID
+		aggType, aggID, evType = AggregateUser, v.ID, UserRegistered
+		payload = UserRegisteredPayload{UserID: v.ID, Email: v.Email, Name: v.Name, Locale: v.Locale}
+	default:
+		return nil
+	}
+	if id == 0 {
+		// not inserted, e.g. skipped by ON CONFLICT DO NOTHING
+		return nil
+	}
+
+	return r.Repo.EnqueueTx(db.Statement.Context, db.Statement.ConnPool, aggType, outbox.AggregateID(aggID), evType, payload)
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/pgtest"
+	"luvy-go-backend/src/models"
+)
+
+func TestRecorderEventsPerRow(t *testing.T) {
+	sqlDB := pgtest.Open(t, &models.User{}, &models.Receipt{}, &models.Transaction{})
+	db := pgtest.Gorm(t, sqlDB)
+	if err := db.Use(Recorder{Repo: outbox.NewRepo(sqlDB)}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&[]models.Receipt{{UserID: 7, Merchant: "A"}, {UserID: 7, Merchant: "B"}}).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Receipt").Create([]*models.Transaction{
+			{UserID: 7, Type: ledger.TypeEarn, Amount: 5},
+			{UserID: 7, Type: ledger.TypeBonus, Amount: 2},
+			{UserID: 7, Type: "adjustment", Amount: -1},
+		}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	rows, err := sqlDB.Query(`SELECT event_type, COUNT(*) FROM outbox_events GROUP BY event_type`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var typ string
+		var n int
+		if err := rows.Scan(&typ, &n); err != nil {
+			t.Fatal(err)
+		}
+		counts[typ] = n
+	}
+	if counts[ReceiptSubmitted] != 2 {
+		t.Errorf("%d %s events for a slice of 2 receipts", counts[ReceiptSubmitted], ReceiptSubmitted)
+	}
+	if counts[TokensEarned] != 2 {
+		t.Errorf("%d %s events, want one per earn and bonus entry only", counts[TokensEarned], TokensEarned)
+	}
+}
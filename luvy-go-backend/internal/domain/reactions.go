@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"luvy-go-backend/internal/analytics"
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/webhooks"
+)
+
+// WebhookPublisher fans an event out to partner webhook subscriptions.
+type WebhookPublisher interface {
+	Publish(ctx context.Context, q outbox.DBTX, eventType, merchant string, data any) error
+}
+
+// Reactions handles the domain events inside the outbox worker. Analytics
+// events are tracked only once a reaction has succeeded, so a retried
+// reaction is not counted twice.
+type Reactions struct {
+	Repo      *outbox.Repo
+	Webhooks  WebhookPublisher  // optional
+	Analytics *analytics.Buffer // optional
+}
+
+// Register adds the domain event types to reg.
+func (x Reactions) Register(reg *outbox.Registry, repo *outbox.Repo) {
+	x.Repo = repo
+	policy := outbox.Policy{Timeout: 10 * time.Second}
+	outbox.Register(reg, ReceiptSubmitted, policy, x.receiptSubmitted)
+	outbox.Register(reg, TokensEarned, policy, x.tokensEarned)
+	outbox.Register(reg, UserRegistered, policy, x.userRegistered)
+}
+
+// receiptSubmitted tells partners about approved receipts from their store.
+// The user is not part of the webhook data.
+func (x Reactions) receiptSubmitted(ctx context.Context, _ outbox.Event, p ReceiptSubmittedPayload) error {
+	if x.Webhooks != nil && p.Status == "completed" {
+		err := x.inTx(ctx, func(tx *sql.Tx) error {
+			return x.Webhooks.Publish(ctx, tx, webhooks.EventReceiptApproved, p.Merchant, map[string]any{
+				"receipt_id":    p.ReceiptID,
+				"merchant":      p.Merchant,
+				"category":      p.Category,
+				"amount":        p.Amount,
+				"tokens_earned": p.TokensEarned,
+				"receipt_date":  p.ReceiptDate,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	x.Analytics.Track(analytics.Event{
+		UserID:     strconv.FormatUint(uint64(p.UserID), 10),
+		MerchantID: x.merchantID(ctx, p.Merchant),
+		Name:       analytics.EventReceiptSubmitted,
+		Properties: map[string]any{
+			"receipt_id":    p.ReceiptID,
+			"merchant":      p.Merchant,
+			"category":      p.Category,
+			"amount":        p.Amount,
+			"tokens_earned": p.TokensEarned,
+		},
+	})
+	return nil
+}
+
+// userRegistered only counts the signup. The welcome email waits until the
+// address is verified (see package account).
+func (x Reactions) userRegistered(_ context.Context, _ outbox.Event, p UserRegisteredPayload) error {
+	x.Analytics.Track(analytics.Event{UserID: strconv.FormatUint(uint64(p.UserID), 10), Name: analytics.EventSignupCompleted})
+	return nil
+}
+
+// tokensEarned mails receipt earnings. Bonuses arrive together with a
+// receipt's earnings and are left to the in-app gamification feedback.
+func (x Reactions) tokensEarned(ctx context.Context, _ outbox.Event, p TokensEarnedPayload) error {
+	if p.Type != ledger.TypeEarn || p.ReceiptID == 0 {
+		x.trackTokens(p)
+		return nil
+	}
+
+	var to, name, locale string
+	err := x.Repo.DB.QueryRowContext(ctx, `SELECT email, name, locale FROM users WHERE id=$1`, p.UserID).Scan(&to, &name, &locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return outbox.Skip("user no longer exists")
+	}
+	if err != nil {
+		return err
+	}
+
+	amount := math.Round(p.Amount*100) / 100
+	err = x.enqueueEmail(ctx, outbox.EmailSendPayload{
+		To:       to,
+		UserID:   strconv.FormatUint(uint64(p.UserID), 10),
+		Template: string(email.PointsEarnedV1),
+		Locale:   locale,
+		Data:     map[string]string{"name": name, "amount": strconv.FormatFloat(amount, 'f', -1, 64)},
+	})
+	if err != nil {
+		return err
+	}
+	x.trackTokens(p)
+	return nil
+}
+
+func (x Reactions) trackTokens(p TokensEarnedPayload) {
+	props := map[string]any{"amount": p.Amount, "type": p.Type}
+	if p.ReceiptID != 0 {
+		props["receipt_id"] = p.ReceiptID
+	}
+	if p.ReferenceType != "" {
+		props["reference_type"] = p.ReferenceType
+	}
+	x.Analytics.Track(analytics.Event{UserID: strconv.FormatUint(uint64(p.UserID), 10), Name: analytics.EventTokensEarned, Properties: props})
+}
+
+// merchantID resolves a receipt's merchant name; unknown merchants have none.
+func (x Reactions) merchantID(ctx context.Context, name string) string {
+	if x.Analytics == nil {
+		return ""
+	}
+	var id int64
+	if err := x.Repo.DB.QueryRowContext(ctx, `SELECT id FROM merchants WHERE lower(name) = lower($1) LIMIT 1`, name).Scan(&id); err != nil {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// enqueueEmail writes the email and its in-app copy together, so a retry of
+// the domain event never sends one without the other.
+func (x Reactions) enqueueEmail(ctx context.Context, p outbox.EmailSendPayload) error {
+	return x.inTx(ctx, func(tx *sql.Tx) error {
+		err := x.Repo.EnqueueEmailTx(ctx, tx, p)
+		var missing *email.MissingDataError
+		if errors.As(err, &missing) {
+			return outbox.Permanent(err)
+		}
+		return err
+	})
+}
+
+// inTx runs fn in a transaction, so everything a reaction enqueues appears at
+// once or, after a failure, not at all and is redone on retry.
+func (x Reactions) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := x.Repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
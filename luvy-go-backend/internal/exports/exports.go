@@ -0,0 +1,319 @@
+// Package exports lets users download their receipts and LUVY history:
+// CSV or JSON for a date range, and a monthly PDF statement. Files are
+// generated by the outbox worker and handed out by link or as an email
+// attachment.
+package exports
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/streaks"
+	"luvy-go-backend/internal/timebucket"
+)
+
+const (
+	KindReceipts     = "receipts"
+	KindTransactions = "transactions"
+	KindStatement    = "statement"
+	// KindPersonalData answers a GDPR access request. It is created by
+	// CreatePersonalData only, never through Create.
+	KindPersonalData = "personal_data"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatPDF  = "pdf"
+)
+
+const (
+	DeliveryLink  = "link"
+	DeliveryEmail = "email"
+)
+
+const (
+	StatusPending = "PENDING"
+	StatusReady   = "READY"
+	StatusFailed  = "FAILED"
+	StatusExpired = "EXPIRED"
+)
+
+// Retention is how long a generated file can be downloaded.
+const Retention = 7 * 24 * time.Hour
+
+// MaxRange caps the date range of a receipts or transactions export.
+const MaxRange = 366 * 24 * time.Hour
+
+var (
+	ErrNotFound = errors.New("export not found")
+	ErrInvalid  = errors.New("invalid export request")
+	ErrNotReady = errors.New("export is not ready")
+)
+
+type Export struct {
+	ID          string     `json:"id"`
+	UserID      uint       `json:"user_id"`
+	Kind        string     `json:"kind"`
+	Format      string     `json:"format"`
+	Delivery    string     `json:"delivery"`
+	From        time.Time  `json:"from"`
+	To          time.Time  `json:"to"`
+	Status      string     `json:"status"`
+	Filename    *string    `json:"filename"`
+	ContentType *string    `json:"content_type"`
+	Size        *int       `json:"size_bytes"`
+	Error       *string    `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// Request is what a user asks for. From and To are dates (YYYY-MM-DD, To
+// inclusive) for receipts and transactions; Month (YYYY-MM) picks the
+// statement month and defaults to the last full one. Dates are read in the
+// user's time zone.
+type Request struct {
+	Kind     string `json:"kind"`
+	Format   string `json:"format"`   // csv or json; statements are always pdf
+	Delivery string `json:"delivery"` // link (default) or email
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Month    string `json:"month"`
+}
+
+// resolve validates req and turns it into a range in loc.
+func (req *Request) resolve(loc *time.Location, now time.Time) (timebucket.Range, error) {
+	if req.Delivery == "" {
+		req.Delivery = DeliveryLink
+	}
+	if req.Delivery != DeliveryLink && req.Delivery != DeliveryEmail {
+		return timebucket.Range{}, fmt.Errorf("%w: delivery must be link or email", ErrInvalid)
+	}
+
+	switch req.Kind {
+	case KindStatement:
+		if req.Format != "" && req.Format != FormatPDF {
+			return timebucket.Range{}, fmt.Errorf("%w: statements are pdf", ErrInvalid)
+		}
+		req.Format = FormatPDF
+		start := timebucket.Month.Add(timebucket.Month.Truncate(now.In(loc)), -1)
+		if req.Month != "" {
+			t, err := time.ParseInLocation("2006-01", req.Month, loc)
+			if err != nil {
+				return timebucket.Range{}, fmt.Errorf("%w: month must be YYYY-MM", ErrInvalid)
+			}
+			start = t
+		}
+		if start.After(now) {
+			return timebucket.Range{}, fmt.Errorf("%w: month is in the future", ErrInvalid)
+		}
+		return timebucket.Range{From: start, To: timebucket.Month.Add(start, 1)}, nil
+
+	case KindReceipts, KindTransactions:
+		if req.Format == "" {
+			req.Format = FormatCSV
+		}
+		if req.Format != FormatCSV && req.Format != FormatJSON {
+			return timebucket.Range{}, fmt.Errorf("%w: format must be csv or json", ErrInvalid)
+		}
+		from, err := time.ParseInLocation("2006-01-02", req.From, loc)
+		if err != nil {
+			return timebucket.Range{}, fmt.Errorf("%w: from must be YYYY-MM-DD", ErrInvalid)
+		}
+		to, err := time.ParseInLocation("2006-01-02", req.To, loc)
+		if err != nil {
+			return timebucket.Range{}, fmt.Errorf("%w: to must be YYYY-MM-DD", ErrInvalid)
+		}
+		r := timebucket.Range{From: from, To: to.AddDate(0, 0, 1)}
+		if r.Validate() != nil || r.To.Sub(r.From) > MaxRange {
+			return r, fmt.Errorf("%w: from must not be after to, at most a year apart", ErrInvalid)
+		}
+		return r, nil
+	}
+	return timebucket.Range{}, fmt.Errorf("%w: kind must be receipts, transactions or statement", ErrInvalid)
+}
+
+type Repo struct{ DB *sql.DB }
+
+func NewRepo(db *sql.DB) *Repo { return &Repo{DB: db} }
+
+const exportColumns = `id, user_id, kind, format, delivery, range_from, range_to, status, filename, content_type, size_bytes, error, created_at, completed_at, expires_at`
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scanExport(sc scanner, e *Export) error {
+	return sc.Scan(&e.ID, &e.UserID, &e.Kind, &e.Format, &e.Delivery, &e.From, &e.To, &e.Status,
+		&e.Filename, &e.ContentType, &e.Size, &e.Error, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt)
+}
+
+// Create stores the request and queues its generation in one transaction.
+func (r *Repo) Create(ctx context.Context, ob *outbox.Repo, userID uint, req Request) (Export, error) {
+	var tz string
+	err := r.DB.QueryRowContext(ctx, `SELECT time_zone FROM users WHERE id=$1`, userID).Scan(&tz)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Export{}, ErrNotFound
+	}
+	if err != nil {
+		return Export{}, err
+	}
+	rng, err := req.resolve(streaks.Location(tz), time.Now())
+	if err != nil {
+		return Export{}, err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Export{}, err
+	}
+	defer tx.Rollback()
+
+	var e Export
+	err = scanExport(tx.QueryRowContext(ctx, `
+INSERT INTO data_exports (user_id, kind, format, delivery, range_from, range_to)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING `+exportColumns, userID, req.Kind, req.Format, req.Delivery, rng.From, rng.To), &e)
+	if err != nil {
+		return e, err
+	}
+	if err := ob.EnqueueTx(ctx, tx, AggregateExport, &e.ID, GenerateEventType, GeneratePayload{ExportID: e.ID}); err != nil {
+		return e, err
+	}
+	return e, tx.Commit()
+}
+
+// CreatePersonalData queues a JSON dump of everything stored about the user
+// inside tx and returns the export's id. The range covers the whole account.
+func (r *Repo) CreatePersonalData(ctx context.Context, tx *sql.Tx, ob *outbox.Repo, userID uint) (string, error) {
+	var id string
+	err := tx.QueryRowContext(ctx, `
+INSERT INTO data_exports (user_id, kind, format, delivery, range_from, range_to)
+SELECT id, $2, $3, $4, created_at, NOW() FROM users WHERE id=$1
+RETURNING id
+`, userID, KindPersonalData, FormatJSON, DeliveryLink).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := ob.EnqueueTx(ctx, tx, AggregateExport, &id, GenerateEventType, GeneratePayload{ExportID: id}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// List returns the user's exports, newest first.
+func (r *Repo) List(ctx context.Context, userID uint) ([]Export, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+exportColumns+` FROM data_exports WHERE user_id=$1 ORDER BY created_at DESC LIMIT 50`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Export{}
+	for rows.Next() {
+		var e Export
+		if err := scanExport(rows, &e); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// Get returns one of the user's exports.
+func (r *Repo) Get(ctx context.Context, userID uint, id string) (Export, error) {
+	var e Export
+	err := scanExport(r.DB.QueryRowContext(ctx, `SELECT `+exportColumns+` FROM data_exports WHERE id::text=$1 AND user_id=$2`, id, userID), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, ErrNotFound
+	}
+	return e, err
+}
+
+// File returns a ready export's content, found by the owner and id or, for
+// emailed links, by token alone (userID 0).
+func (r *Repo) File(ctx context.Context, userID uint, idOrToken string) (Export, []byte, error) {
+	q := `SELECT ` + exportColumns + `, file FROM data_exports WHERE id::text=$1 AND user_id=$2`
+	args := []any{idOrToken, userID}
+	if userID == 0 {
+		q, args = `SELECT `+exportColumns+`, file FROM data_exports WHERE token=$1`, []any{idOrToken}
+	}
+
+	var e Export
+	var file []byte
+	err := r.DB.QueryRowContext(ctx, q, args...).Scan(&e.ID, &e.UserID, &e.Kind, &e.Format, &e.Delivery, &e.From, &e.To, &e.Status,
+		&e.Filename, &e.ContentType, &e.Size, &e.Error, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt, &file)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, nil, ErrNotFound
+	}
+	if err != nil {
+		return e, nil, err
+	}
+	if e.Status != StatusReady || (e.ExpiresAt != nil && time.Now().After(*e.ExpiresAt)) {
+		return e, nil, ErrNotReady
+	}
+	return e, file, nil
+}
+
+// Attachment implements outbox.AttachmentSource with the export id.
+func (r *Repo) Attachment(ctx context.Context, id string) (email.Attachment, error) {
+	var a email.Attachment
+	var filename, contentType sql.NullString
+	err := r.DB.QueryRowContext(ctx, `
+SELECT filename, content_type, file FROM data_exports
+WHERE id::text=$1 AND status=$2 AND expires_at > NOW()
+`, id, StatusReady).Scan(&filename, &contentType, &a.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, outbox.ErrAttachmentGone
+	}
+	a.Filename, a.ContentType = filename.String, contentType.String
+	return a, err
+}
+
+// PurgeExpired drops the files of exports past expires_at, keeping the rows
+// as EXPIRED.
+func (r *Repo) PurgeExpired(ctx context.Context) (int64, error) {
+	res, err := r.DB.ExecContext(ctx, `
+UPDATE data_exports SET status=$1, file=NULL, token=NULL
+WHERE status=$2 AND expires_at < NOW()
+`, StatusExpired, StatusReady)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repo) markReady(ctx context.Context, tx *sql.Tx, id string, f file, token string) error {
+	_, err := tx.ExecContext(ctx, `
+UPDATE data_exports
+SET status=$2, filename=$3, content_type=$4, size_bytes=$5, file=$6, token=$7, error=NULL,
+    completed_at=NOW(), expires_at=NOW() + make_interval(secs => $8)
+WHERE id::text=$1
+`, id, StatusReady, f.Name, f.ContentType, len(f.Data), f.Data, token, Retention.Seconds())
+	return err
+}
+
+func (r *Repo) markFailed(ctx context.Context, id, reason string) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE data_exports SET status=$2, error=$3, completed_at=NOW() WHERE id::text=$1`, id, StatusFailed, reason)
+	return err
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func userIDString(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
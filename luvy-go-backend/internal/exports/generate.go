@@ -0,0 +1,350 @@
+package exports
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"luvy-go-backend/internal/gdpr"
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/streaks"
+)
+
+// GenerateEventType is the outbox event that builds one export.
+const GenerateEventType = "EXPORT_GENERATE"
+
+const AggregateExport = "data_export"
+
+// MaxAttachment is the largest file sent as an attachment; bigger ones are
+// delivered as a link even when email was asked for.
+const MaxAttachment = 10 << 20
+
+type GeneratePayload struct {
+	ExportID string `json:"export_id"`
+}
+
+func (p GeneratePayload) Validate() error {
+	if p.ExportID == "" {
+		return errors.New("export_id is required")
+	}
+	return nil
+}
+
+// Generator builds exports in the outbox worker and tells the user when
+// they are ready.
+type Generator struct {
+	Repo    *Repo
+	Outbox  *outbox.Repo
+	BaseURL string // download links point here
+}
+
+func NewGenerator(repo *Repo, baseURL string) *Generator {
+	return &Generator{Repo: repo, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Register adds EXPORT_GENERATE to reg; repo is where the ready email goes.
+func (g *Generator) Register(reg *outbox.Registry, repo *outbox.Repo) {
+	g.Outbox = repo
+	outbox.Register(reg, GenerateEventType, outbox.Policy{Timeout: 2 * time.Minute}, g.generate)
+}
+
+// DownloadURL is the link sent for an export's token.
+func (g *Generator) DownloadURL(token string) string {
+	return g.BaseURL + "/api/exports/download/" + token
+}
+
+type file struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// owner is who the export is for, with what the files and email need.
+type owner struct {
+	ID     uint
+	Email  string
+	Name   string
+	Locale string
+	Loc    *time.Location
+}
+
+func (g *Generator) generate(ctx context.Context, _ outbox.Event, p GeneratePayload) error {
+	var e Export
+	err := scanExport(g.Repo.DB.QueryRowContext(ctx, `SELECT `+exportColumns+` FROM data_exports WHERE id::text=$1`, p.ExportID), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return outbox.Skip("export no longer exists")
+	}
+	if err != nil {
+		return err
+	}
+	if e.Status != StatusPending {
+		return outbox.Skip("export is " + e.Status)
+	}
+
+	u := owner{ID: e.UserID}
+	var tz string
+	err = g.Repo.DB.QueryRowContext(ctx, `SELECT email, name, locale, time_zone FROM users WHERE id=$1`, e.UserID).Scan(&u.Email, &u.Name, &u.Locale, &tz)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = g.Repo.markFailed(ctx, e.ID, "user no longer exists")
+		return outbox.Skip("user no longer exists")
+	}
+	if err != nil {
+		return err
+	}
+	u.Locale = email.NormalizeLocale(u.Locale)
+	u.Loc = streaks.Location(tz)
+
+	var f file
+	switch e.Kind {
+	case KindReceipts:
+		f, err = g.receipts(ctx, u, e)
+	case KindTransactions:
+		f, err = g.transactions(ctx, u, e)
+	case KindStatement:
+		f, err = g.statement(ctx, u, e)
+	case KindPersonalData:
+		f, err = g.personalData(ctx, u, e)
+	default:
+		_ = g.Repo.markFailed(ctx, e.ID, "unknown kind "+e.Kind)
+		return outbox.Permanent(fmt.Errorf("unknown export kind %q", e.Kind))
+	}
+	if err != nil {
+		return err
+	}
+
+	return g.deliver(ctx, u, e, f)
+}
+
+// deliver stores the file and queues the ready email in one transaction, so
+// a retry after a failure regenerates and notifies exactly once.
+func (g *Generator) deliver(ctx context.Context, u owner, e Export, f file) error {
+	tx, err := g.Repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	token := newToken()
+	if err := g.Repo.markReady(ctx, tx, e.ID, f, token); err != nil {
+		return err
+	}
+
+	msg := outbox.EmailSendPayload{
+		To:       u.Email,
+		UserID:   userIDString(u.ID),
+		Template: string(email.ExportReadyV1),
+		Locale:   u.Locale,
+		Data: map[string]string{
+			"name":       u.Name,
+			"filename":   f.Name,
+			"expires_at": time.Now().Add(Retention).In(u.Loc).Format("2006-01-02"),
+		},
+	}
+	if e.Delivery == DeliveryEmail && len(f.Data) <= MaxAttachment {
+		msg.Attachments = []string{e.ID}
+	} else {
+		msg.Data["download_url"] = g.DownloadURL(token)
+	}
+	if err := g.Outbox.EnqueueEmailTx(ctx, tx, msg); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// personalData is the GDPR access dump: every row stored about the user.
+func (g *Generator) personalData(ctx context.Context, u owner, e Export) (file, error) {
+	d, err := gdpr.Collect(ctx, g.Repo.DB, u.ID)
+	if err != nil {
+		return file{}, err
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return file{}, err
+	}
+	return file{
+		Name:        "luvy-personal-data-" + e.To.In(u.Loc).Format("20060102") + ".json",
+		ContentType: "application/json",
+		Data:        b,
+	}, nil
+}
+
+type receiptRow struct {
+	ID           uint      `json:"id"`
+	Merchant     string    `json:"merchant"`
+	Category     string    `json:"category"`
+	Amount       float64   `json:"amount"`
+	TokensEarned float64   `json:"tokens_earned"`
+	Status       string    `json:"status"`
+	ReceiptDate  time.Time `json:"receipt_date"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type transactionRow struct {
+	ID            uint      `json:"id"`
+	Type          string    `json:"type"`
+	Amount        float64   `json:"amount"`
+	Description   string    `json:"description"`
+	ReceiptID     *uint     `json:"receipt_id"`
+	ReferenceType string    `json:"reference_type,omitempty"`
+	ReferenceID   *uint     `json:"reference_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (g *Generator) receipts(ctx context.Context, u owner, e Export) (file, error) {
+	rows, err := g.Repo.DB.QueryContext(ctx, `
+SELECT id, merchant, category, amount, tokens_earned, status, receipt_date, created_at
+FROM receipts WHERE user_id=$1 AND created_at >= $2 AND created_at < $3
+ORDER BY created_at, id
+`, u.ID, e.From, e.To)
+	if err != nil {
+		return file{}, err
+	}
+	defer rows.Close()
+
+	list := []receiptRow{}
+	for rows.Next() {
+		var r receiptRow
+		if err := rows.Scan(&r.ID, &r.Merchant, &r.Category, &r.Amount, &r.TokensEarned, &r.Status, &r.ReceiptDate, &r.CreatedAt); err != nil {
+			return file{}, err
+		}
+		r.CreatedAt = r.CreatedAt.In(u.Loc)
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		return file{}, err
+	}
+
+	if e.Format == FormatJSON {
+		return jsonFile(u, e, list)
+	}
+	records := [][]string{{"id", "created_at", "receipt_date", "merchant", "category", "amount", "tokens_earned", "status"}}
+	for _, r := range list {
+		records = append(records, []string{
+			strconv.FormatUint(uint64(r.ID), 10),
+			r.CreatedAt.Format(time.RFC3339),
+			r.ReceiptDate.Format("2006-01-02"),
+			r.Merchant,
+			r.Category,
+			amount(r.Amount),
+			amount(r.TokensEarned),
+			r.Status,
+		})
+	}
+	return csvFile(u, e, records)
+}
+
+func (g *Generator) transactions(ctx context.Context, u owner, e Export) (file, error) {
+	list, err := g.transactionRows(ctx, u, e.From, e.To)
+	if err != nil {
+		return file{}, err
+	}
+
+	if e.Format == FormatJSON {
+		return jsonFile(u, e, list)
+	}
+	records := [][]string{{"id", "created_at", "type", "amount", "description", "receipt_id", "reference_type", "reference_id"}}
+	for _, t := range list {
+		records = append(records, []string{
+			strconv.FormatUint(uint64(t.ID), 10),
+			t.CreatedAt.Format(time.RFC3339),
+			t.Type,
+			amount(t.Amount),
+			t.Description,
+			optionalID(t.ReceiptID),
+			t.ReferenceType,
+			optionalID(t.ReferenceID),
+		})
+	}
+	return csvFile(u, e, records)
+}
+
+func (g *Generator) transactionRows(ctx context.Context, u owner, from, to time.Time) ([]transactionRow, error) {
+	rows, err := g.Repo.DB.QueryContext(ctx, `
+SELECT id, type, amount, COALESCE(description, ''), NULLIF(receipt_id, 0), COALESCE(reference_type, ''), NULLIF(reference_id, 0), created_at
+FROM transactions WHERE user_id=$1 AND created_at >= $2 AND created_at < $3
+ORDER BY created_at, id
+`, u.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []transactionRow{}
+	for rows.Next() {
+		var t transactionRow
+		if err := rows.Scan(&t.ID, &t.Type, &t.Amount, &t.Description, &t.ReceiptID, &t.ReferenceType, &t.ReferenceID, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.CreatedAt = t.CreatedAt.In(u.Loc)
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func filename(e Export, ext string) string {
+	return fmt.Sprintf("luvy-%s-%s-%s.%s", e.Kind, e.From.Format("20060102"), e.To.AddDate(0, 0, -1).Format("20060102"), ext)
+}
+
+func jsonFile(u owner, e Export, items any) (file, error) {
+	b, err := json.MarshalIndent(map[string]any{
+		"user_id":      u.ID,
+		"kind":         e.Kind,
+		"from":         e.From.In(u.Loc),
+		"to":           e.To.In(u.Loc),
+		"time_zone":    u.Loc.String(),
+		"generated_at": time.Now().In(u.Loc),
+		e.Kind:         items,
+	}, "", "  ")
+	if err != nil {
+		return file{}, err
+	}
+	return file{Name: filename(e, "json"), ContentType: "application/json", Data: b}, nil
+}
+
+func csvFile(_ owner, e Export, records [][]string) (file, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, rec := range records {
+		for i, v := range rec {
+			rec[i] = csvSafe(v)
+		}
+		if err := w.Write(rec); err != nil {
+			return file{}, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return file{}, err
+	}
+	return file{Name: filename(e, "csv"), ContentType: "text/csv; charset=utf-8", Data: buf.Bytes()}, nil
+}
+
+// csvSafe keeps spreadsheets from running user text such as a merchant
+// named "=HYPERLINK(...)" as a formula.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return "'" + v
+		}
+	}
+	return v
+}
+
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
@@ -0,0 +1,128 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// pdf is a minimal PDF 1.4 writer for statements: A4 pages of single-line
+// text in the standard Helvetica and Courier fonts, which every reader has,
+// so nothing needs embedding. Text is WinAnsi encoded.
+type pdf struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+const (
+	pageWidth  = 595.0 // A4 in points
+	pageHeight = 842.0
+	margin     = 50.0
+)
+
+// Fonts, as named in each page's resources.
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+	fontMono    = "F3" // fixed width, for right-aligned numbers
+)
+
+func newPDF() *pdf {
+	p := &pdf{}
+	p.newPage()
+	return p
+}
+
+func (p *pdf) newPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pageHeight - margin
+}
+
+// line moves down by height, starting a new page when the bottom margin
+// would be crossed.
+func (p *pdf) line(height float64) {
+	p.y -= height
+	if p.y < margin {
+		p.newPage()
+		p.y -= height
+	}
+}
+
+// text writes s at x on the current line.
+func (p *pdf) text(x float64, font string, size float64, s string) {
+	fmt.Fprintf(p.pages[len(p.pages)-1], "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, p.y, pdfString(s))
+}
+
+// right writes s in the mono font so that it ends at x.
+func (p *pdf) right(x float64, size float64, s string) {
+	w := float64(len([]rune(s))) * size * 0.6 // Courier glyphs are 600/1000 em
+	p.text(x-w, fontMono, size, s)
+}
+
+// rule draws a horizontal line under the current line.
+func (p *pdf) rule() {
+	fmt.Fprintf(p.pages[len(p.pages)-1], "0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, p.y-4, pageWidth-margin, p.y-4)
+}
+
+// Bytes assembles the document.
+func (p *pdf) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1 catalog, 2 page tree, 3-5 fonts, then a page and its content per page
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, content := range p.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 7+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding has;
+// Turkish letters it lacks are written without their marks.
+var winAnsi = map[rune]string{
+	'€': "\x80", '–': "\x96", '—': "\x97", '‘': "\x91", '’': "\x92", '“': "\x93", '”': "\x94", '•': "\x95", '…': "\x85",
+	'ğ': "g", 'Ğ': "G", 'ş': "s", 'Ş': "S", 'ı': "i", 'İ': "I",
+}
+
+// pdfString encodes s for a literal string in a content stream.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		case winAnsi[r] != "":
+			b.WriteString(winAnsi[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package exports
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// statementLabels are the statement's words per supported email locale.
+var statementLabels = map[string]map[string]string{
+	"en": {
+		"title": "LUVY statement", "period": "Period", "time_zone": "Time zone",
+		"opening": "Opening balance", "earned": "LUVY earned", "closing": "Closing balance",
+		"categories": "Spending by category", "category": "Category", "receipts": "Receipts", "spent": "Spent",
+		"entries": "LUVY history", "date": "Date", "type": "Type", "description": "Description", "amount": "Amount",
+		"none": "Nothing in this period.", "generated": "Generated",
+	},
+	"de": {
+		"title": "LUVY-Kontoauszug", "period": "Zeitraum", "time_zone": "Zeitzone",
+		"opening": "Anfangssaldo", "earned": "Gesammelte LUVY", "closing": "Endsaldo",
+		"categories": "Ausgaben nach Kategorie", "category": "Kategorie", "receipts": "Belege", "spent": "Ausgegeben",
+		"entries": "LUVY-Verlauf", "date": "Datum", "type": "Art", "description": "Beschreibung", "amount": "Betrag",
+		"none": "Keine Einträge in diesem Zeitraum.", "generated": "Erstellt",
+	},
+	"tr": {
+		"title": "LUVY hesap özeti", "period": "Dönem", "time_zone": "Saat dilimi",
+		"opening": "Açılış bakiyesi", "earned": "Kazanılan LUVY", "closing": "Kapanış bakiyesi",
+		"categories": "Kategoriye göre harcama", "category": "Kategori", "receipts": "Fiş", "spent": "Harcanan",
+		"entries": "LUVY geçmişi", "date": "Tarih", "type": "Tür", "description": "Açıklama", "amount": "Tutar",
+		"none": "Bu dönemde kayıt yok.", "generated": "Oluşturulma",
+	},
+}
+
+type categoryTotal struct {
+	Category string
+	Receipts int
+	Spent    float64
+	Earned   float64
+}
+
+// statement is the monthly PDF: opening and closing LUVY balance from the
+// ledger, receipt spending per category and every ledger entry of the month.
+func (g *Generator) statement(ctx context.Context, u owner, e Export) (file, error) {
+	var opening float64
+	err := g.Repo.DB.QueryRowContext(ctx, `
+SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id=$1 AND created_at < $2
+`, u.ID, e.From).Scan(&opening)
+	if err != nil {
+		return file{}, err
+	}
+	entries, err := g.transactionRows(ctx, u, e.From, e.To)
+	if err != nil {
+		return file{}, err
+	}
+
+	rows, err := g.Repo.DB.QueryContext(ctx, `
+SELECT category, COUNT(*), SUM(amount), SUM(tokens_earned)
+FROM receipts WHERE user_id=$1 AND created_at >= $2 AND created_at < $3
+GROUP BY category ORDER BY SUM(amount) DESC
+`, u.ID, e.From, e.To)
+	if err != nil {
+		return file{}, err
+	}
+	defer rows.Close()
+	var categories []categoryTotal
+	for rows.Next() {
+		var c categoryTotal
+		if err := rows.Scan(&c.Category, &c.Receipts, &c.Spent, &c.Earned); err != nil {
+			return file{}, err
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return file{}, err
+	}
+
+	earned := 0.0
+	for _, t := range entries {
+		earned += t.Amount
+	}
+
+	l := statementLabels[u.Locale]
+	if l == nil {
+		l = statementLabels["en"]
+	}
+	from, to := e.From.In(u.Loc), e.To.In(u.Loc).AddDate(0, 0, -1)
+	right := pageWidth - margin
+
+	p := newPDF()
+	p.text(margin, fontBold, 18, l["title"])
+	p.line(24)
+	p.text(margin, fontRegular, 10, fmt.Sprintf("%s: %s – %s", l["period"], from.Format("2006-01-02"), to.Format("2006-01-02")))
+	p.line(14)
+	p.text(margin, fontRegular, 10, u.Name+" <"+u.Email+">")
+	p.line(14)
+	p.text(margin, fontRegular, 10, l["time_zone"]+": "+u.Loc.String())
+
+	p.line(30)
+	for _, row := range []struct {
+		label string
+		value float64
+		font  string
+	}{
+		{l["opening"], opening, fontRegular},
+		{l["earned"], earned, fontRegular},
+		{l["closing"], opening + earned, fontBold},
+	} {
+		p.text(margin, row.font, 11, row.label)
+		p.right(right, 11, amount(row.value))
+		p.line(16)
+	}
+
+	p.line(16)
+	p.text(margin, fontBold, 13, l["categories"])
+	p.line(18)
+	p.text(margin, fontBold, 9, l["category"])
+	p.text(300, fontBold, 9, l["receipts"])
+	p.text(380, fontBold, 9, l["spent"])
+	p.text(470, fontBold, 9, "LUVY")
+	p.rule()
+	p.line(16)
+	if len(categories) == 0 {
+		p.text(margin, fontRegular, 9, l["none"])
+		p.line(14)
+	}
+	for _, c := range categories {
+		p.text(margin, fontRegular, 9, truncate(c.Category, 45))
+		p.right(340, 9, fmt.Sprint(c.Receipts))
+		p.right(440, 9, amount(c.Spent))
+		p.right(right, 9, amount(c.Earned))
+		p.line(14)
+	}
+
+	p.line(16)
+	p.text(margin, fontBold, 13, l["entries"])
+	p.line(18)
+	p.text(margin, fontBold, 9, l["date"])
+	p.text(130, fontBold, 9, l["type"])
+	p.text(200, fontBold, 9, l["description"])
+	p.text(480, fontBold, 9, l["amount"])
+	p.rule()
+	p.line(16)
+	if len(entries) == 0 {
+		p.text(margin, fontRegular, 9, l["none"])
+		p.line(14)
+	}
+	for _, t := range entries {
+		p.text(margin, fontRegular, 9, t.CreatedAt.Format("2006-01-02 15:04"))
+		p.text(130, fontRegular, 9, t.Type)
+		p.text(200, fontRegular, 9, truncate(t.Description, 50))
+		p.right(right, 9, amount(t.Amount))
+		p.line(14)
+	}
+
+	p.line(20)
+	p.text(margin, fontRegular, 8, l["generated"]+": "+time.Now().In(u.Loc).Format("2006-01-02 15:04"))
+
+	return file{
+		Name:        "luvy-statement-" + from.Format("2006-01") + ".pdf",
+		ContentType: "application/pdf",
+		Data:        p.Bytes(),
+	}, nil
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,37 @@
+package gamification
+
+import "luvy-go-backend/src/models"
+
+// DefaultAchievements is the built-in achievement catalog seeded at startup.
+var DefaultAchievements = []models.Achievement{
+	{
+		Code:             "FIRST_RECEIPT",
+		Name:             "First Receipt",
+		Description:      "Submit your first receipt",
+		Category:         "receipt",
+		Icon:             "🧾",
+		RequirementType:  RequireReceipts,
+		RequirementValue: 1,
+		RewardLuvy:       5,
+	},
+	{
+		Code:             "TEN_MERCHANTS",
+		Name:             "Explorer",
+		Description:      "Shop at 10 different merchants",
+		Category:         "spending",
+		Icon:             "🗺️",
+		RequirementType:  RequireMerchants,
+		RequirementValue: 10,
+		RewardLuvy:       25,
+	},
+	{
+		Code:             "STREAK_30",
+		Name:             "30-Day Streak",
+		Description:      "Submit a receipt 30 days in a row",
+		Category:         "streak",
+		Icon:             "🔥",
+		RequirementType:  RequireStreak,
+		RequirementValue: 30,
+		RewardLuvy:       100,
+	},
+}
@@ -0,0 +1,53 @@
+package gamification
+
+import "math"
+
+const (
+	LevelBronze   = "bronze"
+	LevelSilver   = "silver"
+	LevelGold     = "gold"
+	LevelPlatinum = "platinum"
+	LevelDiamond  = "diamond"
+)
+
+// XPPerLuvy matches the Node service: every LUVY earned is worth 10 XP.
+const XPPerLuvy = 10
+
+type threshold struct {
+	Level   string
+	MinLuvy float64
+}
+
+// thresholds are ordered from lowest to highest (total LUVY earned).
+var thresholds = []threshold{
+	{LevelBronze, 0},
+	{LevelSilver, 500},
+	{LevelGold, 2000},
+	{LevelPlatinum, 5000},
+	{LevelDiamond, 10000},
+}
+
+func CalculateXP(luvy float64) int {
+	return int(math.Floor(luvy * XPPerLuvy))
+}
+
+func CalculateLevel(totalLuvy float64) string {
+	level := LevelBronze
+	for _, t := range thresholds {
+		if totalLuvy >= t.MinLuvy {
+			level = t.Level
+		}
+	}
+	return level
+}
+
+// NextLevel returns the level after the one reached with totalLuvy and how
+// much LUVY is still missing. ok is false once diamond is reached.
+func NextLevel(totalLuvy float64) (level string, missing float64, ok bool) {
+	for _, t := range thresholds {
+		if totalLuvy < t.MinLuvy {
+			return t.Level, t.MinLuvy - totalLuvy, true
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,195 @@
+package gamification
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/src/models"
+)
+
+// Achievement requirement types.
+const (
+	RequireReceipts  = "receipts"
+	RequireMerchants = "merchants"
+	RequireStreak    = "streak"
+	RequireLuvy      = "luvy"
+)
+
+type Service struct {
+	DB *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+// Result describes what a single approved receipt changed for the user.
+type Result struct {
+	XPEarned  int                  `json:"xp_earned"`
+	Level     string               `json:"level"`
+	LeveledUp bool                 `json:"leveled_up"`
+	OldLevel  string               `json:"old_level,omitempty"`
+	Unlocked  []models.Achievement `json:"unlocked_achievements"`
+}
+
+// OnReceiptApproved updates the user's level and unlocks any achievements the
+// receipt made reachable. tx should be the transaction that approved the
+// receipt so a rollback also undoes the progress. streakDays is the user's
+// daily streak after this receipt, as tracked by the streaks package.
+func (s *Service) OnReceiptApproved(tx *gorm.DB, receipt *models.Receipt, streakDays int) (Result, error) {
+	var res Result
+
+	lvl, err := s.levelFor(tx, receipt.UserID)
+	if err != nil {
+		return res, err
+	}
+
+	res.OldLevel = lvl.Level
+	res.XPEarned = CalculateXP(receipt.TokensEarned)
+
+	lvl.TotalLuvyEarned += receipt.TokensEarned
+	lvl.CurrentXP += res.XPEarned
+	lvl.ReceiptsSubmitted++
+	lvl.ConsecutiveDays = streakDays
+	lvl.Level = CalculateLevel(lvl.TotalLuvyEarned)
+	active := receipt.CreatedAt
+	lvl.LastActiveDate = &active
+
+	if err := tx.Save(&lvl).Error; err != nil {
+		return res, err
+	}
+
+	res.Level = lvl.Level
+	res.LeveledUp = lvl.Level != res.OldLevel
+	if !res.LeveledUp {
+		res.OldLevel = ""
+	}
+
+	res.Unlocked, err = s.unlockAchievements(tx, lvl)
+	return res, err
+}
+
+// Level returns the stored level for a user, or a fresh bronze level if the
+// user has not earned anything yet.
+func (s *Service) Level(userID uint) (models.UserLevel, error) {
+	lvl := models.UserLevel{UserID: userID, Level: LevelBronze}
+	err := s.DB.Where("user_id = ?", userID).Limit(1).Find(&lvl).Error
+	return lvl, err
+}
+
+type AchievementStatus struct {
+	models.Achievement
+	Unlocked   bool       `json:"unlocked"`
+	UnlockedAt *time.Time `json:"unlocked_at"`
+}
+
+// Achievements lists every active achievement with the user's unlock state.
+func (s *Service) Achievements(userID uint) ([]AchievementStatus, error) {
+	var all []models.Achievement
+	if err := s.DB.Where("is_active = ?", true).Order("id ASC").Find(&all).Error; err != nil {
+		return nil, err
+	}
+
+	var mine []models.UserAchievement
+	if err := s.DB.Where("user_id = ?", userID).Find(&mine).Error; err != nil {
+		return nil, err
+	}
+	unlockedAt := make(map[uint]time.Time, len(mine))
+	for _, ua := range mine {
+		unlockedAt[ua.AchievementID] = ua.UnlockedAt
+	}
+
+	out := make([]AchievementStatus, 0, len(all))
+	for _, a := range all {
+		st := AchievementStatus{Achievement: a}
+		if t, ok := unlockedAt[a.ID]; ok {
+			st.Unlocked = true
+			st.UnlockedAt = &t
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// levelFor returns the user's level row, created if missing and locked for
+// the rest of tx, so concurrent approvals for one user add their XP in turn
+// instead of saving over each other.
+func (s *Service) levelFor(tx *gorm.DB, userID uint) (models.UserLevel, error) {
+	// a racing insert waits for the other one and then does nothing
+	fresh := models.UserLevel{UserID: userID, Level: LevelBronze}
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).Create(&fresh).Error
+	if err != nil {
+		return fresh, err
+	}
+
+	var lvl models.UserLevel
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&lvl).Error
+	return lvl, err
+}
+
+func (s *Service) unlockAchievements(tx *gorm.DB, lvl models.UserLevel) ([]models.Achievement, error) {
+	var candidates []models.Achievement
+	err := tx.Where("is_active = ?", true).
+		Where("id NOT IN (?)", tx.Model(&models.UserAchievement{}).Select("achievement_id").Where("user_id = ?", lvl.UserID)).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	merchants := int64(-1)
+	unlocked := []models.Achievement{}
+
+	for _, a := range candidates {
+		reached := false
+		switch a.RequirementType {
+		case RequireReceipts:
+			reached = lvl.ReceiptsSubmitted >= a.RequirementValue
+		case RequireStreak:
+			reached = lvl.ConsecutiveDays >= a.RequirementValue
+		case RequireLuvy:
+			reached = lvl.TotalLuvyEarned >= float64(a.RequirementValue)
+		case RequireMerchants:
+			if merchants < 0 {
+				if err := tx.Model(&models.Receipt{}).
+					Where("user_id = ? AND status = ?", lvl.UserID, "completed").
+					Distinct("merchant").Count(&merchants).Error; err != nil {
+					return nil, err
+				}
+			}
+			reached = merchants >= int64(a.RequirementValue)
+		}
+		if !reached {
+			continue
+		}
+
+		// The unique (user_id, achievement_id) index keeps this idempotent even
+		// if two receipts race past the same threshold.
+		ua := models.UserAchievement{UserID: lvl.UserID, AchievementID: a.ID, UnlockedAt: time.Now()}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Achievement").Create(&ua)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		if a.RewardLuvy > 0 {
+			if err := ledger.Credit(tx, &models.Transaction{
+				UserID:        lvl.UserID,
+				Type:          ledger.TypeBonus,
+				Amount:        a.RewardLuvy,
+				Description:   "Achievement unlocked: " + a.Name,
+				ReferenceType: "achievement",
+				ReferenceID:   a.ID,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		unlocked = append(unlocked, a)
+	}
+
+	return unlocked, nil
+}
@@ -0,0 +1,46 @@
+package gamification
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"luvy-go-backend/internal/pgtest"
+	"luvy-go-backend/src/models"
+)
+
+func TestConcurrentApprovalsKeepEveryXP(t *testing.T) {
+	db := pgtest.Gorm(t, pgtest.Open(t, &models.UserLevel{}, &models.Achievement{}, &models.UserAchievement{}, &models.Receipt{}))
+	s := NewService(db)
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.Transaction(func(tx *gorm.DB) error {
+				_, err := s.OnReceiptApproved(tx, &models.Receipt{UserID: 7, TokensEarned: 10, CreatedAt: time.Now()}, 1)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lvl, err := s.Level(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lvl.ReceiptsSubmitted != n || lvl.CurrentXP != n*CalculateXP(10) || lvl.TotalLuvyEarned != n*10 {
+		t.Fatalf("after %d approvals: receipts=%d xp=%d luvy=%v", n, lvl.ReceiptsSubmitted, lvl.CurrentXP, lvl.TotalLuvyEarned)
+	}
+}
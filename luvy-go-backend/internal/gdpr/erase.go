@@ -0,0 +1,168 @@
+package gdpr
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"luvy-go-backend/internal/audit"
+	"luvy-go-backend/internal/outbox"
+)
+
+// Values a step can bind, in the order it lists them as $1, $2, ...
+const (
+	argID        = iota // users.id
+	argIDText           // the id in TEXT user columns
+	argEmail            // the address before erasure
+	argPseudonym        // fresh random id for analytics
+)
+
+// step is one statement of an erasure.
+type step struct {
+	name  string
+	query string
+	args  []int
+}
+
+// erasure anonymises the user row, removes what only identifies or
+// contacts the person, and pseudonymises analytics. What it keeps is listed,
+// with the reason, in Retained.
+var erasure = []step{
+	{"users", `
+UPDATE users SET name = '', email = 'erased-' || id || '@erased.invalid', phone = '', city = '',
+  password = '', signup_device_id = '', quiet_hours_start = '', quiet_hours_end = '',
+  email_verified_at = NULL, leaderboard_opt_out = TRUE, erased_at = NOW(), updated_at = NOW()
+WHERE id = $1`, []int{argID}},
+	// receipt photos can show names and card numbers
+	{"receipts", `UPDATE receipts SET image_url = '' WHERE user_id = $1 AND image_url <> ''`, []int{argID}},
+	{"push_tokens", `DELETE FROM push_tokens WHERE user_id = $1`, []int{argIDText}},
+	{"account_tokens", `DELETE FROM account_tokens WHERE user_id = $1`, []int{argID}},
+	// one random pseudonym per erasure keeps funnels and retention counting
+	{"analytics_events", `UPDATE analytics_events SET user_id = $1 WHERE user_id = $2`, []int{argPseudonym, argIDText}},
+	{"notifications", `DELETE FROM notifications WHERE user_id = $1`, []int{argID}},
+	{"notification_preferences", `DELETE FROM notification_preferences WHERE user_id = $1`, []int{argID}},
+	{"leaderboard_entries", `DELETE FROM leaderboard_entries WHERE user_id = $1`, []int{argID}},
+	{"referral_codes", `DELETE FROM referral_codes WHERE user_id = $1`, []int{argID}},
+	// the code named the referrer; a pending referral can no longer pay out
+	{"referrals", `
+UPDATE referrals SET referral_code = '', status = CASE WHEN status = 'pending' THEN 'expired' ELSE status END, updated_at = NOW()
+WHERE referrer_id = $1 OR referred_user_id = $1`, []int{argID}},
+	{"budget_alerts", `DELETE FROM budget_alerts WHERE budget_id IN (SELECT id FROM budgets WHERE user_id = $1)`, []int{argID}},
+	{"budgets", `DELETE FROM budgets WHERE user_id = $1`, []int{argID}},
+	{"user_insights", `DELETE FROM user_insights WHERE user_id = $1`, []int{argID}},
+	{"data_exports", `
+UPDATE data_exports SET file = NULL, token = NULL, status = 'EXPIRED'
+WHERE user_id = $1 AND status IN ('PENDING', 'READY')`, []int{argID}},
+	// notifications still queued for the person are not sent ...
+	{"outbox_cancelled", `
+WITH u AS (
+  UPDATE outbox_events SET status = 'CANCELLED', payload = '{}'::jsonb, next_retry_at = NULL, updated_at = NOW()
+  WHERE status IN ('PENDING', 'FAILED')
+    AND (payload->>'user_id' = $1 OR payload->>'to' = $2)
+  RETURNING id, attempt_count
+)
+INSERT INTO outbox_event_log (event_id, attempt, status, actor)
+SELECT id, attempt_count, 'CANCELLED', 'gdpr' FROM u`, []int{argIDText, argEmail}},
+	// ... and the copies of their details in delivered ones are dropped
+	{"outbox_redacted", `
+UPDATE outbox_events SET payload = '{}'::jsonb, updated_at = NOW()
+WHERE status IN ('SENT', 'SKIPPED', 'CANCELLED', 'DEAD')
+  AND (payload->>'user_id' = $1 OR payload->>'to' = $2)`, []int{argIDText, argEmail}},
+}
+
+// Retention is a kind of record an erasure keeps, as explained to the user.
+type Retention struct {
+	Table  string `json:"table"`
+	Reason string `json:"reason"`
+}
+
+// Retained lists what an erasure keeps. Apart from the audit log, every row
+// is keyed only to the anonymised account id. Erasure requests carry it in
+// their response.
+var Retained = []Retention{
+	{"audit_logs", "The audit log is append-only and kept unaltered as the legal record of account, receipt and administrative actions, including the IP address and browser of each request."},
+	{"transactions", "Ledger entries are kept for accounting and tax records."},
+	{"receipts", "Receipt amounts, dates and merchants are kept, without the photo, for accounting and aggregate statistics."},
+	{"referrals", "Completed referrals are kept, without the code, because their rewards are in the ledger."},
+	{"user_levels", "Levels are kept so aggregate statistics stay unchanged."},
+	{"user_streaks", "Streaks are kept so aggregate statistics stay unchanged."},
+	{"user_achievements", "Achievements are kept because their rewards are in the ledger."},
+	{"user_challenges", "Challenge progress is kept because its rewards are in the ledger."},
+	{"privacy_requests", "Privacy requests are kept as the record that this erasure was carried out."},
+}
+
+func (s *Service) erase(ctx context.Context, _ outbox.Event, p ErasePayload) error {
+	var (
+		userID uint
+		status string
+		due    bool
+		at     time.Time
+	)
+	err := s.DB.QueryRowContext(ctx, `
+SELECT user_id, status, scheduled_for <= NOW(), scheduled_for FROM privacy_requests WHERE id::text = $1
+`, p.RequestID).Scan(&userID, &status, &due, &at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return outbox.Skip("privacy request no longer exists")
+	}
+	if err != nil {
+		return err
+	}
+	if status != StatusScheduled {
+		return outbox.Skip("erasure is " + status)
+	}
+	if !due {
+		return outbox.DeferUntil(at, "erasure grace period")
+	}
+
+	summary, err := s.Erase(ctx, userID, p.RequestID)
+	if err != nil {
+		return err
+	}
+	s.audit(ctx, userID, audit.RoleSystem, audit.ActionPrivacyErasureComplete, p.RequestID, map[string]any{"rows": summary})
+	return nil
+}
+
+// Erase carries out an erasure now and completes requestID, all in one
+// transaction. It returns the number of rows each step changed.
+func (s *Service) Erase(ctx context.Context, userID uint, requestID string) (map[string]int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, outbox.Permanent(ErrUnknownUser)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := []any{argID: userID, argIDText: strconv.FormatUint(uint64(userID), 10), argEmail: email, argPseudonym: pseudonym()}
+	summary := map[string]int64{}
+	for _, st := range erasure {
+		args := make([]any, len(st.args))
+		for i, a := range st.args {
+			args[i] = values[a]
+		}
+		res, err := tx.ExecContext(ctx, st.query, args...)
+		if err != nil {
+			return nil, err
+		}
+		summary[st.name], _ = res.RowsAffected()
+	}
+
+	b, _ := json.Marshal(summary)
+	_, err = tx.ExecContext(ctx, `
+UPDATE privacy_requests SET status = $2, completed_at = NOW(), summary = $3::jsonb WHERE id::text = $1
+`, requestID, StatusCompleted, string(b))
+	if err != nil {
+		return nil, err
+	}
+	return summary, tx.Commit()
+}
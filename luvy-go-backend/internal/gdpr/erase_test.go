@@ -0,0 +1,116 @@
+package gdpr
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"luvy-go-backend/internal/pgtest"
+	"luvy-go-backend/src/models"
+)
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// Postgres rejects a statement whose parameters don't all appear in it.
+func TestErasureStepsBindTheirArgs(t *testing.T) {
+	seen := map[string]bool{}
+	for _, st := range erasure {
+		if seen[st.name] {
+			t.Errorf("step %s listed twice", st.name)
+		}
+		seen[st.name] = true
+
+		used := map[int]bool{}
+		for _, m := range placeholder.FindAllStringSubmatch(st.query, -1) {
+			n, _ := strconv.Atoi(m[1])
+			used[n] = true
+		}
+		for n := 1; n <= len(st.args); n++ {
+			if !used[n] {
+				t.Errorf("%s: $%d is bound but not used", st.name, n)
+			}
+		}
+		if len(used) != len(st.args) {
+			t.Errorf("%s: uses %d parameters, binds %d", st.name, len(used), len(st.args))
+		}
+	}
+}
+
+// Every table the access export reads must be erased or explained.
+func TestErasureCoversEverySource(t *testing.T) {
+	handled := map[string]bool{}
+	for _, st := range erasure {
+		handled[st.name] = true
+	}
+	for _, r := range Retained {
+		handled[r.Table] = true
+	}
+	for _, src := range sources {
+		if !handled[src.name] {
+			t.Errorf("%s is exported but neither erased nor listed in Retained", src.name)
+		}
+	}
+}
+
+func TestEraseAnonymisesUser(t *testing.T) {
+	db := pgtest.Open(t, &models.User{}, &models.Receipt{}, &models.Transaction{}, &models.UserLevel{},
+		&models.UserAchievement{}, &models.UserChallenge{}, &models.UserStreak{}, &models.ReferralCode{},
+		&models.Referral{}, &models.LeaderboardEntry{}, &models.NotificationPreference{}, &models.Notification{},
+		&models.Budget{}, &models.BudgetAlert{}, &models.UserInsight{}, &models.AccountToken{})
+	ctx := context.Background()
+	s := NewService(db, nil, nil)
+
+	var userID, friendID uint
+	if err := db.QueryRow(`INSERT INTO users (name, email, phone) VALUES ('Ana', 'ana@example.com', '555') RETURNING id`).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`INSERT INTO users (name, email) VALUES ('Bo', 'bo@example.com') RETURNING id`).Scan(&friendID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO referral_codes (user_id, code) VALUES ($1, 'LUVYANA')`, userID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+INSERT INTO referrals (referrer_id, referred_user_id, referral_code, status, expires_at)
+VALUES ($1, $2, 'LUVYANA', 'pending', NOW() + interval '30 days')`, userID, friendID); err != nil {
+		t.Fatal(err)
+	}
+	var requestID string
+	if err := db.QueryRow(`
+INSERT INTO privacy_requests (user_id, kind, status, scheduled_for) VALUES ($1, $2, $3, NOW()) RETURNING id::text
+`, userID, KindErasure, StatusScheduled).Scan(&requestID); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := s.Erase(ctx, userID, requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary) != len(erasure) || summary["users"] != 1 || summary["referrals"] != 1 || summary["referral_codes"] != 1 {
+		t.Fatalf("summary %v", summary)
+	}
+
+	var name, email string
+	if err := db.QueryRow(`SELECT name, email FROM users WHERE id = $1`, userID).Scan(&name, &email); err != nil {
+		t.Fatal(err)
+	}
+	if name != "" || email != "erased-"+strconv.Itoa(int(userID))+"@erased.invalid" {
+		t.Fatalf("user left as %q %q", name, email)
+	}
+	var code, status string
+	if err := db.QueryRow(`SELECT referral_code, status FROM referrals WHERE referrer_id = $1`, userID).Scan(&code, &status); err != nil {
+		t.Fatal(err)
+	}
+	if code != "" || status != "expired" {
+		t.Fatalf("referral left as %q %q", code, status)
+	}
+
+	req, err := getRequest(db.QueryRow(`SELECT `+requestColumns+requestFrom+`WHERE r.id::text = $1`, requestID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Status != StatusCompleted || len(req.Retained) == 0 {
+		t.Fatalf("request %+v", req)
+	}
+}
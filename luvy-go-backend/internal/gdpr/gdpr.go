@@ -0,0 +1,291 @@
+// Package gdpr handles data subject requests: access (a machine-readable
+// dump of everything stored about the user, delivered as a data export) and
+// erasure (personal data anonymised after a grace period, with receipts and
+// the ledger kept so balances and totals still add up).
+package gdpr
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"luvy-go-backend/internal/audit"
+	"luvy-go-backend/internal/outbox"
+)
+
+const (
+	KindAccess  = "access"
+	KindErasure = "erasure"
+)
+
+const (
+	StatusPending   = "PENDING"
+	StatusScheduled = "SCHEDULED"
+	StatusCompleted = "COMPLETED"
+	StatusCancelled = "CANCELLED"
+	StatusFailed    = "FAILED"
+)
+
+// GracePeriod is how long an erasure waits, and can be cancelled, before it
+// is carried out.
+const GracePeriod = 30 * 24 * time.Hour
+
+// EraseEventType carries out one erasure request once it is due.
+const EraseEventType = "GDPR_ERASE"
+
+const AggregatePrivacyRequest = "privacy_request"
+
+var (
+	ErrNotFound       = errors.New("privacy request not found")
+	ErrAlreadyPending = errors.New("an erasure is already scheduled")
+	ErrUnknownUser    = errors.New("user not found")
+)
+
+// Request is a privacy_requests row. Access requests take their status from
+// the export that fulfils them.
+type Request struct {
+	ID           string          `json:"id"`
+	UserID       uint            `json:"user_id"`
+	Kind         string          `json:"kind"`
+	Status       string          `json:"status"`
+	ExportID     *string         `json:"export_id,omitempty"`
+	ScheduledFor *time.Time      `json:"scheduled_for,omitempty"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty"`
+	CancelledAt  *time.Time      `json:"cancelled_at,omitempty"`
+	Summary      json.RawMessage `json:"summary,omitempty"`
+	Retained     []Retention     `json:"retained,omitempty"` // erasures only
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// ExportCreator starts the export that answers an access request, inside
+// the caller's transaction, and returns its id.
+type ExportCreator interface {
+	CreatePersonalData(ctx context.Context, tx *sql.Tx, ob *outbox.Repo, userID uint) (string, error)
+}
+
+type Service struct {
+	DB      *sql.DB
+	Outbox  *outbox.Repo
+	Exports ExportCreator
+	Audit   *audit.Log
+	Grace   time.Duration // defaults to GracePeriod
+}
+
+func NewService(db *sql.DB, exports ExportCreator, log *audit.Log) *Service {
+	return &Service{DB: db, Exports: exports, Audit: log, Grace: GracePeriod}
+}
+
+// Register adds GDPR_ERASE to reg; repo is where requests are queued.
+func (s *Service) Register(reg *outbox.Registry, repo *outbox.Repo) {
+	s.Outbox = repo
+	outbox.Register(reg, EraseEventType, outbox.Policy{Timeout: 2 * time.Minute}, s.erase)
+}
+
+type ErasePayload struct {
+	RequestID string `json:"request_id"`
+}
+
+func (p ErasePayload) Validate() error {
+	if p.RequestID == "" {
+		return errors.New("request_id is required")
+	}
+	return nil
+}
+
+const requestColumns = `r.id, r.user_id, r.kind,
+CASE WHEN r.kind = 'access' THEN
+  CASE e.status WHEN 'READY' THEN 'COMPLETED' WHEN 'EXPIRED' THEN 'COMPLETED' WHEN 'FAILED' THEN 'FAILED' ELSE r.status END
+ELSE r.status END,
+r.export_id, r.scheduled_for, COALESCE(r.completed_at, CASE WHEN r.kind = 'access' THEN e.completed_at END),
+r.cancelled_at, r.summary, r.created_at`
+
+const requestFrom = ` FROM privacy_requests r LEFT JOIN data_exports e ON e.id = r.export_id `
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scanRequest(sc scanner, r *Request) error {
+	var summary []byte
+	if err := sc.Scan(&r.ID, &r.UserID, &r.Kind, &r.Status, &r.ExportID, &r.ScheduledFor, &r.CompletedAt, &r.CancelledAt, &summary, &r.CreatedAt); err != nil {
+		return err
+	}
+	r.Summary = summary
+	if r.Kind == KindErasure {
+		r.Retained = Retained
+	}
+	return nil
+}
+
+func getRequest(q scanner) (Request, error) {
+	var r Request
+	err := scanRequest(q, &r)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+// RequestAccess records an access request and starts the export of the
+// user's data; the user is emailed a download link when it is ready.
+func (s *Service) RequestAccess(ctx context.Context, userID uint) (Request, error) {
+	if err := s.userExists(ctx, userID); err != nil {
+		return Request{}, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Request{}, err
+	}
+	defer tx.Rollback()
+
+	exportID, err := s.Exports.CreatePersonalData(ctx, tx, s.Outbox, userID)
+	if err != nil {
+		return Request{}, err
+	}
+	var id string
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO privacy_requests (user_id, kind, status, export_id) VALUES ($1, $2, $3, $4) RETURNING id
+`, userID, KindAccess, StatusPending, exportID).Scan(&id)
+	if err != nil {
+		return Request{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Request{}, err
+	}
+
+	s.audit(ctx, userID, audit.RoleCustomer, audit.ActionPrivacyAccess, id, map[string]any{"export_id": exportID})
+	return s.Get(ctx, userID, id)
+}
+
+// RequestErasure schedules the user's erasure after the grace period. The
+// GDPR_ERASE event waits in the outbox until then.
+func (s *Service) RequestErasure(ctx context.Context, userID uint) (Request, error) {
+	if err := s.userExists(ctx, userID); err != nil {
+		return Request{}, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Request{}, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO privacy_requests (user_id, kind, status, scheduled_for)
+VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+ON CONFLICT (user_id) WHERE kind = 'erasure' AND status = 'SCHEDULED' DO NOTHING
+RETURNING id
+`, userID, KindErasure, StatusScheduled, s.grace().Seconds()).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Request{}, ErrAlreadyPending
+	}
+	if err != nil {
+		return Request{}, err
+	}
+	if err := s.Outbox.EnqueueTx(ctx, tx, AggregatePrivacyRequest, &id, EraseEventType, ErasePayload{RequestID: id}); err != nil {
+		return Request{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Request{}, err
+	}
+
+	s.audit(ctx, userID, audit.RoleCustomer, audit.ActionPrivacyErasureRequest, id, map[string]any{"grace_days": int(s.grace().Hours() / 24)})
+	return s.Get(ctx, userID, id)
+}
+
+// CancelErasure withdraws the user's scheduled erasure during the grace
+// period.
+func (s *Service) CancelErasure(ctx context.Context, userID uint) (Request, error) {
+	var id string
+	err := s.DB.QueryRowContext(ctx, `
+UPDATE privacy_requests SET status=$3, cancelled_at=NOW()
+WHERE user_id=$1 AND kind=$2 AND status=$4
+RETURNING id
+`, userID, KindErasure, StatusCancelled, StatusScheduled).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Request{}, ErrNotFound
+	}
+	if err != nil {
+		return Request{}, err
+	}
+
+	s.audit(ctx, userID, audit.RoleCustomer, audit.ActionPrivacyErasureCancel, id, nil)
+	return s.Get(ctx, userID, id)
+}
+
+func (s *Service) Get(ctx context.Context, userID uint, id string) (Request, error) {
+	return getRequest(s.DB.QueryRowContext(ctx, `SELECT `+requestColumns+requestFrom+`WHERE r.id::text=$1 AND r.user_id=$2`, id, userID))
+}
+
+// List returns the user's requests, or every user's when userID is 0
+// (admin), newest first.
+func (s *Service) List(ctx context.Context, userID uint, limit int) ([]Request, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+requestColumns+requestFrom+`
+WHERE $1 = 0 OR r.user_id = $1
+ORDER BY r.created_at DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Request{}
+	for rows.Next() {
+		var r Request
+		if err := scanRequest(rows, &r); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *Service) userExists(ctx context.Context, userID uint) error {
+	var one int
+	err := s.DB.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id=$1`, userID).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUnknownUser
+	}
+	return err
+}
+
+func (s *Service) grace() time.Duration {
+	if s.Grace > 0 {
+		return s.Grace
+	}
+	return GracePeriod
+}
+
+// audit records after the commit; a failed write does not undo the request.
+func (s *Service) audit(ctx context.Context, userID uint, role, action, requestID string, meta map[string]any) {
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	meta["user_id"] = userID
+	actor := ""
+	if role == audit.RoleCustomer {
+		actor = strconv.FormatUint(uint64(userID), 10)
+	}
+	err := s.Audit.Record(ctx, audit.Entry{
+		ActorID:   actor,
+		ActorRole: role,
+		Action:    action,
+		Resource:  "privacy_request:" + requestID,
+		Metadata:  meta,
+	})
+	if err != nil {
+		log.Printf("audit %s failed: %v", action, err)
+	}
+}
+
+func pseudonym() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "erased_" + hex.EncodeToString(b)
+}
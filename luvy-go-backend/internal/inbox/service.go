@@ -0,0 +1,143 @@
+package inbox
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"luvy-go-backend/src/models"
+)
+
+const (
+	// MaxPerUser caps a user's inbox; the oldest entries go first.
+	MaxPerUser = 200
+	// Retention is how long entries are kept at all, read or not.
+	Retention = 90 * 24 * time.Hour
+
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrNotFound = errors.New("notification not found")
+
+type Service struct {
+	DB *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+type Query struct {
+	Before     uint // cursor: only entries with a smaller ID
+	Limit      int
+	UnreadOnly bool
+}
+
+type Page struct {
+	Notifications []models.Notification `json:"notifications"`
+	NextCursor    uint                  `json:"next_cursor,omitempty"`
+	Unread        int64                 `json:"unread"`
+}
+
+// List returns newest first. Pass NextCursor back as Before for the next page.
+func (s *Service) List(userID uint, q Query) (Page, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+
+	// Retention is applied lazily, like referral expiry.
+	if err := s.Prune(userID, time.Now()); err != nil {
+		return Page{}, err
+	}
+
+	db := s.DB.Where("user_id = ?", userID)
+	if q.Before > 0 {
+		db = db.Where("id < ?", q.Before)
+	}
+	if q.UnreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+
+	// one extra row tells whether there is a next page
+	var rows []models.Notification
+	if err := db.Order("id DESC").Limit(q.Limit + 1).Find(&rows).Error; err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Notifications: rows}
+	if len(rows) > q.Limit {
+		page.Notifications = rows[:q.Limit]
+		page.NextCursor = rows[q.Limit-1].ID
+	}
+
+	unread, err := s.UnreadCount(userID)
+	if err != nil {
+		return Page{}, err
+	}
+	page.Unread = unread
+	return page, nil
+}
+
+func (s *Service) UnreadCount(userID uint) (int64, error) {
+	var n int64
+	err := s.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&n).Error
+	return n, err
+}
+
+func (s *Service) MarkRead(userID, id uint) error {
+	res := s.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Service) MarkAllRead(userID uint) (int64, error) {
+	res := s.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+func (s *Service) Delete(userID, id uint) error {
+	res := s.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Notification{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Prune drops a user's entries past Retention and beyond MaxPerUser.
+func (s *Service) Prune(userID uint, now time.Time) error {
+	if err := s.DB.Where("user_id = ? AND created_at < ?", userID, now.Add(-Retention)).
+		Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+
+	var cutoff []uint
+	if err := s.DB.Model(&models.Notification{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").Offset(MaxPerUser).Limit(1).
+		Pluck("id", &cutoff).Error; err != nil {
+		return err
+	}
+	if len(cutoff) == 0 {
+		return nil
+	}
+	return s.DB.Where("user_id = ? AND id <= ?", userID, cutoff[0]).Delete(&models.Notification{}).Error
+}
@@ -0,0 +1,70 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Entry is what the outbox worker stores for an INAPP_NOTIFY event.
+type Entry struct {
+	UserID   string
+	Category string
+	Type     string
+	Title    string
+	Body     string
+	Data     map[string]string
+}
+
+// Writer is the outbox worker's database/sql path into the notifications
+// table that Service reads through gorm.
+type Writer struct {
+	DB *sql.DB
+}
+
+func NewWriter(db *sql.DB) *Writer {
+	return &Writer{DB: db}
+}
+
+// Insert stores the entry and trims the user's inbox in the same
+// transaction, so it never grows past MaxPerUser.
+func (w *Writer) Insert(ctx context.Context, e Entry) (int64, error) {
+	userID, err := strconv.ParseUint(e.UserID, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	data := []byte("{}")
+	if len(e.Data) > 0 {
+		if data, err = json.Marshal(e.Data); err != nil {
+			return 0, err
+		}
+	}
+
+	tx, err := w.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, `
+INSERT INTO notifications (user_id, category, type, title, body, data, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW())
+RETURNING id
+`, userID, e.Category, e.Type, e.Title, e.Body, string(data)).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM notifications
+WHERE user_id = $1
+  AND (created_at < $2
+       OR id <= (SELECT id FROM notifications WHERE user_id = $1 ORDER BY id DESC OFFSET $3 LIMIT 1))
+`, userID, time.Now().Add(-Retention), MaxPerUser); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
@@ -0,0 +1,335 @@
+package insights
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"luvy-go-backend/internal/timebucket"
+)
+
+// receipt is the part of a receipt the detectors look at.
+type receipt struct {
+	ID           uint
+	Merchant     string
+	Category     string
+	Amount       float64
+	TokensEarned float64
+	CreatedAt    time.Time
+}
+
+// Anomaly is a recent receipt well above what the user usually spends at
+// that merchant.
+type Anomaly struct {
+	ReceiptID uint      `json:"receipt_id"`
+	Merchant  string    `json:"merchant"`
+	Category  string    `json:"category"`
+	Amount    float64   `json:"amount"`
+	Typical   float64   `json:"typical"` // mean of the earlier receipts
+	Ratio     float64   `json:"ratio"`   // Amount / Typical
+	ZScore    float64   `json:"z_score"`
+	At        time.Time `json:"at"`
+}
+
+// Recurring is a merchant the user buys from at a steady interval.
+type Recurring struct {
+	Merchant      string    `json:"merchant"`
+	Category      string    `json:"category"`
+	Cadence       string    `json:"cadence"` // weekly, biweekly, monthly or every_n_days
+	IntervalDays  int       `json:"interval_days"`
+	AverageAmount float64   `json:"average_amount"`
+	Occurrences   int       `json:"occurrences"`
+	LastAt        time.Time `json:"last_at"`
+	NextExpected  time.Time `json:"next_expected"`
+}
+
+// CategoryShift compares this month so far with the same days of last month.
+type CategoryShift struct {
+	Category      string   `json:"category"`
+	Current       float64  `json:"current"`
+	Previous      float64  `json:"previous"`
+	Change        float64  `json:"change"`
+	ChangePct     *float64 `json:"change_pct"` // nil when nothing was spent before
+	ShareCurrent  float64  `json:"share_current"`
+	SharePrevious float64  `json:"share_previous"`
+}
+
+// Earner is a merchant ranked by the LUVY it earned the user.
+type Earner struct {
+	Merchant     string  `json:"merchant"`
+	TokensEarned float64 `json:"tokens_earned"`
+	Spent        float64 `json:"spent"`
+	Receipts     int     `json:"receipts"`
+	Rate         float64 `json:"rate"` // LUVY per unit spent
+}
+
+// Detection settings.
+const (
+	anomalyWindow    = 30 * 24 * time.Hour // receipts this recent are checked
+	anomalyMinPrior  = 3                   // earlier receipts needed for a baseline
+	anomalyMinZ      = 2.0
+	anomalyMinRatio  = 1.5
+	recurringMinSeen = 3
+	recurringMaxGaps = 6 // latest intervals considered
+	earnerWindow     = 90 * 24 * time.Hour
+	maxItems         = 10
+)
+
+// merchantKey groups spellings like "Migros " and "migros".
+func merchantKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// anomalies flags recent receipts far above the mean of the same merchant's
+// earlier receipts: both anomalyMinZ standard deviations and anomalyMinRatio
+// times the mean. receipts must be oldest first.
+func anomalies(receipts []receipt, now time.Time) []Anomaly {
+	out := []Anomaly{}
+	prior := map[string][]float64{}
+	for _, r := range receipts {
+		key := merchantKey(r.Merchant)
+		hist := prior[key]
+		if len(hist) >= anomalyMinPrior && now.Sub(r.CreatedAt) <= anomalyWindow {
+			mean, sd := meanStd(hist)
+			if mean > 0 && r.Amount >= mean*anomalyMinRatio {
+				z := math.Inf(1)
+				if sd > 0 {
+					z = (r.Amount - mean) / sd
+				}
+				if z >= anomalyMinZ {
+					out = append(out, Anomaly{
+						ReceiptID: r.ID,
+						Merchant:  r.Merchant,
+						Category:  r.Category,
+						Amount:    r.Amount,
+						Typical:   round2(mean),
+						Ratio:     round2(r.Amount / mean),
+						ZScore:    round2(math.Min(z, 99)),
+						At:        r.CreatedAt,
+					})
+				}
+			}
+		}
+		prior[key] = append(hist, r.Amount)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].At.After(out[j].At) })
+	return limit(out)
+}
+
+// recurring finds merchants visited at least recurringMinSeen times whose
+// latest gaps all stay within a quarter (at least two days) of their median.
+// Visits on the same local day count once.
+func recurring(receipts []receipt, loc *time.Location) []Recurring {
+	type visits struct {
+		name, category string
+		days           []time.Time
+		amounts        []float64
+	}
+	byMerchant := map[string]*visits{}
+	var order []string
+	for _, r := range receipts {
+		key := merchantKey(r.Merchant)
+		v := byMerchant[key]
+		if v == nil {
+			v = &visits{name: r.Merchant, category: r.Category}
+			byMerchant[key] = v
+			order = append(order, key)
+		}
+		day := timebucket.Day.Truncate(r.CreatedAt.In(loc))
+		if n := len(v.days); n == 0 || !v.days[n-1].Equal(day) {
+			v.days = append(v.days, day)
+		}
+		v.amounts = append(v.amounts, r.Amount)
+	}
+
+	out := []Recurring{}
+	for _, key := range order {
+		v := byMerchant[key]
+		if len(v.days) < recurringMinSeen {
+			continue
+		}
+		days := v.days
+		if len(days) > recurringMaxGaps+1 {
+			days = days[len(days)-recurringMaxGaps-1:]
+		}
+		gaps := make([]float64, len(days)-1)
+		for i := 1; i < len(days); i++ {
+			gaps[i-1] = math.Round(days[i].Sub(days[i-1]).Hours() / 24)
+		}
+		interval := median(gaps)
+		if interval < 1 {
+			continue
+		}
+		tolerance := math.Max(interval/4, 2)
+		steady := true
+		for _, g := range gaps {
+			if math.Abs(g-interval) > tolerance {
+				steady = false
+				break
+			}
+		}
+		if !steady {
+			continue
+		}
+
+		mean, _ := meanStd(v.amounts)
+		last := days[len(days)-1]
+		out = append(out, Recurring{
+			Merchant:      v.name,
+			Category:      v.category,
+			Cadence:       cadence(interval),
+			IntervalDays:  int(interval),
+			AverageAmount: round2(mean),
+			Occurrences:   len(v.days),
+			LastAt:        last,
+			NextExpected:  last.AddDate(0, 0, int(interval)),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].AverageAmount > out[j].AverageAmount })
+	return limit(out)
+}
+
+func cadence(days float64) string {
+	switch {
+	case days >= 6 && days <= 8:
+		return "weekly"
+	case days >= 13 && days <= 15:
+		return "biweekly"
+	case days >= 28 && days <= 31:
+		return "monthly"
+	}
+	return "every_n_days"
+}
+
+// categoryShifts compares this month up to now with the same stretch of the
+// previous month, so a half-finished month is not read as a drop. Largest
+// changes first.
+func categoryShifts(receipts []receipt, now time.Time) []CategoryShift {
+	cur := timebucket.Month.Truncate(now)
+	prev := timebucket.Month.Add(cur, -1)
+	prevEnd := prev.Add(now.Sub(cur))
+	if prevEnd.After(cur) {
+		prevEnd = cur
+	}
+
+	byCategory := map[string]*CategoryShift{}
+	var curTotal, prevTotal float64
+	for _, r := range receipts {
+		var slot *float64
+		s := byCategory[r.Category]
+		if s == nil {
+			s = &CategoryShift{Category: r.Category}
+		}
+		switch {
+		case !r.CreatedAt.Before(cur) && !r.CreatedAt.After(now):
+			slot = &s.Current
+			curTotal += r.Amount
+		case !r.CreatedAt.Before(prev) && r.CreatedAt.Before(prevEnd):
+			slot = &s.Previous
+			prevTotal += r.Amount
+		default:
+			continue
+		}
+		*slot += r.Amount
+		byCategory[r.Category] = s
+	}
+
+	out := []CategoryShift{}
+	for _, s := range byCategory {
+		s.Change = round2(s.Current - s.Previous)
+		if s.Previous > 0 {
+			pct := round2(s.Change / s.Previous * 100)
+			s.ChangePct = &pct
+		}
+		if curTotal > 0 {
+			s.ShareCurrent = round2(s.Current / curTotal * 100)
+		}
+		if prevTotal > 0 {
+			s.SharePrevious = round2(s.Previous / prevTotal * 100)
+		}
+		s.Current, s.Previous = round2(s.Current), round2(s.Previous)
+		out = append(out, *s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if a, b := math.Abs(out[i].Change), math.Abs(out[j].Change); a != b {
+			return a > b
+		}
+		return out[i].Category < out[j].Category
+	})
+	return limit(out)
+}
+
+// topEarners ranks merchants by LUVY earned over the last earnerWindow.
+func topEarners(receipts []receipt, now time.Time) []Earner {
+	byMerchant := map[string]*Earner{}
+	for _, r := range receipts {
+		if now.Sub(r.CreatedAt) > earnerWindow {
+			continue
+		}
+		key := merchantKey(r.Merchant)
+		e := byMerchant[key]
+		if e == nil {
+			e = &Earner{Merchant: r.Merchant}
+			byMerchant[key] = e
+		}
+		e.TokensEarned += r.TokensEarned
+		e.Spent += r.Amount
+		e.Receipts++
+	}
+
+	out := []Earner{}
+	for _, e := range byMerchant {
+		if e.Spent > 0 {
+			e.Rate = round2(e.TokensEarned / e.Spent)
+		}
+		e.TokensEarned, e.Spent = round2(e.TokensEarned), round2(e.Spent)
+		out = append(out, *e)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TokensEarned != out[j].TokensEarned {
+			return out[i].TokensEarned > out[j].TokensEarned
+		}
+		return out[i].Merchant < out[j].Merchant
+	})
+	if len(out) > 5 {
+		out = out[:5]
+	}
+	return out
+}
+
+func meanStd(xs []float64) (mean, sd float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		sd += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sd / float64(len(xs)))
+}
+
+func median(xs []float64) float64 {
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func limit[T any](xs []T) []T {
+	if len(xs) > maxItems {
+		return xs[:maxItems]
+	}
+	return xs
+}
@@ -0,0 +1,209 @@
+package insights
+
+import (
+	"testing"
+	"time"
+)
+
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	return loc
+}
+
+func TestAnomalies(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	ago := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+	receipts := []receipt{
+		{ID: 1, Merchant: "Migros", Amount: 100, CreatedAt: ago(60)},
+		{ID: 2, Merchant: "migros ", Amount: 110, CreatedAt: ago(50)},
+		{ID: 3, Merchant: "Shell", Amount: 50, CreatedAt: ago(45)},
+		{ID: 4, Merchant: "Migros", Amount: 90, CreatedAt: ago(40)},
+		{ID: 5, Merchant: "Shell", Amount: 50, CreatedAt: ago(35)},
+		{ID: 6, Merchant: "Shell", Amount: 50, CreatedAt: ago(33)},
+		{ID: 7, Merchant: "Migros", Amount: 140, CreatedAt: ago(32)}, // outside the window, joins the baseline
+		{ID: 8, Merchant: "Cafe", Amount: 5, CreatedAt: ago(20)},
+		{ID: 9, Merchant: "Cafe", Amount: 5, CreatedAt: ago(15)},
+		{ID: 10, Merchant: "Cafe", Amount: 50, CreatedAt: ago(10)}, // two earlier receipts are no baseline
+		{ID: 11, Merchant: "Shell", Amount: 70, CreatedAt: ago(5)},  // identical history: infinite z, but only 1.4x
+		{ID: 12, Merchant: "Migros", Amount: 300, CreatedAt: ago(3)},
+		{ID: 13, Merchant: "Shell", Amount: 110, CreatedAt: ago(1)},
+	}
+
+	got := anomalies(receipts, now)
+	if len(got) != 2 {
+		t.Fatalf("got %d anomalies %+v, want 2", len(got), got)
+	}
+	// newest first
+	shell, migros := got[0], got[1]
+	if shell.ReceiptID != 13 || shell.Typical != 55 || shell.Ratio != 2 {
+		t.Errorf("shell: %+v", shell)
+	}
+	if migros.ReceiptID != 12 || migros.Typical != 110 || migros.Ratio != 2.73 || migros.ZScore < anomalyMinZ {
+		t.Errorf("migros: %+v", migros)
+	}
+
+	flat := []receipt{
+		{ID: 1, Merchant: "Bakery", Amount: 4, CreatedAt: ago(9)},
+		{ID: 2, Merchant: "Bakery", Amount: 4, CreatedAt: ago(8)},
+		{ID: 3, Merchant: "Bakery", Amount: 4, CreatedAt: ago(7)},
+		{ID: 4, Merchant: "Bakery", Amount: 12, CreatedAt: ago(6)},
+	}
+	if got := anomalies(flat, now); len(got) != 1 || got[0].ZScore != 99 {
+		t.Fatalf("no spread before: got %+v, want one anomaly with z capped at 99", got)
+	}
+	if got := anomalies(nil, now); got == nil || len(got) != 0 {
+		t.Fatalf("no receipts: got %#v, want an empty list", got)
+	}
+}
+
+func TestRecurring(t *testing.T) {
+	loc := berlin(t)
+	at := func(m time.Month, d, h int) time.Time { return time.Date(2026, m, d, h, 0, 0, 0, loc) }
+	receipts := []receipt{
+		{Merchant: "Netflix", Category: "Entertainment", Amount: 12, CreatedAt: at(1, 15, 8)},
+		{Merchant: "Bazaar", Amount: 30, CreatedAt: at(1, 20, 10)}, // gaps of 2, 20 and 5 days
+		{Merchant: "Bazaar", Amount: 30, CreatedAt: at(1, 22, 10)},
+		{Merchant: "netflix", Category: "Entertainment", Amount: 14, CreatedAt: at(2, 15, 8)},
+		{Merchant: "Bazaar", Amount: 30, CreatedAt: at(2, 11, 10)},
+		{Merchant: "Bazaar", Amount: 30, CreatedAt: at(2, 16, 10)},
+		{Merchant: "Netflix", Category: "Entertainment", Amount: 14, CreatedAt: at(3, 15, 8)},
+		// Saturdays either side of the spring DST change, one day twice
+		{Merchant: "Gym", Category: "Sport", Amount: 10, CreatedAt: at(3, 21, 9)},
+		{Merchant: "Gym", Category: "Sport", Amount: 10, CreatedAt: at(3, 28, 9)},
+		{Merchant: "Gym", Category: "Sport", Amount: 10, CreatedAt: at(3, 28, 19)},
+		{Merchant: "Gym", Category: "Sport", Amount: 10, CreatedAt: at(4, 4, 9)},
+		{Merchant: "Netflix", Category: "Entertainment", Amount: 14, CreatedAt: at(4, 15, 8)},
+		{Merchant: "Kiosk", Amount: 3, CreatedAt: at(4, 1, 8)}, // two visits are no pattern yet
+		{Merchant: "Kiosk", Amount: 3, CreatedAt: at(4, 8, 8)},
+	}
+
+	got := recurring(receipts, loc)
+	if len(got) != 2 {
+		t.Fatalf("got %d recurring %+v, want Netflix and the gym", len(got), got)
+	}
+	netflix, gym := got[0], got[1] // by average amount
+	if netflix.Merchant != "Netflix" || netflix.Cadence != "monthly" || netflix.Occurrences != 4 || netflix.AverageAmount != 13.5 {
+		t.Errorf("netflix: %+v", netflix)
+	}
+	if !netflix.LastAt.Equal(at(4, 15, 0)) || !netflix.NextExpected.Equal(at(4, 15, 0).AddDate(0, 0, netflix.IntervalDays)) {
+		t.Errorf("netflix dates: last %s next %s", netflix.LastAt, netflix.NextExpected)
+	}
+	// the DST week is an hour short but still rounds to seven days
+	if gym.Merchant != "Gym" || gym.Cadence != "weekly" || gym.IntervalDays != 7 || gym.Occurrences != 3 || gym.AverageAmount != 10 {
+		t.Errorf("gym: %+v", gym)
+	}
+}
+
+func TestRecurringNeedsEveryRecentGapSteady(t *testing.T) {
+	loc := berlin(t)
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 12, 0, 0, 0, loc) }
+	// weekly since February after one visit in January: the 31-day gap is
+	// among the latest six and breaks the run
+	receipts := []receipt{{Merchant: "Gym", CreatedAt: day(1, 4)}}
+	for d := 4; d <= 25; d += 7 {
+		receipts = append(receipts, receipt{Merchant: "Gym", CreatedAt: day(2, d)})
+	}
+	if got := recurring(receipts, loc); len(got) != 0 {
+		t.Fatalf("got %+v", got)
+	}
+	// three more weeks push the January gap out of the window
+	for d := 4; d <= 18; d += 7 {
+		receipts = append(receipts, receipt{Merchant: "Gym", CreatedAt: day(3, d)})
+	}
+	got := recurring(receipts, loc)
+	if len(got) != 1 || got[0].Cadence != "weekly" || got[0].Occurrences != 8 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestCadence(t *testing.T) {
+	for days, want := range map[float64]string{
+		1: "every_n_days", 7: "weekly", 8: "weekly", 10: "every_n_days",
+		14: "biweekly", 28: "monthly", 31: "monthly", 60: "every_n_days",
+	} {
+		if got := cadence(days); got != want {
+			t.Errorf("cadence(%v) = %s, want %s", days, got, want)
+		}
+	}
+}
+
+func TestCategoryShifts(t *testing.T) {
+	loc := berlin(t)
+	at := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 12, 0, 0, 0, loc) }
+	now := at(3, 10)
+	receipts := []receipt{
+		{Category: "Fuel", Amount: 80, CreatedAt: at(2, 3)},
+		{Category: "Market", Amount: 50, CreatedAt: at(2, 5)},
+		{Category: "Market", Amount: 500, CreatedAt: at(2, 20)}, // later in February than today is in March
+		{Category: "Market", Amount: 100, CreatedAt: at(3, 2)},
+		{Category: "Food", Amount: 30, CreatedAt: at(3, 3)},
+		{Category: "Food", Amount: 99, CreatedAt: at(3, 11)}, // after now
+	}
+
+	got := categoryShifts(receipts, now)
+	if len(got) != 3 {
+		t.Fatalf("got %d shifts %+v, want 3", len(got), got)
+	}
+	fuel, market, food := got[0], got[1], got[2] // largest change first
+	if fuel.Category != "Fuel" || fuel.Change != -80 || fuel.ChangePct == nil || *fuel.ChangePct != -100 || fuel.SharePrevious != 61.54 {
+		t.Errorf("fuel: %+v", fuel)
+	}
+	if market.Category != "Market" || market.Current != 100 || market.Previous != 50 || *market.ChangePct != 100 ||
+		market.ShareCurrent != 76.92 || market.SharePrevious != 38.46 {
+		t.Errorf("market: %+v", market)
+	}
+	if food.Category != "Food" || food.Current != 30 || food.ChangePct != nil || food.ShareCurrent != 23.08 {
+		t.Errorf("food: %+v", food)
+	}
+}
+
+func TestCategoryShiftsAtMonthEnd(t *testing.T) {
+	loc := berlin(t)
+	// 31 March reaches past the end of February; the previous stretch stops
+	// at 1 March rather than spilling into this month
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, loc)
+	receipts := []receipt{
+		{Category: "Market", Amount: 40, CreatedAt: time.Date(2026, 2, 28, 20, 0, 0, 0, loc)},
+		{Category: "Market", Amount: 60, CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, loc)},
+	}
+	got := categoryShifts(receipts, now)
+	if len(got) != 1 || got[0].Previous != 40 || got[0].Current != 60 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestTopEarners(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	ago := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+	receipts := []receipt{
+		{Merchant: "Migros", Amount: 1000, TokensEarned: 500, CreatedAt: ago(91)}, // outside the window
+		{Merchant: "Migros", Amount: 100, TokensEarned: 10, CreatedAt: ago(60)},
+		{Merchant: "migros", Amount: 50, TokensEarned: 10, CreatedAt: ago(2)},
+		{Merchant: "Shell", Amount: 40, TokensEarned: 8, CreatedAt: ago(1)},
+		{Merchant: "Apple", Amount: 30, TokensEarned: 8, CreatedAt: ago(1)},
+		{Merchant: "A", TokensEarned: 1, CreatedAt: ago(1)},
+		{Merchant: "B", Amount: 10, TokensEarned: 1, CreatedAt: ago(1)},
+		{Merchant: "C", Amount: 10, TokensEarned: 0.5, CreatedAt: ago(1)},
+	}
+
+	got := topEarners(receipts, now)
+	if len(got) != 5 {
+		t.Fatalf("got %d earners, want the top 5", len(got))
+	}
+	want := []Earner{
+		{Merchant: "Migros", TokensEarned: 20, Spent: 150, Receipts: 2, Rate: 0.13},
+		{Merchant: "Apple", TokensEarned: 8, Spent: 30, Receipts: 1, Rate: 0.27}, // ties by name
+		{Merchant: "Shell", TokensEarned: 8, Spent: 40, Receipts: 1, Rate: 0.2},
+		{Merchant: "A", TokensEarned: 1, Receipts: 1}, // nothing spent, no rate
+		{Merchant: "B", TokensEarned: 1, Spent: 10, Receipts: 1, Rate: 0.1},
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("earner %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
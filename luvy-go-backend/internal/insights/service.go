@@ -0,0 +1,199 @@
+// Package insights derives spending insights from a user's receipts:
+// unusual merchant spend, recurring purchases, month-over-month category
+// shifts and the merchants that earn the most LUVY. Reports are computed in
+// the background and cached per user.
+package insights
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/internal/streaks"
+	"luvy-go-backend/internal/timebucket"
+	"luvy-go-backend/src/models"
+)
+
+// HistoryMonths is how much receipt history a report looks at.
+const HistoryMonths = 12
+
+// MaxAge is how long a report stays fresh without new receipts; month
+// boundaries and the recent windows move even when nothing is bought.
+const MaxAge = 24 * time.Hour
+
+// BatchSize caps the reports one scheduler run recomputes.
+const BatchSize = 500
+
+type Report struct {
+	UserID         uint            `json:"user_id"`
+	TimeZone       string          `json:"time_zone"`
+	ComputedAt     time.Time       `json:"computed_at"`
+	Anomalies      []Anomaly       `json:"anomalies"`
+	Recurring      []Recurring     `json:"recurring"`
+	CategoryShifts []CategoryShift `json:"category_shifts"`
+	TopEarning     []Earner        `json:"top_earning"`
+}
+
+type Service struct {
+	DB *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+// Compute builds a fresh report for the user as of now.
+func (s *Service) Compute(userID uint, now time.Time) (Report, error) {
+	var user models.User
+	if err := s.DB.Select("id, time_zone").Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return Report{}, err
+	}
+	loc := streaks.Location(user.TimeZone)
+	now = now.In(loc)
+
+	history := timebucket.Range{From: timebucket.Month.Add(timebucket.Month.Truncate(now), -HistoryMonths), To: now.Add(time.Second)}
+	cond, args := timebucket.RangeCond(timebucket.DialectOf(s.DB), "created_at", history)
+	var receipts []receipt
+	err := s.DB.Model(&models.Receipt{}).
+		Select("id, merchant, category, amount, tokens_earned, created_at").
+		Where("user_id = ? AND status = ?", userID, "completed").Where(cond, args...).
+		Order("created_at, id").
+		Scan(&receipts).Error
+	if err != nil {
+		return Report{}, err
+	}
+	for i := range receipts {
+		receipts[i].CreatedAt = receipts[i].CreatedAt.In(loc)
+	}
+
+	return Report{
+		UserID:         userID,
+		TimeZone:       loc.String(),
+		ComputedAt:     now,
+		Anomalies:      anomalies(receipts, now),
+		Recurring:      recurring(receipts, loc),
+		CategoryShifts: categoryShifts(receipts, now),
+		TopEarning:     topEarners(receipts, now),
+	}, nil
+}
+
+// Get returns the cached report, computing and storing it first when it is
+// missing or older than MaxAge. The scheduler keeps this path rare.
+func (s *Service) Get(userID uint) (Report, error) {
+	var cached models.UserInsight
+	if err := s.DB.Where("user_id = ?", userID).Limit(1).Find(&cached).Error; err != nil {
+		return Report{}, err
+	}
+	if cached.ID != 0 && time.Since(cached.ComputedAt) < MaxAge {
+		var r Report
+		if err := json.Unmarshal([]byte(cached.Report), &r); err == nil {
+			return r, nil
+		}
+	}
+	return s.Refresh(userID)
+}
+
+// Refresh recomputes the user's report and replaces the cached one.
+func (s *Service) Refresh(userID uint) (Report, error) {
+	// read before the receipts, so a receipt committed meanwhile leaves the
+	// report marked stale rather than hidden
+	var version int64
+	if err := s.DB.Model(&models.UserInsight{}).Select("receipt_version").
+		Where("user_id = ?", userID).Limit(1).Scan(&version).Error; err != nil {
+		return Report{}, err
+	}
+
+	r, err := s.Compute(userID, time.Now())
+	if err != nil {
+		return r, err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return r, err
+	}
+
+	row := models.UserInsight{
+		UserID:          userID,
+		Report:          string(b),
+		ReceiptVersion:  version,
+		ComputedVersion: version,
+		ComputedAt:      r.ComputedAt,
+	}
+	err = s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"report", "computed_version", "computed_at"}),
+	}).Create(&row).Error
+	return r, err
+}
+
+// OnReceiptApproved marks the owner's report stale, in the receipt's
+// transaction.
+func (s *Service) OnReceiptApproved(tx *gorm.DB, r *models.Receipt) error {
+	return s.markStale(tx, r.UserID)
+}
+
+// OnReceiptDeleted marks the owner's report stale, so deleting a user's
+// last receipt clears it too.
+func (s *Service) OnReceiptDeleted(tx *gorm.DB, r *models.Receipt) error {
+	return s.markStale(tx, r.UserID)
+}
+
+// markStale bumps the user's receipt version, creating an empty report row
+// for the scheduler to fill when there is none yet.
+func (s *Service) markStale(tx *gorm.DB, userID uint) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"receipt_version": gorm.Expr("user_insights.receipt_version + 1")}),
+	}).Create(&models.UserInsight{UserID: userID, ReceiptVersion: 1}).Error
+}
+
+// RefreshStale recomputes up to BatchSize reports, oldest first: those whose
+// receipts changed since they were computed and those older than MaxAge.
+// It returns how many were refreshed.
+func (s *Service) RefreshStale(ctx context.Context) (int, error) {
+	var users []uint
+	err := s.DB.WithContext(ctx).Model(&models.UserInsight{}).
+		Where("computed_version < receipt_version OR computed_at < ?", time.Now().Add(-MaxAge)).
+		Order("computed_at").Limit(BatchSize).
+		Pluck("user_id", &users).Error
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	var errs []error
+	for _, id := range users {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := s.Refresh(id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// Run refreshes stale reports every interval until ctx is done.
+func (s *Service) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		if n, err := s.RefreshStale(ctx); err != nil {
+			log.Println("insights refresh failed:", err)
+		} else if n > 0 {
+			log.Printf("insights: %d reports refreshed", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package insights
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"luvy-go-backend/src/models"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "insights.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Receipt{}, &models.UserInsight{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db)
+}
+
+func createUser(t *testing.T, s *Service, email string) models.User {
+	t.Helper()
+	u := models.User{Name: "Test", Email: email}
+	if err := s.DB.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// submit stores a receipt the way the receipt handler does.
+func submit(t *testing.T, s *Service, userID uint, merchant string, amount float64) models.Receipt {
+	t.Helper()
+	r := models.Receipt{UserID: userID, Merchant: merchant, Category: "Market", Amount: amount, TokensEarned: amount / 10, Status: "completed", CreatedAt: time.Now()}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+		return s.OnReceiptApproved(tx, &r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func remove(t *testing.T, s *Service, r models.Receipt) {
+	t.Helper()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.OnReceiptDeleted(tx, &r); err != nil {
+			return err
+		}
+		return tx.Delete(&r).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func refreshStale(t *testing.T, s *Service) int {
+	t.Helper()
+	n, err := s.RefreshStale(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func cached(t *testing.T, s *Service, userID uint) models.UserInsight {
+	t.Helper()
+	var row models.UserInsight
+	if err := s.DB.Where("user_id = ?", userID).First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestRefreshStaleOnlyRecomputesChangedReports(t *testing.T) {
+	s := newTestService(t)
+	ana := createUser(t, s, "ana@example.com")
+	bo := createUser(t, s, "bo@example.com")
+	idle := createUser(t, s, "idle@example.com")
+
+	submit(t, s, ana.ID, "Migros", 100)
+	submit(t, s, bo.ID, "Shell", 50)
+	if _, err := s.Get(idle.ID); err != nil { // a report without receipts
+		t.Fatal(err)
+	}
+	if n := refreshStale(t, s); n != 2 {
+		t.Fatalf("refreshed %d reports, want ana's and bo's", n)
+	}
+	if n := refreshStale(t, s); n != 0 {
+		t.Fatalf("refreshed %d unchanged reports", n)
+	}
+
+	submit(t, s, ana.ID, "Migros", 80)
+	before := cached(t, s, bo.ID).ComputedAt
+	if n := refreshStale(t, s); n != 1 {
+		t.Fatalf("refreshed %d reports, want ana's", n)
+	}
+	if !cached(t, s, bo.ID).ComputedAt.Equal(before) {
+		t.Fatal("bo's report recomputed without a change")
+	}
+	row := cached(t, s, ana.ID)
+	if row.ReceiptVersion != 2 || row.ComputedVersion != 2 {
+		t.Fatalf("versions %d/%d, want 2/2", row.ReceiptVersion, row.ComputedVersion)
+	}
+	r, err := s.Get(ana.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.TopEarning) != 1 || r.TopEarning[0].Receipts != 2 {
+		t.Fatalf("report misses the new receipt: %+v", r.TopEarning)
+	}
+}
+
+func TestDeletingTheLastReceiptClearsTheReport(t *testing.T) {
+	s := newTestService(t)
+	ana := createUser(t, s, "ana@example.com")
+	r := submit(t, s, ana.ID, "Migros", 100)
+	refreshStale(t, s)
+
+	remove(t, s, r)
+	if n := refreshStale(t, s); n != 1 {
+		t.Fatalf("refreshed %d reports after the last receipt was deleted, want 1", n)
+	}
+	got, err := s.Get(ana.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.TopEarning) != 0 || len(got.CategoryShifts) != 0 {
+		t.Fatalf("deleted receipt still reported: %+v", got)
+	}
+}
+
+func TestRefreshStaleRecomputesOldReports(t *testing.T) {
+	s := newTestService(t)
+	ana := createUser(t, s, "ana@example.com")
+	submit(t, s, ana.ID, "Migros", 100)
+	refreshStale(t, s)
+
+	s.DB.Model(&models.UserInsight{}).Where("user_id = ?", ana.ID).Update("computed_at", time.Now().Add(-MaxAge-time.Minute))
+	if n := refreshStale(t, s); n != 1 {
+		t.Fatalf("refreshed %d reports older than MaxAge, want 1", n)
+	}
+	if time.Since(cached(t, s, ana.ID).ComputedAt) > time.Minute {
+		t.Fatal("report not recomputed")
+	}
+}
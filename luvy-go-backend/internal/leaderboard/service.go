@@ -0,0 +1,293 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/src/models"
+)
+
+const (
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+	PeriodAllTime = "all_time"
+)
+
+const (
+	MetricLuvy     = "luvy"
+	MetricReceipts = "receipts"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+var ErrInvalidQuery = errors.New("invalid leaderboard query")
+
+var periods = []string{PeriodWeekly, PeriodMonthly, PeriodAllTime}
+
+// scoreColumns whitelists the metrics that can be ranked by.
+var scoreColumns = map[string]string{
+	MetricLuvy:     "e.luvy_earned",
+	MetricReceipts: "e.receipts",
+}
+
+type Service struct {
+	DB *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+// PeriodKey names the bucket t falls into, e.g. "2026-W07" or "2026-02".
+func PeriodKey(period string, t time.Time) string {
+	t = t.UTC()
+	switch period {
+	case PeriodWeekly:
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	case PeriodMonthly:
+		return t.Format("2006-01")
+	default:
+		return "all"
+	}
+}
+
+// OnReceiptApproved adds the receipt to every board it belongs to.
+func (s *Service) OnReceiptApproved(tx *gorm.DB, receipt *models.Receipt) error {
+	return s.apply(tx, receipt, 1)
+}
+
+// OnReceiptDeleted takes a previously counted receipt back out again.
+func (s *Service) OnReceiptDeleted(tx *gorm.DB, receipt *models.Receipt) error {
+	return s.apply(tx, receipt, -1)
+}
+
+func (s *Service) apply(tx *gorm.DB, receipt *models.Receipt, sign int) error {
+	at := receipt.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	categories := []string{""}
+	if receipt.Category != "" {
+		categories = append(categories, receipt.Category)
+	}
+
+	for _, period := range periods {
+		for _, category := range categories {
+			entry := models.LeaderboardEntry{
+				Period:     period,
+				PeriodKey:  PeriodKey(period, at),
+				Category:   category,
+				UserID:     receipt.UserID,
+				LuvyEarned: float64(sign) * receipt.TokensEarned,
+				Receipts:   sign,
+			}
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "period"}, {Name: "period_key"}, {Name: "category"}, {Name: "user_id"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"luvy_earned": gorm.Expr("leaderboard_entries.luvy_earned + excluded.luvy_earned"),
+					"receipts":    gorm.Expr("leaderboard_entries.receipts + excluded.receipts"),
+					"updated_at":  gorm.Expr("excluded.updated_at"),
+				}),
+			}).Create(&entry).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type Query struct {
+	Period   string
+	Metric   string
+	Category string
+	City     string
+	Limit    int
+	At       time.Time
+}
+
+type Row struct {
+	Rank        int     `json:"rank"`
+	DisplayName string  `json:"display_name"`
+	LuvyEarned  float64 `json:"luvy_earned"`
+	Receipts    int     `json:"receipts"`
+	IsMe        bool    `json:"is_me"`
+}
+
+type Board struct {
+	Period    string `json:"period"`
+	PeriodKey string `json:"period_key"`
+	Metric    string `json:"metric"`
+	Category  string `json:"category,omitempty"`
+	City      string `json:"city,omitempty"`
+	Top       []Row  `json:"top"`
+	Me        *Row   `json:"me"`
+	OptedOut  bool   `json:"opted_out"`
+}
+
+type scored struct {
+	UserID     uint
+	Name       string
+	LuvyEarned float64
+	Receipts   int
+	OptOut     bool
+}
+
+// Board returns the top N for q and, separately, where userID stands.
+func (s *Service) Board(q Query, userID uint) (Board, error) {
+	if q.Period == "" {
+		q.Period = PeriodWeekly
+	}
+	if q.Metric == "" {
+		q.Metric = MetricLuvy
+	}
+	score, ok := scoreColumns[q.Metric]
+	if !ok || (q.Period != PeriodWeekly && q.Period != PeriodMonthly && q.Period != PeriodAllTime) {
+		return Board{}, ErrInvalidQuery
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	if q.At.IsZero() {
+		q.At = time.Now()
+	}
+
+	out := Board{
+		Period:    q.Period,
+		PeriodKey: PeriodKey(q.Period, q.At),
+		Metric:    q.Metric,
+		Category:  q.Category,
+		City:      q.City,
+		Top:       []Row{},
+	}
+
+	base := func() *gorm.DB {
+		db := s.DB.Table("leaderboard_entries AS e").
+			Joins("JOIN users u ON u.id = e.user_id").
+			Where("e.period = ? AND e.period_key = ? AND e.category = ?", out.Period, out.PeriodKey, q.Category).
+			Where(score + " > 0")
+		if q.City != "" {
+			db = db.Where("LOWER(u.city) = LOWER(?)", q.City)
+		}
+		return db
+	}
+
+	var top []scored
+	err := base().
+		Select("e.user_id, u.name, e.luvy_earned, e.receipts").
+		Where("u.leaderboard_opt_out = ?", false).
+		Order(score + " DESC, e.user_id ASC").
+		Limit(q.Limit).
+		Scan(&top).Error
+	if err != nil {
+		return out, err
+	}
+	for i, r := range top {
+		out.Top = append(out.Top, Row{
+			Rank:        i + 1,
+			DisplayName: MaskName(r.Name),
+			LuvyEarned:  r.LuvyEarned,
+			Receipts:    r.Receipts,
+			IsMe:        r.UserID == userID,
+		})
+	}
+
+	var mine []scored
+	if err := base().
+		Select("e.user_id, u.name, e.luvy_earned, e.receipts, u.leaderboard_opt_out AS opt_out").
+		Where("e.user_id = ?", userID).
+		Limit(1).
+		Scan(&mine).Error; err != nil {
+		return out, err
+	}
+	if len(mine) == 0 {
+		return out, nil
+	}
+	me := mine[0]
+	if me.OptOut {
+		out.OptedOut = true
+		return out, nil
+	}
+
+	myScore := me.LuvyEarned
+	if q.Metric == MetricReceipts {
+		myScore = float64(me.Receipts)
+	}
+	var ahead int64
+	if err := base().
+		Where("u.leaderboard_opt_out = ?", false).
+		Where("("+score+" > ? OR ("+score+" = ? AND e.user_id < ?))", myScore, myScore, userID).
+		Count(&ahead).Error; err != nil {
+		return out, err
+	}
+	out.Me = &Row{
+		Rank:        int(ahead) + 1,
+		DisplayName: MaskName(me.Name),
+		LuvyEarned:  me.LuvyEarned,
+		Receipts:    me.Receipts,
+		IsMe:        true,
+	}
+	return out, nil
+}
+
+// SetOptOut hides or shows a user on every leaderboard.
+func (s *Service) SetOptOut(userID uint, optOut bool) error {
+	return s.DB.Model(&models.User{}).Where("id = ?", userID).Update("leaderboard_opt_out", optOut).Error
+}
+
+// BackfillIfEmpty seeds the boards from existing receipts the first time the
+// table is created. Afterwards the boards are only maintained incrementally.
+// It runs in one transaction: a partial backfill would leave the table
+// non-empty and never be finished.
+func (s *Service) BackfillIfEmpty() error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&models.LeaderboardEntry{}).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+
+		var batch []models.Receipt
+		return tx.Where("status = ?", "completed").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			// a fresh statement on the same transaction, without the batch query's conditions
+			tx = tx.Session(&gorm.Session{NewDB: true})
+			for i := range batch {
+				if err := s.apply(tx, &batch[i], 1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	})
+}
+
+// MaskName shortens "Begüm Doğan" to "Begüm D." and single names to "B***".
+func MaskName(name string) string {
+	parts := strings.Fields(name)
+	switch len(parts) {
+	case 0:
+		return "LUVY user"
+	case 1:
+		r, _ := utf8.DecodeRuneInString(parts[0])
+		return string(r) + "***"
+	default:
+		r, _ := utf8.DecodeRuneInString(parts[len(parts)-1])
+		return parts[0] + " " + string(r) + "."
+	}
+}
@@ -0,0 +1,166 @@
+package leaderboard
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"luvy-go-backend/src/models"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "leaderboard.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Receipt{}, &models.LeaderboardEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db)
+}
+
+func entry(t *testing.T, s *Service, period, key, category string, userID uint) models.LeaderboardEntry {
+	t.Helper()
+	var e models.LeaderboardEntry
+	s.DB.Where("period = ? AND period_key = ? AND category = ? AND user_id = ?", period, key, category, userID).Limit(1).Find(&e)
+	return e
+}
+
+func TestPeriodKey(t *testing.T) {
+	cases := []struct {
+		period string
+		at     time.Time
+		want   string
+	}{
+		{PeriodWeekly, time.Date(2026, 2, 11, 12, 0, 0, 0, time.UTC), "2026-W07"},
+		// ISO weeks: Jan 1st 2027 is a Friday and still in 2026's last week
+		{PeriodWeekly, time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC), "2026-W53"},
+		{PeriodWeekly, time.Date(2025, 12, 29, 0, 0, 0, 0, time.UTC), "2026-W01"},
+		{PeriodMonthly, time.Date(2026, 2, 28, 23, 59, 0, 0, time.UTC), "2026-02"},
+		// keys are UTC: 00:30 in Berlin on March 1st is still February
+		{PeriodMonthly, time.Date(2026, 3, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600)), "2026-02"},
+		{PeriodAllTime, time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC), "all"},
+	}
+	for _, c := range cases {
+		if got := PeriodKey(c.period, c.at); got != c.want {
+			t.Errorf("PeriodKey(%s, %v) = %s, want %s", c.period, c.at, got, c.want)
+		}
+	}
+}
+
+func TestApprovedAndDeletedReceiptsMoveEveryBucket(t *testing.T) {
+	s := newTestService(t)
+	at := time.Date(2026, 2, 11, 12, 0, 0, 0, time.UTC)
+	first := models.Receipt{UserID: 1, Category: "food", TokensEarned: 10, CreatedAt: at}
+	second := models.Receipt{UserID: 1, Category: "food", TokensEarned: 5, CreatedAt: at}
+
+	for _, r := range []*models.Receipt{&first, &second} {
+		if err := s.OnReceiptApproved(s.DB, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var n int64
+	s.DB.Model(&models.LeaderboardEntry{}).Count(&n)
+	if n != 6 {
+		t.Fatalf("%d entries, want one per period for the overall and the category board", n)
+	}
+	for _, period := range periods {
+		for _, category := range []string{"", "food"} {
+			e := entry(t, s, period, PeriodKey(period, at), category, 1)
+			if e.Receipts != 2 || e.LuvyEarned != 15 {
+				t.Errorf("%s/%q after two receipts: %d receipts, %v luvy", period, category, e.Receipts, e.LuvyEarned)
+			}
+		}
+	}
+
+	if err := s.OnReceiptDeleted(s.DB, &first); err != nil {
+		t.Fatal(err)
+	}
+	if e := entry(t, s, PeriodWeekly, "2026-W07", "food", 1); e.Receipts != 1 || e.LuvyEarned != 5 {
+		t.Fatalf("after deleting one: %d receipts, %v luvy", e.Receipts, e.LuvyEarned)
+	}
+}
+
+func TestBackfillSeedsCompletedReceiptsOnce(t *testing.T) {
+	s := newTestService(t)
+	at := time.Date(2026, 2, 11, 12, 0, 0, 0, time.UTC)
+	receipts := []models.Receipt{
+		{UserID: 1, Category: "food", TokensEarned: 10, Status: "completed", CreatedAt: at},
+		{UserID: 1, Category: "fashion", TokensEarned: 4, Status: "completed", CreatedAt: at.AddDate(0, -1, 0)},
+		{UserID: 2, Category: "food", TokensEarned: 7, Status: "completed", CreatedAt: at},
+		{UserID: 2, Category: "food", TokensEarned: 99, Status: "pending", CreatedAt: at},
+	}
+	if err := s.DB.Create(&receipts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.BackfillIfEmpty(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		period, key, category string
+		userID                uint
+		receipts              int
+		luvy                  float64
+	}{
+		{PeriodAllTime, "all", "", 1, 2, 14},
+		{PeriodMonthly, "2026-02", "", 1, 1, 10},
+		{PeriodMonthly, "2026-01", "fashion", 1, 1, 4},
+		{PeriodWeekly, "2026-W07", "food", 2, 1, 7},
+	}
+	for _, c := range cases {
+		e := entry(t, s, c.period, c.key, c.category, c.userID)
+		if e.Receipts != c.receipts || e.LuvyEarned != c.luvy {
+			t.Errorf("%s %s %q user %d: %d receipts, %v luvy; want %d, %v", c.period, c.key, c.category, c.userID, e.Receipts, e.LuvyEarned, c.receipts, c.luvy)
+		}
+	}
+}
+
+func TestBoardRanksAndHonoursOptOut(t *testing.T) {
+	s := newTestService(t)
+	at := time.Date(2026, 2, 11, 12, 0, 0, 0, time.UTC)
+	users := []models.User{
+		{Name: "Begüm Doğan", Email: "b@example.com"},
+		{Name: "Cem", Email: "c@example.com"},
+		{Name: "Deniz Ak", Email: "d@example.com", LeaderboardOptOut: true},
+	}
+	if err := s.DB.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i, luvy := range []float64{10, 20, 30} {
+		if err := s.OnReceiptApproved(s.DB, &models.Receipt{UserID: users[i].ID, TokensEarned: luvy, CreatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := s.Board(Query{Period: PeriodWeekly, At: at}, users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Top) != 2 || b.Top[0].DisplayName != "C***" || b.Top[1].DisplayName != "Begüm D." {
+		t.Fatalf("top %+v", b.Top)
+	}
+	if b.Me == nil || b.Me.Rank != 2 || !b.Top[1].IsMe {
+		t.Fatalf("me %+v", b.Me)
+	}
+
+	hidden, err := s.Board(Query{Period: PeriodWeekly, At: at}, users[2].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hidden.OptedOut || hidden.Me != nil {
+		t.Fatalf("opted-out user sees %+v", hidden)
+	}
+
+	if _, err := s.Board(Query{Metric: "spend"}, users[0].ID); err != ErrInvalidQuery {
+		t.Fatalf("unknown metric: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package ledger
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"luvy-go-backend/src/models"
+)
+
+const (
+	TypeEarn     = "earn"
+	TypeBonus    = "bonus"
+	TypeReversal = "reversal"
+)
+
+// Credit writes a ledger entry and adds its amount to the user's balance.
+// Callers pass a transaction so the entry and the balance move together.
+func Credit(db *gorm.DB, entry *models.Transaction) error {
+	if entry.UserID == 0 {
+		return errors.New("ledger: user id is required")
+	}
+	if entry.Amount <= 0 {
+		return errors.New("ledger: amount must be positive")
+	}
+	if entry.Type == "" {
+		entry.Type = TypeBonus
+	}
+
+	if err := db.Model(&models.User{}).Where("id = ?", entry.UserID).
+		Update("luvy_balance", gorm.Expr("luvy_balance + ?", entry.Amount)).Error; err != nil {
+		return err
+	}
+	return db.Create(entry).Error
+}
+
+// Debit takes entry.Amount off the user's balance and records it as a
+// negative entry, so a user's entries always sum to their balance. Amount is
+// passed positive.
+func Debit(db *gorm.DB, entry *models.Transaction) error {
+	if entry.UserID == 0 {
+		return errors.New("ledger: user id is required")
+	}
+	if entry.Amount <= 0 {
+		return errors.New("ledger: amount must be positive")
+	}
+	if entry.Type == "" {
+		entry.Type = TypeReversal
+	}
+
+	if err := db.Model(&models.User{}).Where("id = ?", entry.UserID).
+		Update("luvy_balance", gorm.Expr("luvy_balance - ?", entry.Amount)).Error; err != nil {
+		return err
+	}
+	entry.Amount = -entry.Amount
+	return db.Create(entry).Error
+}
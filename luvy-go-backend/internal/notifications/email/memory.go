@@ -0,0 +1,52 @@
+package email
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Sent is one message accepted by Memory, with the bytes that would have
+// gone over the wire.
+type Sent struct {
+	Message Message
+	Raw     []byte
+}
+
+// Memory is an in-process Mailer for tests and local runs. Err, when set,
+// fails every send.
+type Memory struct {
+	From string
+	Err  error
+
+	mu   sync.Mutex
+	sent []Sent
+}
+
+func NewMemory(from string) *Memory {
+	return &Memory{From: from}
+}
+
+func (m *Memory) Send(_ context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	raw, err := Build(msg, time.Now())
+	if err != nil {
+		return err
+	}
+	m.sent = append(m.sent, Sent{Message: *msg, Raw: raw})
+	return nil
+}
+
+// Messages returns a copy of everything sent so far.
+func (m *Memory) Messages() []Sent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Sent(nil), m.sent...)
+}
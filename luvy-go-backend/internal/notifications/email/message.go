@@ -0,0 +1,203 @@
+﻿package email
+
+import (
+"bytes"
+"crypto/rand"
+"encoding/base64"
+"encoding/hex"
+"errors"
+"fmt"
+"io"
+"mime"
+"mime/multipart"
+"mime/quotedprintable"
+"net/mail"
+"net/textproto"
+"sort"
+"strings"
+"time"
+)
+
+type Attachment struct {
+Filename    string
+ContentType string
+Data        []byte
+}
+
+// Message is a complete outgoing mail. From may be left empty to use the
+// transport's default sender.
+type Message struct {
+From        string
+To          []string
+ReplyTo     string
+Subject     string
+Text        string
+HTML        string
+Headers     map[string]string
+Attachments []Attachment
+}
+
+// Message turns a rendered template into a Message for one recipient.
+func (r Rendered) Message(to string) *Message {
+return &Message{To: []string{to}, Subject: r.Subject, Text: r.Text, HTML: r.HTML}
+}
+
+var ErrNoRecipients = errors.New("email: message has no recipients")
+
+// Build serialises m as RFC 5322 with MIME bodies:
+// multipart/mixed (when there are attachments) around multipart/alternative
+// (when both text and HTML are present). Non-ASCII header text is RFC 2047
+// encoded; bodies are quoted-printable, attachments base64.
+func Build(m *Message, now time.Time) ([]byte, error) {
+if len(m.To) == 0 {
+return nil, ErrNoRecipients
+}
+from, err := mail.ParseAddress(m.From)
+if err != nil {
+return nil, fmt.Errorf("email: from: %w", err)
+}
+to := make([]string, 0, len(m.To))
+for _, a := range m.To {
+addr, err := mail.ParseAddress(a)
+if err != nil {
+return nil, fmt.Errorf("email: to: %w", err)
+}
+to = append(to, addr.String())
+}
+
+var buf bytes.Buffer
+h := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+
+h("From", from.String())
+h("To", strings.Join(to, ", "))
+if m.ReplyTo != "" {
+if rt, err := mail.ParseAddress(m.ReplyTo); err == nil {
+h("Reply-To", rt.String())
+}
+}
+h("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+h("Date", now.Format(time.RFC1123Z))
+h("Message-ID", messageID(from.Address))
+h("MIME-Version", "1.0")
+
+keys := make([]string, 0, len(m.Headers))
+for k := range m.Headers {
+keys = append(keys, k)
+}
+sort.Strings(keys)
+for _, k := range keys {
+h(textproto.CanonicalMIMEHeaderKey(k), mime.QEncoding.Encode("utf-8", m.Headers[k]))
+}
+
+if len(m.Attachments) == 0 {
+if err := writeBody(&buf, m); err != nil {
+return nil, err
+}
+return buf.Bytes(), nil
+}
+
+mixed := multipart.NewWriter(&buf)
+h("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+buf.WriteString("\r\n")
+
+var body bytes.Buffer
+if err := writeBody(&body, m); err != nil {
+return nil, err
+}
+// writeBody emits its own part headers, so hand them over verbatim.
+hdr, rest, _ := bytes.Cut(body.Bytes(), []byte("\r\n\r\n"))
+pw, err := mixed.CreatePart(parseHeader(hdr))
+if err != nil {
+return nil, err
+}
+pw.Write(rest)
+
+for _, a := range m.Attachments {
+ct := a.ContentType
+if ct == "" {
+ct = "application/octet-stream"
+}
+ph := textproto.MIMEHeader{}
+ph.Set("Content-Type", mime.FormatMediaType(ct, map[string]string{"name": a.Filename}))
+ph.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+ph.Set("Content-Transfer-Encoding", "base64")
+pw, err := mixed.CreatePart(ph)
+if err != nil {
+return nil, err
+}
+writeBase64(pw, a.Data)
+}
+if err := mixed.Close(); err != nil {
+return nil, err
+}
+return buf.Bytes(), nil
+}
+
+// writeBody writes a self-contained entity: Content-Type header(s), a blank
+// line and the text and/or HTML content.
+func writeBody(w *bytes.Buffer, m *Message) error {
+switch {
+case m.Text != "" && m.HTML != "":
+alt := multipart.NewWriter(w)
+fmt.Fprintf(w, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", alt.Boundary())
+for _, p := range []struct{ ct, body string }{
+{"text/plain; charset=UTF-8", m.Text},
+{"text/html; charset=UTF-8", m.HTML},
+} {
+pw, err := alt.CreatePart(textproto.MIMEHeader{
+"Content-Type":              {p.ct},
+"Content-Transfer-Encoding": {"quoted-printable"},
+})
+if err != nil {
+return err
+}
+if err := writeQP(pw, p.body); err != nil {
+return err
+}
+}
+return alt.Close()
+case m.HTML != "":
+w.WriteString("Content-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+return writeQP(w, m.HTML)
+default:
+w.WriteString("Content-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+return writeQP(w, m.Text)
+}
+}
+
+func writeQP(w io.Writer, s string) error {
+qp := quotedprintable.NewWriter(w)
+if _, err := qp.Write([]byte(s)); err != nil {
+return err
+}
+return qp.Close()
+}
+
+func writeBase64(w io.Writer, data []byte) {
+enc := base64.StdEncoding.EncodeToString(data)
+for len(enc) > 76 {
+io.WriteString(w, enc[:76]+"\r\n")
+enc = enc[76:]
+}
+io.WriteString(w, enc+"\r\n")
+}
+
+func parseHeader(raw []byte) textproto.MIMEHeader {
+out := textproto.MIMEHeader{}
+for _, line := range strings.Split(string(raw), "\r\n") {
+if k, v, ok := strings.Cut(line, ":"); ok {
+out.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+}
+}
+return out
+}
+
+func messageID(from string) string {
+domain := "localhost"
+if i := strings.LastIndex(from, "@"); i >= 0 {
+domain = from[i+1:]
+}
+b := make([]byte, 16)
+_, _ = rand.Read(b)
+return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,140 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readPart(t *testing.T, p *multipart.Part) string {
+	t.Helper()
+	b, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestBuildEncodesHeadersAndNestsParts(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pdf := bytes.Repeat([]byte("%PDF-1.7 "), 40)
+	raw, err := Build(&Message{
+		From:    "LUVY <noreply@luvy.app>",
+		To:      []string{"begum@example.com"},
+		Subject: "Hoş geldin, Begüm!",
+		Text:    "Merhaba Begüm",
+		HTML:    "<p>Merhaba Begüm</p>",
+		Attachments: []Attachment{
+			{Filename: "ekstre.pdf", ContentType: "application/pdf", Data: pdf},
+		},
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := msg.Header.Get("Subject"); !strings.HasPrefix(s, "=?utf-8?q?") {
+		t.Fatalf("subject not RFC 2047 encoded: %q", s)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Hoş geldin, Begüm!" {
+		t.Fatalf("subject decodes to %q %v", subject, err)
+	}
+	if d, err := msg.Header.Date(); err != nil || !d.Equal(now) {
+		t.Fatalf("date %v %v", d, err)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@luvy.app>") {
+		t.Fatalf("message id %q", id)
+	}
+
+	mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/mixed" {
+		t.Fatalf("content type %s %v", mt, err)
+	}
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+
+	body, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt, params, err = mime.ParseMediaType(body.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/alternative" {
+		t.Fatalf("body type %s %v", mt, err)
+	}
+	alt := multipart.NewReader(body, params["boundary"])
+	for _, want := range []struct{ ct, body string }{
+		{"text/plain; charset=UTF-8", "Merhaba Begüm"},
+		{"text/html; charset=UTF-8", "<p>Merhaba Begüm</p>"},
+	} {
+		p, err := alt.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// multipart.Reader undoes the quoted-printable encoding
+		if ct, got := p.Header.Get("Content-Type"), readPart(t, p); ct != want.ct || got != want.body {
+			t.Fatalf("alternative part %s %q, want %s %q", ct, got, want.ct, want.body)
+		}
+	}
+
+	att, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.FileName() != "ekstre.pdf" {
+		t.Fatalf("attachment filename %q", att.FileName())
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(readPart(t, att), "\r\n", ""))
+	if err != nil || !bytes.Equal(data, pdf) {
+		t.Fatalf("attachment does not round-trip: %v", err)
+	}
+	if _, err := mixed.NextPart(); err != io.EOF {
+		t.Fatalf("extra part after the attachment: %v", err)
+	}
+}
+
+func TestBuildRejectsMessageWithoutRecipients(t *testing.T) {
+	if _, err := Build(&Message{From: "noreply@luvy.app", Text: "x"}, time.Now()); !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("got %v, want ErrNoRecipients", err)
+	}
+}
+
+func TestMemoryKeepsWhatWouldHaveBeenSent(t *testing.T) {
+	r, err := Render(WelcomeV1, "tr", Templates()[WelcomeV1].Sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory("LUVY <noreply@luvy.app>")
+	if err := m.Send(context.Background(), r.Message("begum@example.com")); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := m.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("%d messages, want 1", len(sent))
+	}
+	if sent[0].Message.From != "LUVY <noreply@luvy.app>" {
+		t.Fatalf("default sender not applied: %q", sent[0].Message.From)
+	}
+	if _, err := mail.ReadMessage(bytes.NewReader(sent[0].Raw)); err != nil {
+		t.Fatalf("raw message does not parse: %v", err)
+	}
+
+	m.Err = errors.New("smtp down")
+	if err := m.Send(context.Background(), r.Message("begum@example.com")); !errors.Is(err, m.Err) {
+		t.Fatalf("got %v, want the configured error", err)
+	}
+	if len(m.Messages()) != 1 {
+		t.Fatal("a failed send was recorded")
+	}
+}
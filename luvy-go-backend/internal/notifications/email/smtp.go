@@ -1,8 +1,30 @@
 ﻿package email
 
 import (
+"context"
+"crypto/tls"
+"errors"
 "fmt"
+"net"
+"net/mail"
 "net/smtp"
+"os"
+"sync"
+"time"
+)
+
+// Mailer is the transport the outbox sends through. SMTPClient is the real
+// one; Memory stands in for it in tests and local runs.
+type Mailer interface {
+Send(ctx context.Context, m *Message) error
+}
+
+// TLS modes for SMTPConfig.TLS.
+const (
+TLSOpportunistic = ""         // STARTTLS when the server offers it
+TLSStartTLS      = "starttls" // require STARTTLS
+TLSImplicit      = "tls"      // TLS from the first byte (port 465)
+TLSNone          = "none"     // plain text, local relays only
 )
 
 type SMTPConfig struct {
@@ -11,23 +33,239 @@ Port string
 User string
 Pass string
 From string
+
+TLS       string
+TLSConfig *tls.Config // optional, e.g. a private CA
+
+PoolSize    int           // idle connections kept, default 2
+IdleTimeout time.Duration // idle connections older than this are redialled, default 30s
+DialTimeout time.Duration // default 10s
+}
+
+type pooledConn struct {
+c        *smtp.Client
+nc       net.Conn // the socket under c, for deadlines
+lastUsed time.Time
 }
 
-type SMTPClient struct{ cfg SMTPConfig }
-func NewSMTP(cfg SMTPConfig) *SMTPClient { return &SMTPClient{cfg: cfg} }
+// SMTPClient keeps a small pool of authenticated connections and reuses
+// them across messages (RSET between mails).
+type SMTPClient struct {
+cfg  SMTPConfig
+idle chan *pooledConn
+
+closeOnce sync.Once
+}
 
+func NewSMTP(cfg SMTPConfig) *SMTPClient {
+if cfg.Port == "" {
+cfg.Port = "587"
+if cfg.TLS == TLSImplicit {
+cfg.Port = "465"
+}
+}
+if cfg.PoolSize <= 0 { cfg.PoolSize = 2 }
+if cfg.IdleTimeout <= 0 { cfg.IdleTimeout = 30 * time.Second }
+if cfg.DialTimeout <= 0 { cfg.DialTimeout = 10 * time.Second }
+return &SMTPClient{cfg: cfg, idle: make(chan *pooledConn, cfg.PoolSize)}
+}
+
+func (c *SMTPClient) Send(ctx context.Context, m *Message) error {
+if m.From == "" {
+m.From = c.cfg.From
+}
+raw, err := Build(m, time.Now())
+if err != nil {
+return err
+}
+from, err := mail.ParseAddress(m.From)
+if err != nil {
+return err
+}
+
+conn, err := c.get(ctx)
+if err != nil {
+return err
+}
+// a server that stops answering mid-message must not outlive ctx
+stop := context.AfterFunc(ctx, func() { conn.nc.Close() })
+err = deliver(conn.c, from.Address, m.To, raw)
+if !stop() || err != nil {
+// protocol state is unknown after an error; never reuse the connection
+conn.c.Close()
+return ctxErr(ctx, err)
+}
+c.put(conn)
+return nil
+}
+
+// bound applies ctx's deadline to the socket. Without one it clears the
+// deadline a previous send left on a pooled connection.
+func bound(ctx context.Context, nc net.Conn) error {
+deadline, _ := ctx.Deadline()
+return nc.SetDeadline(deadline)
+}
+
+// ctxErr reports a failure caused by ctx ending as ctx's error, so callers
+// can tell it from a server rejection.
+func ctxErr(ctx context.Context, err error) error {
+if err != nil && ctx.Err() != nil {
+return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
+return err
+}
+
+// SendHTML is the original single-part helper, kept for callers that only
+// have HTML.
 func (c *SMTPClient) SendHTML(to, subject, htmlBody string) error {
-addr := fmt.Sprintf("%s:%s", c.cfg.Host, c.cfg.Port)
-auth := smtp.PlainAuth("", c.cfg.User, c.cfg.Pass, c.cfg.Host)
-
-msg := []byte(
-"From: " + c.cfg.From + "\r\n" +
-"To: " + to + "\r\n" +
-"Subject: " + subject + "\r\n" +
-"MIME-Version: 1.0\r\n" +
-"Content-Type: text/html; charset=UTF-8\r\n\r\n" +
-htmlBody,
-)
+return c.Send(context.Background(), &Message{To: []string{to}, Subject: subject, HTML: htmlBody})
+}
+
+// Close quits all idle connections.
+func (c *SMTPClient) Close() {
+c.closeOnce.Do(func() {
+for {
+select {
+case pc := <-c.idle:
+_ = pc.c.Quit()
+default:
+return
+}
+}
+})
+}
+
+func deliver(sc *smtp.Client, from string, to []string, raw []byte) error {
+if err := sc.Mail(from); err != nil {
+return err
+}
+for _, a := range to {
+addr, err := mail.ParseAddress(a)
+if err != nil {
+return err
+}
+if err := sc.Rcpt(addr.Address); err != nil {
+return err
+}
+}
+w, err := sc.Data()
+if err != nil {
+return err
+}
+if _, err := w.Write(raw); err != nil {
+return err
+}
+return w.Close()
+}
+
+func (c *SMTPClient) get(ctx context.Context) (*pooledConn, error) {
+for {
+select {
+case pc := <-c.idle:
+if time.Since(pc.lastUsed) > c.cfg.IdleTimeout || bound(ctx, pc.nc) != nil || pc.c.Noop() != nil {
+pc.c.Close()
+continue
+}
+return pc, nil
+default:
+return c.dial(ctx)
+}
+}
+}
 
-return smtp.SendMail(addr, auth, c.cfg.From, []string{to}, msg)
+func (c *SMTPClient) put(pc *pooledConn) {
+if err := pc.c.Reset(); err != nil {
+pc.c.Close()
+return
+}
+pc.lastUsed = time.Now()
+select {
+case c.idle <- pc:
+default:
+_ = pc.c.Quit()
+}
+}
+
+func (c *SMTPClient) dial(ctx context.Context) (*pooledConn, error) {
+addr := net.JoinHostPort(c.cfg.Host, c.cfg.Port)
+d := &net.Dialer{Timeout: c.cfg.DialTimeout}
+
+tlsCfg := c.cfg.TLSConfig
+if tlsCfg == nil {
+tlsCfg = &tls.Config{ServerName: c.cfg.Host}
+}
+
+var conn net.Conn
+var err error
+if c.cfg.TLS == TLSImplicit {
+conn, err = (&tls.Dialer{NetDialer: d, Config: tlsCfg}).DialContext(ctx, "tcp", addr)
+} else {
+conn, err = d.DialContext(ctx, "tcp", addr)
+}
+if err != nil {
+return nil, err
+}
+if err := bound(ctx, conn); err != nil {
+conn.Close()
+return nil, err
+}
+stop := context.AfterFunc(ctx, func() { conn.Close() })
+defer stop()
+
+sc, err := smtp.NewClient(conn, c.cfg.Host)
+if err != nil {
+conn.Close()
+return nil, ctxErr(ctx, err)
+}
+if err := c.handshake(sc, tlsCfg); err != nil {
+sc.Close()
+return nil, ctxErr(ctx, err)
+}
+if !stop() {
+sc.Close()
+return nil, ctx.Err()
+}
+return &pooledConn{c: sc, nc: conn}, nil
+}
+
+func (c *SMTPClient) handshake(sc *smtp.Client, tlsCfg *tls.Config) error {
+if err := sc.Hello(helloName()); err != nil {
+return err
+}
+
+switch c.cfg.TLS {
+case TLSImplicit, TLSNone:
+case TLSStartTLS, TLSOpportunistic:
+ok, _ := sc.Extension("STARTTLS")
+if ok {
+if err := sc.StartTLS(tlsCfg); err != nil {
+return err
+}
+} else if c.cfg.TLS == TLSStartTLS {
+return errors.New("email: server does not offer STARTTLS")
+}
+default:
+return fmt.Errorf("email: unknown TLS mode %q", c.cfg.TLS)
+}
+
+if c.cfg.User != "" {
+// configured credentials mean the relay expects them; sending
+// unauthenticated would only be refused later or, worse, accepted
+if ok, _ := sc.Extension("AUTH"); !ok {
+return errors.New("email: server does not offer AUTH")
+}
+// PlainAuth refuses to send credentials over an unencrypted
+// connection except to localhost.
+if err := sc.Auth(smtp.PlainAuth("", c.cfg.User, c.cfg.Pass, c.cfg.Host)); err != nil {
+return err
+}
+}
+return nil
+}
+
+func helloName() string {
+if h, err := os.Hostname(); err == nil && h != "" {
+return h
+}
+return "localhost"
 }
@@ -0,0 +1,278 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type received struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// smtpStandIn is an in-process SMTP server, just enough of one for
+// SMTPClient: no STARTTLS, no AUTH. Recipients in reject get a 550; with
+// stall set it takes the message and never answers.
+type smtpStandIn struct {
+	ln     net.Listener
+	reject map[string]bool
+	stall  bool
+
+	mu    sync.Mutex
+	conns int
+	quits int
+	mail  []received
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{ln: ln, reject: map[string]bool{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) serve(c net.Conn) {
+	defer c.Close()
+	tc := textproto.NewConn(c)
+	tc.PrintfLine("220 stand-in ESMTP")
+	var cur received
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tc.PrintfLine("250-stand-in")
+			tc.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			cur = received{From: address(arg)}
+			tc.PrintfLine("250 OK")
+		case "RCPT":
+			to := address(arg)
+			if s.reject[to] {
+				tc.PrintfLine("550 no such user")
+				continue
+			}
+			cur.To = append(cur.To, to)
+			tc.PrintfLine("250 OK")
+		case "DATA":
+			tc.PrintfLine("354 go ahead")
+			data, err := tc.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			cur.Data = data
+			s.mu.Lock()
+			s.mail = append(s.mail, cur)
+			stall := s.stall
+			s.mu.Unlock()
+			if stall {
+				continue
+			}
+			tc.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			cur = received{}
+			tc.PrintfLine("250 OK")
+		case "QUIT":
+			s.mu.Lock()
+			s.quits++
+			s.mu.Unlock()
+			tc.PrintfLine("221 bye")
+			return
+		default:
+			tc.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// address pulls the mailbox out of "FROM:<a@b> BODY=8BITMIME".
+func address(arg string) string {
+	_, rest, _ := strings.Cut(arg, "<")
+	addr, _, _ := strings.Cut(rest, ">")
+	return addr
+}
+
+func (s *smtpStandIn) client(tls string) *SMTPClient {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return NewSMTP(SMTPConfig{Host: host, Port: port, From: "LUVY <noreply@luvy.app>", TLS: tls})
+}
+
+func (s *smtpStandIn) stats() (conns, quits int, mail []received) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, s.quits, append([]received(nil), s.mail...)
+}
+
+func TestSMTPClientReusesConnections(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	c := srv.client(TLSNone)
+	ctx := context.Background()
+
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		if err := c.Send(ctx, &Message{To: []string{to}, Subject: "Özet", Text: "merhaba"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Close()
+
+	conns, quits, got := srv.stats()
+	if conns != 1 || quits != 1 {
+		t.Fatalf("%d connections, %d quits; want one of each", conns, quits)
+	}
+	if len(got) != 2 {
+		t.Fatalf("%d messages received, want 2", len(got))
+	}
+	for i, to := range []string{"a@example.com", "b@example.com"} {
+		if got[i].From != "noreply@luvy.app" || len(got[i].To) != 1 || got[i].To[0] != to {
+			t.Fatalf("message %d envelope %s -> %v", i, got[i].From, got[i].To)
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(got[i].Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Header.Get("To") != "<"+to+">" {
+			t.Fatalf("message %d header To %q", i, msg.Header.Get("To"))
+		}
+	}
+}
+
+func TestSMTPClientDropsConnectionAfterFailedDelivery(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	srv.reject["gone@example.com"] = true
+	c := srv.client(TLSOpportunistic) // the stand-in offers no STARTTLS
+	defer c.Close()
+	ctx := context.Background()
+
+	if err := c.Send(ctx, &Message{To: []string{"gone@example.com"}, Text: "x"}); err == nil {
+		t.Fatal("rejected recipient reported as sent")
+	}
+	if err := c.Send(ctx, &Message{To: []string{"a@example.com"}, Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if conns, _, got := srv.stats(); conns != 2 || len(got) != 1 {
+		t.Fatalf("%d connections, %d messages; want a fresh connection after the failure and one message", conns, len(got))
+	}
+}
+
+func TestSMTPClientRequiresOfferedStartTLS(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	c := srv.client(TLSStartTLS)
+	defer c.Close()
+
+	err := c.Send(context.Background(), &Message{To: []string{"a@example.com"}, Text: "x"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("got %v, want a STARTTLS error", err)
+	}
+	if _, _, got := srv.stats(); len(got) != 0 {
+		t.Fatal("message sent in plain text")
+	}
+}
+
+func TestSMTPClientGivesUpAtTheContextDeadline(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	srv.stall = true
+	c := srv.client(TLSNone)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.Send(ctx, &Message{To: []string{"a@example.com"}, Text: "x"}); err == nil {
+		t.Fatal("unanswered message reported as sent")
+	}
+	if waited := time.Since(start); waited > 2*time.Second {
+		t.Fatalf("send blocked for %s past a 200ms deadline", waited)
+	}
+
+	srv.mu.Lock()
+	srv.stall = false
+	srv.mu.Unlock()
+	if err := c.Send(context.Background(), &Message{To: []string{"b@example.com"}, Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if conns, _, _ := srv.stats(); conns != 2 {
+		t.Fatalf("%d connections; the timed-out one must not be reused", conns)
+	}
+}
+
+func TestSMTPClientStopsWhenTheContextIsCancelled(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	srv.stall = true
+	c := srv.client(TLSNone)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() { done <- c.Send(ctx, &Message{To: []string{"a@example.com"}, Text: "x"}) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("send kept waiting after its context was cancelled")
+	}
+}
+
+func TestSMTPClientClearsAnEarlierDeadline(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	c := srv.client(TLSNone)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Send(ctx, &Message{To: []string{"a@example.com"}, Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	<-ctx.Done()
+	// the pooled connection still carries the expired deadline
+	if err := c.Send(context.Background(), &Message{To: []string{"b@example.com"}, Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if conns, _, got := srv.stats(); conns != 1 || len(got) != 2 {
+		t.Fatalf("%d connections, %d messages; want both sent over one", conns, len(got))
+	}
+}
+
+func TestSMTPClientRequiresOfferedAuth(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	c := srv.client(TLSNone)
+	c.cfg.User, c.cfg.Pass = "luvy", "secret"
+	defer c.Close()
+
+	err := c.Send(context.Background(), &Message{To: []string{"a@example.com"}, Text: "x"})
+	if err == nil || !strings.Contains(err.Error(), "AUTH") {
+		t.Fatalf("got %v, want an AUTH error", err)
+	}
+	if _, _, got := srv.stats(); len(got) != 0 {
+		t.Fatal("message sent without the configured credentials")
+	}
+}
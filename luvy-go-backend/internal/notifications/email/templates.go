@@ -1,31 +1,217 @@
 ﻿package email
 
-import "fmt"
+import (
+"bytes"
+"embed"
+"errors"
+"fmt"
+htmltemplate "html/template"
+"path"
+"sort"
+"strings"
+texttemplate "text/template"
+)
 
 type Template string
 
 const (
-WelcomeV1      Template = "WELCOME_V1"
-PointsEarnedV1 Template = "POINTS_EARNED_V1"
+WelcomeV1       Template = "WELCOME_V1"
+PointsEarnedV1  Template = "POINTS_EARNED_V1"
+BudgetAlertV1   Template = "BUDGET_ALERT_V1"
+ExportReadyV1   Template = "EXPORT_READY_V1"
+EmailVerifyV1   Template = "EMAIL_VERIFY_V1"
+PasswordResetV1 Template = "PASSWORD_RESET_V1"
 )
 
-func Render(t Template, data map[string]string) (subject string, html string) {
-switch t {
-case WelcomeV1:
-subject = "LUVY'ye hoş geldin!"
-name := data["name"]
-if name == "" { name = "👋" }
-html = fmt.Sprintf("<h2>Merhaba %s</h2><p>LUVY hesabın aktif ✅</p>", name)
-return
-case PointsEarnedV1:
-subject = "Yeni LUVY kazandın!"
-amount := data["amount"]
-if amount == "" { amount = "0" }
-html = fmt.Sprintf("<h2>🎉 Tebrikler</h2><p>+%s LUVY kazandın.</p>", amount)
-return
-default:
-subject = "LUVY bildirimi"
-html = "<p>Bildirim</p>"
-return
+const DefaultLocale = "tr"
+
+var Locales = []string{"tr", "de", "en"}
+
+// Spec lists the data keys a template cannot render without, sample values
+// for the admin preview, and the preference category (marketing,
+// transactional, security) recipients can opt out of.
+type Spec struct {
+Category string            `json:"category"`
+Required []string          `json:"required"`
+Sample   map[string]string `json:"sample"`
+}
+
+var specs = map[Template]Spec{
+WelcomeV1: {
+Category: "transactional",
+Required: []string{"name"},
+Sample:   map[string]string{"name": "Begüm"},
+},
+PointsEarnedV1: {
+Category: "transactional",
+Required: []string{"amount"},
+Sample:   map[string]string{"name": "Begüm", "amount": "50"},
+},
+BudgetAlertV1: {
+Category: "transactional",
+Required: []string{"category", "threshold", "spent", "limit"},
+Sample:   map[string]string{"name": "Begüm", "category": "Market", "threshold": "80", "spent": "400.00", "limit": "500.00", "month": "2026-03"},
+},
+ExportReadyV1: {
+Category: "transactional",
+Required: []string{"filename"},
+Sample:   map[string]string{"name": "Begüm", "filename": "luvy-statement-2026-03.pdf", "download_url": "https://api.luvy.app/api/exports/download/abc", "expires_at": "2026-04-08"},
+},
+EmailVerifyV1: {
+Category: "security",
+Required: []string{"verify_url"},
+Sample:   map[string]string{"name": "Begüm", "verify_url": "https://api.luvy.app/api/auth/verify-email?token=abc"},
+},
+PasswordResetV1: {
+Category: "security",
+Required: []string{"reset_url"},
+Sample:   map[string]string{"name": "Begüm", "reset_url": "https://app.luvy.app/reset-password?token=abc"},
+},
+}
+
+var ErrUnknownTemplate = errors.New("email: unknown template")
+
+type MissingDataError struct {
+Template Template
+Keys     []string
+}
+
+func (e *MissingDataError) Error() string {
+return fmt.Sprintf("email: %s requires data keys: %s", e.Template, strings.Join(e.Keys, ", "))
+}
+
+// Rendered is a ready-to-send message: HTML plus its plain-text alternative.
+type Rendered struct {
+Subject string `json:"subject"`
+HTML    string `json:"html"`
+Text    string `json:"text"`
+}
+
+// view is what templates see: {{.Data.name}}, {{.Locale}}, {{.Subject}}.
+type view struct {
+Locale  string
+Subject string
+Data    map[string]string
+}
+
+//go:embed templates
+var files embed.FS
+
+type compiled struct {
+html *htmltemplate.Template
+text *texttemplate.Template
+}
+
+// compiledSet is keyed by template and locale. Everything is parsed at init,
+// so a broken template file fails at startup rather than at send time.
+var compiledSet = mustCompile()
+
+func mustCompile() map[Template]map[string]compiled {
+out := map[Template]map[string]compiled{}
+for t := range specs {
+dir := "templates/" + strings.ToLower(string(t))
+out[t] = map[string]compiled{}
+for _, loc := range Locales {
+h := htmltemplate.Must(htmltemplate.New("").Option("missingkey=zero").ParseFS(files,
+"templates/layout/*.html", "templates/partials/*.html", path.Join(dir, loc+".html")))
+x := texttemplate.Must(texttemplate.New("").Option("missingkey=zero").ParseFS(files,
+"templates/layout/*.txt", "templates/partials/*.txt", path.Join(dir, loc+".txt")))
+out[t][loc] = compiled{html: h, text: x}
+}
+}
+return out
+}
+
+// Templates returns the known templates with their specs.
+func Templates() map[Template]Spec {
+out := make(map[Template]Spec, len(specs))
+for t, s := range specs {
+out[t] = s
+}
+return out
+}
+
+// NormalizeLocale maps "de-DE", "EN" and the like onto a supported locale,
+// falling back to DefaultLocale.
+func NormalizeLocale(locale string) string {
+l := strings.ToLower(strings.TrimSpace(locale))
+if i := strings.IndexAny(l, "-_"); i >= 0 {
+l = l[:i]
+}
+if SupportedLocale(l) {
+return l
+}
+return DefaultLocale
+}
+
+func SupportedLocale(locale string) bool {
+for _, l := range Locales {
+if l == locale {
+return true
+}
+}
+return false
+}
+
+// Validate checks the template exists and every required key is non-empty.
+// Call it before enqueueing so bad payloads never reach the outbox.
+func Validate(t Template, data map[string]string) error {
+spec, ok := specs[t]
+if !ok {
+return ErrUnknownTemplate
+}
+var missing []string
+for _, k := range spec.Required {
+if strings.TrimSpace(data[k]) == "" {
+missing = append(missing, k)
+}
+}
+if len(missing) > 0 {
+sort.Strings(missing)
+return &MissingDataError{Template: t, Keys: missing}
+}
+return nil
+}
+
+func Render(t Template, locale string, data map[string]string) (Rendered, error) {
+if err := Validate(t, data); err != nil {
+return Rendered{}, err
+}
+c := compiledSet[t][NormalizeLocale(locale)]
+v := view{Locale: NormalizeLocale(locale), Data: data}
+
+var subject, text, html bytes.Buffer
+if err := c.text.ExecuteTemplate(&subject, "subject", v); err != nil {
+return Rendered{}, err
+}
+v.Subject = strings.TrimSpace(subject.String())
+
+if err := c.text.ExecuteTemplate(&text, "layout", v); err != nil {
+return Rendered{}, err
+}
+if err := c.html.ExecuteTemplate(&html, "layout", v); err != nil {
+return Rendered{}, err
+}
+return Rendered{Subject: v.Subject, HTML: html.String(), Text: strings.TrimSpace(text.String()) + "\n"}, nil
+}
+
+// RenderInApp renders the short in-app variant: the subject as title and the
+// locale file's "inapp" block as body (empty when a template has none).
+func RenderInApp(t Template, locale string, data map[string]string) (title, body string, err error) {
+if err := Validate(t, data); err != nil {
+return "", "", err
+}
+c := compiledSet[t][NormalizeLocale(locale)]
+v := view{Locale: NormalizeLocale(locale), Data: data}
+
+var subject, short bytes.Buffer
+if err := c.text.ExecuteTemplate(&subject, "subject", v); err != nil {
+return "", "", err
+}
+if c.text.Lookup("inapp") != nil {
+if err := c.text.ExecuteTemplate(&short, "inapp", v); err != nil {
+return "", "", err
+}
 }
+return strings.TrimSpace(subject.String()), strings.TrimSpace(short.String()), nil
 }
@@ -0,0 +1,161 @@
+package email
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSamplesRenderInEveryLocale(t *testing.T) {
+	for tmpl, spec := range Templates() {
+		for _, loc := range Locales {
+			r, err := Render(tmpl, loc, spec.Sample)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", tmpl, loc, err)
+			}
+			if r.Subject == "" || r.HTML == "" || strings.TrimSpace(r.Text) == "" {
+				t.Fatalf("%s/%s rendered an empty part: %+v", tmpl, loc, r)
+			}
+			if !strings.Contains(r.HTML, `<html lang="`+loc+`">`) {
+				t.Fatalf("%s/%s: html not tagged with its locale", tmpl, loc)
+			}
+		}
+	}
+}
+
+func TestRenderEscapesUserDataInHTMLOnly(t *testing.T) {
+	name := `<script>alert("x")</script> & Co`
+	r, err := Render(WelcomeV1, "en", map[string]string{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(r.HTML, "<script>") {
+		t.Fatalf("name reached the html unescaped:\n%s", r.HTML)
+	}
+	if !strings.Contains(r.HTML, "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; Co") {
+		t.Fatalf("escaped name missing from the html:\n%s", r.HTML)
+	}
+	// the plain-text part is not markup, so it keeps the name as typed
+	if !strings.Contains(r.Text, "Hi "+name+",") {
+		t.Fatalf("text part altered the name:\n%s", r.Text)
+	}
+}
+
+func TestRenderRefusesScriptURLs(t *testing.T) {
+	r, err := Render(EmailVerifyV1, "en", map[string]string{"verify_url": "javascript:alert(1)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(r.HTML, "javascript:") {
+		t.Fatalf("script url kept in href:\n%s", r.HTML)
+	}
+	r, err = Render(EmailVerifyV1, "en", map[string]string{"verify_url": "https://api.luvy.app/verify?token=a&b=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(r.HTML, `href="https://api.luvy.app/verify?token=a&amp;b=1"`) {
+		t.Fatalf("https url not kept:\n%s", r.HTML)
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	for in, want := range map[string]string{
+		"de":     "de",
+		"de-DE":  "de",
+		"EN_gb":  "en",
+		" tr ":   "tr",
+		"fr":     DefaultLocale,
+		"":       DefaultLocale,
+		"german": DefaultLocale,
+	} {
+		if got := NormalizeLocale(in); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	data := map[string]string{"name": "Ana"}
+	want, err := Render(WelcomeV1, DefaultLocale, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, loc := range []string{"fr-FR", ""} {
+		got, err := Render(WelcomeV1, loc, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("locale %q rendered %q, want the %s message %q", loc, got.Subject, DefaultLocale, want.Subject)
+		}
+	}
+	de, err := Render(WelcomeV1, "de-AT", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if de.Subject != "Willkommen bei LUVY!" || !strings.Contains(de.Text, "Du erhältst") {
+		t.Fatalf("de-AT not rendered in German: %+v", de)
+	}
+}
+
+func TestMissingDataIsReportedSorted(t *testing.T) {
+	data := map[string]string{"category": "Market", "spent": "  ", "name": "Ana"}
+	err := Validate(BudgetAlertV1, data)
+	var missing *MissingDataError
+	if !errors.As(err, &missing) {
+		t.Fatalf("got %v, want *MissingDataError", err)
+	}
+	if missing.Template != BudgetAlertV1 || !reflect.DeepEqual(missing.Keys, []string{"limit", "spent", "threshold"}) {
+		t.Fatalf("got %+v", missing)
+	}
+
+	if _, err := Render(BudgetAlertV1, "en", data); !errors.As(err, &missing) {
+		t.Fatalf("render: got %v, want *MissingDataError", err)
+	}
+	if _, _, err := RenderInApp(BudgetAlertV1, "en", data); !errors.As(err, &missing) {
+		t.Fatalf("in-app: got %v, want *MissingDataError", err)
+	}
+	// optional keys may be left out
+	if err := Validate(WelcomeV1, map[string]string{"name": "Ana"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnknownTemplate(t *testing.T) {
+	if err := Validate("NOPE_V1", nil); !errors.Is(err, ErrUnknownTemplate) {
+		t.Fatalf("validate: got %v", err)
+	}
+	if _, err := Render("NOPE_V1", "en", nil); !errors.Is(err, ErrUnknownTemplate) {
+		t.Fatalf("render: got %v", err)
+	}
+	if _, _, err := RenderInApp("NOPE_V1", "en", nil); !errors.Is(err, ErrUnknownTemplate) {
+		t.Fatalf("in-app: got %v", err)
+	}
+}
+
+func TestRenderInApp(t *testing.T) {
+	title, body, err := RenderInApp(PointsEarnedV1, "de-DE", map[string]string{"amount": "50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "Du hast +50 LUVY gesammelt." {
+		t.Fatalf("body %q", body)
+	}
+	r, err := Render(PointsEarnedV1, "de", map[string]string{"amount": "50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != r.Subject {
+		t.Fatalf("title %q, want the email subject %q", title, r.Subject)
+	}
+
+	// no inapp block: title only
+	title, body, err = RenderInApp(EmailVerifyV1, "en", map[string]string{"verify_url": "https://luvy.app/v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title == "" || body != "" {
+		t.Fatalf("got title %q body %q", title, body)
+	}
+}
@@ -0,0 +1,90 @@
+package prefs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	_ "time/tzdata"
+)
+
+// Checker is the outbox worker's read path. It queries the same tables the
+// Service writes, over database/sql like the rest of the worker.
+type Checker struct {
+	DB *sql.DB
+}
+
+func NewChecker(db *sql.DB) *Checker {
+	return &Checker{DB: db}
+}
+
+// Check decides whether a notification may go out now. Unknown users are
+// allowed through so system mail to arbitrary addresses still works.
+func (c *Checker) Check(ctx context.Context, userID, channel, category string, now time.Time) (Decision, error) {
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil || category == CategorySecurity {
+		return Decision{Allowed: true}, nil
+	}
+
+	var tz string
+	var quiet QuietHours
+	var deactivated bool
+	err = c.DB.QueryRowContext(ctx, `
+SELECT COALESCE(time_zone, ''), COALESCE(quiet_hours_start, ''), COALESCE(quiet_hours_end, ''), deactivated_at IS NOT NULL
+FROM users WHERE id=$1
+`, id).Scan(&tz, &quiet.Start, &quiet.End, &deactivated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Decision{Allowed: true}, nil
+	}
+	if err != nil {
+		return Decision{}, err
+	}
+	if deactivated {
+		return Decision{Reason: "account deactivated"}, nil
+	}
+
+	rows, err := c.DB.QueryContext(ctx, `
+SELECT channel, category, enabled FROM notification_preferences WHERE user_id=$1
+`, id)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer rows.Close()
+
+	m := Default()
+	for rows.Next() {
+		var ch, cat string
+		var enabled bool
+		if err := rows.Scan(&ch, &cat, &enabled); err != nil {
+			return Decision{}, err
+		}
+		if Valid(ch, cat) && cat != CategorySecurity {
+			m[ch][cat] = enabled
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Decision{}, err
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "" {
+		loc = time.UTC
+	}
+	return Decide(m, quiet, loc, channel, category, now), nil
+}
+
+// UserIDByEmail resolves the recipient of an email payload that carries only
+// an address. Returns "" when no user has that address.
+func (c *Checker) UserIDByEmail(ctx context.Context, address string) (string, error) {
+	var id uint64
+	err := c.DB.QueryRowContext(ctx, `SELECT id FROM users WHERE email=$1`, address).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(id, 10), nil
+}
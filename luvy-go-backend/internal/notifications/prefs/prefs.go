@@ -0,0 +1,143 @@
+package prefs
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+	ChannelInApp = "in_app"
+)
+
+const (
+	CategoryMarketing     = "marketing"
+	CategoryTransactional = "transactional"
+	CategorySecurity      = "security"
+)
+
+var (
+	Channels   = []string{ChannelEmail, ChannelPush, ChannelInApp}
+	Categories = []string{CategoryMarketing, CategoryTransactional, CategorySecurity}
+)
+
+var (
+	ErrInvalid        = errors.New("unknown channel or category")
+	ErrLocked         = errors.New("security notifications cannot be turned off")
+	ErrInvalidQuiet   = errors.New("quiet hours must be HH:MM, both set or both empty")
+	ErrInvalidLinkSig = errors.New("invalid unsubscribe link")
+)
+
+// Matrix is channel -> category -> enabled.
+type Matrix map[string]map[string]bool
+
+// Default is what a user gets before touching any setting: marketing is
+// opt-in, everything else is on.
+func Default() Matrix {
+	m := Matrix{}
+	for _, ch := range Channels {
+		m[ch] = map[string]bool{}
+		for _, cat := range Categories {
+			m[ch][cat] = cat != CategoryMarketing
+		}
+	}
+	return m
+}
+
+func Valid(channel, category string) bool {
+	return contains(Channels, channel) && contains(Categories, category)
+}
+
+func ValidCategory(category string) bool {
+	return contains(Categories, category)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Decision is the outcome of checking a notification against preferences.
+// A zero DeferUntil with Allowed set means send now.
+type Decision struct {
+	Allowed    bool
+	Reason     string
+	DeferUntil time.Time
+}
+
+// QuietHours is a daily window in the user's time zone, e.g. 22:00-07:00.
+// The zero value means no quiet hours.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (q QuietHours) Enabled() bool {
+	return q.Start != "" && q.End != ""
+}
+
+func (q QuietHours) Validate() error {
+	if q.Start == "" && q.End == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", q.Start); err != nil {
+		return ErrInvalidQuiet
+	}
+	if _, err := time.Parse("15:04", q.End); err != nil {
+		return ErrInvalidQuiet
+	}
+	return nil
+}
+
+// Until reports whether now falls inside the window and, if so, when it ends.
+func (q QuietHours) Until(now time.Time, loc *time.Location) (time.Time, bool) {
+	if !q.Enabled() || q.Validate() != nil {
+		return time.Time{}, false
+	}
+	local := now.In(loc)
+	start := clock(local, q.Start)
+	end := clock(local, q.End)
+
+	if !start.After(end) {
+		// same-day window, e.g. 13:00-15:00
+		if !local.Before(start) && local.Before(end) {
+			return end, true
+		}
+		return time.Time{}, false
+	}
+	// window over midnight, e.g. 22:00-07:00
+	if !local.Before(start) {
+		return end.AddDate(0, 0, 1), true
+	}
+	if local.Before(end) {
+		return end, true
+	}
+	return time.Time{}, false
+}
+
+func clock(day time.Time, hhmm string) time.Time {
+	t, _ := time.Parse("15:04", hhmm)
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+}
+
+// Decide applies the matrix and quiet hours. Security notifications always
+// go out immediately; quiet hours only hold back push.
+func Decide(m Matrix, quiet QuietHours, loc *time.Location, channel, category string, now time.Time) Decision {
+	if category == CategorySecurity {
+		return Decision{Allowed: true}
+	}
+	if !m[channel][category] {
+		return Decision{Reason: "user opted out of " + channel + "/" + category}
+	}
+	if channel == ChannelPush {
+		if until, quiet := quiet.Until(now, loc); quiet {
+			return Decision{Allowed: true, Reason: "quiet hours", DeferUntil: until}
+		}
+	}
+	return Decision{Allowed: true}
+}
@@ -0,0 +1,115 @@
+package prefs
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/src/models"
+)
+
+// Service reads and writes preferences for the API.
+type Service struct {
+	DB *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+type Settings struct {
+	Preferences Matrix     `json:"preferences"`
+	QuietHours  QuietHours `json:"quiet_hours"`
+	TimeZone    string     `json:"time_zone"`
+}
+
+type Change struct {
+	Channel  string `json:"channel"`
+	Category string `json:"category"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func (s *Service) Get(userID uint) (Settings, error) {
+	var user models.User
+	if err := s.DB.Select("id", "time_zone", "quiet_hours_start", "quiet_hours_end").First(&user, userID).Error; err != nil {
+		return Settings{}, err
+	}
+	m, err := s.matrix(userID)
+	if err != nil {
+		return Settings{}, err
+	}
+	return Settings{
+		Preferences: m,
+		QuietHours:  QuietHours{Start: user.QuietHoursStart, End: user.QuietHoursEnd},
+		TimeZone:    user.TimeZone,
+	}, nil
+}
+
+func (s *Service) matrix(userID uint) (Matrix, error) {
+	var rows []models.NotificationPreference
+	if err := s.DB.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	m := Default()
+	for _, r := range rows {
+		if Valid(r.Channel, r.Category) && r.Category != CategorySecurity {
+			m[r.Channel][r.Category] = r.Enabled
+		}
+	}
+	return m, nil
+}
+
+// Update applies the changes and, when quiet is non-nil, the quiet hours,
+// all or nothing.
+func (s *Service) Update(userID uint, changes []Change, quiet *QuietHours) error {
+	for _, c := range changes {
+		if !Valid(c.Channel, c.Category) {
+			return ErrInvalid
+		}
+		if c.Category == CategorySecurity && !c.Enabled {
+			return ErrLocked
+		}
+	}
+	if quiet != nil {
+		if err := quiet.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, c := range changes {
+			if err := setOne(tx, userID, c.Channel, c.Category, c.Enabled); err != nil {
+				return err
+			}
+		}
+		if quiet != nil {
+			return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+				"quiet_hours_start": quiet.Start,
+				"quiet_hours_end":   quiet.End,
+			}).Error
+		}
+		return nil
+	})
+}
+
+// Unsubscribe turns one pair off; used by the one-click link.
+func (s *Service) Unsubscribe(userID uint, channel, category string) error {
+	if !Valid(channel, category) {
+		return ErrInvalid
+	}
+	if category == CategorySecurity {
+		return ErrLocked
+	}
+	return setOne(s.DB, userID, channel, category, false)
+}
+
+func setOne(db *gorm.DB, userID uint, channel, category string, enabled bool) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel"}, {Name: "category"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"enabled": enabled, "updated_at": gorm.Expr("CURRENT_TIMESTAMP")}),
+	}).Create(&models.NotificationPreference{
+		UserID:   userID,
+		Channel:  channel,
+		Category: category,
+		Enabled:  enabled,
+	}).Error
+}
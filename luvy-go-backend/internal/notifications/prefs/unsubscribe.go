@@ -0,0 +1,67 @@
+package prefs
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Links signs one-click unsubscribe URLs. Links do not expire: they sit in
+// old mails and must keep working, so the secret should be stable
+// (UNSUBSCRIBE_SECRET) rather than the random per-process fallback.
+type Links struct {
+	Secret  []byte
+	BaseURL string
+}
+
+const unsubscribePath = "/api/notifications/unsubscribe"
+
+func NewLinks(secret, baseURL string) *Links {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	return &Links{Secret: key, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (l *Links) Token(userID uint, channel, category string) string {
+	body := base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(userID), 10) + ":" + channel + ":" + category))
+	return body + "." + l.sign(body)
+}
+
+// URL is both the List-Unsubscribe target (RFC 8058 one-click POST) and the
+// link shown in the mail footer (GET, which only asks for confirmation).
+func (l *Links) URL(userID uint, channel, category string) string {
+	return l.BaseURL + unsubscribePath + "?token=" + url.QueryEscape(l.Token(userID, channel, category))
+}
+
+func (l *Links) Verify(token string) (userID uint, channel, category string, err error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(l.sign(body))) {
+		return 0, "", "", ErrInvalidLinkSig
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return 0, "", "", ErrInvalidLinkSig
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return 0, "", "", ErrInvalidLinkSig
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || id == 0 || !Valid(parts[1], parts[2]) {
+		return 0, "", "", ErrInvalidLinkSig
+	}
+	return uint(id), parts[1], parts[2], nil
+}
+
+func (l *Links) sign(body string) string {
+	m := hmac.New(sha256.New, l.Secret)
+	m.Write([]byte("unsubscribe:" + body))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apnsProduction = "https://api.push.apple.com"
+	apnsSandbox    = "https://api.sandbox.push.apple.com"
+
+	// Apple rejects provider tokens older than an hour and throttles ones
+	// refreshed more often than every 20 minutes.
+	apnsTokenRefresh = 50 * time.Minute
+)
+
+// APNs sends to iOS devices over the token-based (.p8) HTTP/2 API.
+type APNs struct {
+	TeamID     string
+	KeyID      string
+	Key        *ecdsa.PrivateKey
+	Topic      string // app bundle ID
+	Production bool
+	Client     *http.Client
+	Endpoint   string
+
+	mu       sync.Mutex
+	jwt      string
+	issuedAt time.Time
+}
+
+// NewAPNs parses the PEM contents of an Apple .p8 signing key.
+func NewAPNs(teamID, keyID string, p8 []byte, topic string, production bool) (*APNs, error) {
+	signer, err := parsePrivateKey(p8)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := signer.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("push: APNs key is not ECDSA")
+	}
+	return &APNs{TeamID: teamID, KeyID: keyID, Key: key, Topic: topic, Production: production}, nil
+}
+
+func (a *APNs) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.jwt != "" && time.Since(a.issuedAt) < apnsTokenRefresh {
+		return a.jwt, nil
+	}
+	now := time.Now()
+	tok, err := signES256(a.Key, map[string]any{"kid": a.KeyID}, map[string]any{
+		"iss": a.TeamID,
+		"iat": now.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	a.jwt, a.issuedAt = tok, now
+	return tok, nil
+}
+
+func (a *APNs) Send(ctx context.Context, token string, msg Message) error {
+	bearer, err := a.providerToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = apnsSandbox
+		if a.Production {
+			endpoint = apnsProduction
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+bearer)
+	req.Header.Set("apns-topic", a.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("Content-Type", "application/json")
+
+	// net/http negotiates HTTP/2 over TLS, which APNs requires.
+	resp, err := client(a.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	_ = json.Unmarshal(b, &apnsErr)
+
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		apnsErr.Reason == "BadDeviceToken",
+		apnsErr.Reason == "Unregistered",
+		apnsErr.Reason == "DeviceTokenNotForTopic":
+		return ErrInvalidToken
+	case apnsErr.Reason == "ExpiredProviderToken":
+		a.mu.Lock()
+		a.jwt = ""
+		a.mu.Unlock()
+	}
+	return fmt.Errorf("apns: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,45 @@
+package push
+
+import (
+	"context"
+	"sync"
+)
+
+// Delivery is one message accepted by a Fake provider.
+type Delivery struct {
+	Token   string
+	Message Message
+}
+
+// Fake is an in-memory Provider for local runs and tests. Tokens listed in
+// Invalid are rejected with ErrInvalidToken; Err, when set, fails every send.
+type Fake struct {
+	mu      sync.Mutex
+	Invalid map[string]bool
+	Err     error
+	Sent    []Delivery
+}
+
+func NewFake() *Fake {
+	return &Fake{Invalid: map[string]bool{}}
+}
+
+func (f *Fake) Send(_ context.Context, token string, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Invalid[token] {
+		return ErrInvalidToken
+	}
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Sent = append(f.Sent, Delivery{Token: token, Message: msg})
+	return nil
+}
+
+// Deliveries returns a copy of everything sent so far.
+func (f *Fake) Deliveries() []Delivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Delivery(nil), f.Sent...)
+}
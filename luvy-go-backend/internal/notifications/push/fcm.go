@@ -0,0 +1,170 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fcmEndpoint   = "https://fcm.googleapis.com"
+	fcmScope      = "https://www.googleapis.com/auth/firebase.messaging"
+	googleOAuthTo = "https://oauth2.googleapis.com/token"
+)
+
+// FCM sends through the Firebase Cloud Messaging HTTP v1 API (Android, and
+// iOS apps that go through Firebase).
+type FCM struct {
+	ProjectID   string
+	AccessToken func(ctx context.Context) (string, error)
+	Client      *http.Client
+	Endpoint    string
+}
+
+func (f *FCM) Send(ctx context.Context, token string, msg Message) error {
+	access, err := f.AccessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        token,
+			"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+			"data":         msg.Data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := f.Endpoint
+	if endpoint == "" {
+		endpoint = fcmEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/v1/projects/%s/messages:send", endpoint, f.ProjectID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client(f.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusNotFound,
+		bytes.Contains(respBody, []byte("UNREGISTERED")),
+		resp.StatusCode == http.StatusBadRequest && bytes.Contains(respBody, []byte("registration token")):
+		return ErrInvalidToken
+	default:
+		return fmt.Errorf("fcm: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+}
+
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	ProjectID   string `json:"project_id"`
+}
+
+// ServiceAccountToken returns an AccessToken func for a Google service
+// account key file. Tokens are cached until shortly before they expire.
+func ServiceAccountToken(keyJSON []byte, hc *http.Client) (projectID string, fn func(ctx context.Context) (string, error), err error) {
+	var sa serviceAccount
+	if err := json.Unmarshal(keyJSON, &sa); err != nil {
+		return "", nil, err
+	}
+	signer, err := parsePrivateKey([]byte(sa.PrivateKey))
+	if err != nil {
+		return "", nil, err
+	}
+	key, ok := signer.(*rsa.PrivateKey)
+	if !ok {
+		return "", nil, errors.New("push: service account key is not RSA")
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = googleOAuthTo
+	}
+
+	var (
+		mu      sync.Mutex
+		cached  string
+		expires time.Time
+	)
+	fn = func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cached != "" && time.Now().Before(expires) {
+			return cached, nil
+		}
+
+		now := time.Now()
+		assertion, err := signRS256(key, map[string]any{}, map[string]any{
+			"iss":   sa.ClientEmail,
+			"scope": fcmScope,
+			"aud":   sa.TokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client(hc).Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+			return "", fmt.Errorf("fcm oauth: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+		}
+
+		var tok struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+			return "", err
+		}
+		cached = tok.AccessToken
+		expires = now.Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+		return cached, nil
+	}
+	return sa.ProjectID, fn, nil
+}
+
+func client(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
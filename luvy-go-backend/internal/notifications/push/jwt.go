@@ -0,0 +1,83 @@
+package push
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+)
+
+// The push services all authenticate with small, self-signed JWTs; these
+// helpers cover the two algorithms they need without a JWT dependency.
+
+var b64 = base64.RawURLEncoding
+
+func signJWT(header, claims map[string]any, sign func(digest []byte) ([]byte, error)) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := sign(digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + b64.EncodeToString(sig), nil
+}
+
+// signES256 produces a JWS ES256 token (raw r||s signature, 64 bytes).
+func signES256(key *ecdsa.PrivateKey, header, claims map[string]any) (string, error) {
+	header["alg"] = "ES256"
+	header["typ"] = "JWT"
+	return signJWT(header, claims, func(digest []byte) ([]byte, error) {
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 64)
+		r.FillBytes(out[:32])
+		s.FillBytes(out[32:])
+		return out, nil
+	})
+}
+
+func signRS256(key *rsa.PrivateKey, header, claims map[string]any) (string, error) {
+	header["alg"] = "RS256"
+	header["typ"] = "JWT"
+	return signJWT(header, claims, func(digest []byte) ([]byte, error) {
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	})
+}
+
+// parsePrivateKey reads a PEM encoded PKCS#8 (or PKCS#1/SEC1) private key,
+// as shipped in Apple .p8 files and Google service account JSON.
+func parsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("push: no PEM block in key")
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if s, ok := k.(crypto.Signer); ok {
+			return s, nil
+		}
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("push: unsupported private key")
+}
@@ -0,0 +1,80 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWeb     = "web"
+)
+
+// ErrInvalidToken is returned by a Provider when the push service reports the
+// device token as unknown or expired. Such tokens are pruned.
+var ErrInvalidToken = errors.New("push: invalid device token")
+
+type Message struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Provider delivers a message to a single device token.
+type Provider interface {
+	Send(ctx context.Context, token string, msg Message) error
+}
+
+// Result summarises a fan-out to every device of one user.
+type Result struct {
+	Sent    int
+	Pruned  int
+	Failed  int
+	LastErr error
+}
+
+// Sender fans a message out to all of a user's devices, picking the provider
+// by the platform the token was registered for.
+type Sender struct {
+	Tokens    *TokenRepo
+	Providers map[string]Provider
+}
+
+func NewSender(tokens *TokenRepo, providers map[string]Provider) *Sender {
+	return &Sender{Tokens: tokens, Providers: providers}
+}
+
+func (s *Sender) SendToUser(ctx context.Context, userID string, msg Message) (Result, error) {
+	var res Result
+
+	tokens, err := s.Tokens.ListForUser(ctx, userID)
+	if err != nil {
+		return res, err
+	}
+
+	for _, t := range tokens {
+		p, ok := s.Providers[t.Platform]
+		if !ok {
+			res.Failed++
+			res.LastErr = fmt.Errorf("push: no provider for platform %q", t.Platform)
+			continue
+		}
+
+		err := p.Send(ctx, t.Token, msg)
+		switch {
+		case err == nil:
+			res.Sent++
+		case errors.Is(err, ErrInvalidToken):
+			if err := s.Tokens.Delete(ctx, t.Platform, t.Token); err != nil {
+				return res, err
+			}
+			res.Pruned++
+		default:
+			res.Failed++
+			res.LastErr = err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,85 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"luvy-go-backend/internal/pgtest"
+)
+
+func TestSendToUserFansOutAndPrunesInvalidTokens(t *testing.T) {
+	db := pgtest.Open(t)
+	tokens := NewTokenRepo(db)
+	ctx := context.Background()
+
+	for _, tk := range []struct{ user, platform, token string }{
+		{"1", PlatformIOS, "ios-ok"},
+		{"1", PlatformAndroid, "android-ok"},
+		{"1", PlatformAndroid, "android-stale"},
+		{"1", PlatformWeb, "web-down"},
+		{"2", PlatformIOS, "someone-else"},
+	} {
+		if err := tokens.Upsert(ctx, tk.user, tk.platform, tk.token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ios, android, web := NewFake(), NewFake(), NewFake()
+	android.Invalid["android-stale"] = true
+	web.Err = errors.New("push service unavailable")
+	s := NewSender(tokens, map[string]Provider{PlatformIOS: ios, PlatformAndroid: android, PlatformWeb: web})
+
+	msg := Message{Title: "LUVY", Body: "50 LUVY kazandın", Data: map[string]string{"kind": "points"}}
+	res, err := s.SendToUser(ctx, "1", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 2 || res.Pruned != 1 || res.Failed != 1 || !errors.Is(res.LastErr, web.Err) {
+		t.Fatalf("result %+v", res)
+	}
+
+	for _, c := range []struct {
+		fake  *Fake
+		token string
+	}{{ios, "ios-ok"}, {android, "android-ok"}} {
+		got := c.fake.Deliveries()
+		if len(got) != 1 || got[0].Token != c.token || got[0].Message.Body != msg.Body {
+			t.Fatalf("deliveries for %s: %+v", c.token, got)
+		}
+	}
+
+	left, err := tokens.ListForUser(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the rejected token goes; web-down failed transiently and stays
+	if len(left) != 3 {
+		t.Fatalf("%d tokens left, want 3", len(left))
+	}
+	for _, tk := range left {
+		if tk.Token == "android-stale" {
+			t.Fatal("rejected token was not pruned")
+		}
+	}
+	if other, err := tokens.ListForUser(ctx, "2"); err != nil || len(other) != 1 {
+		t.Fatalf("other user's tokens: %v %v", other, err)
+	}
+}
+
+func TestSendToUserReportsMissingProvider(t *testing.T) {
+	db := pgtest.Open(t)
+	tokens := NewTokenRepo(db)
+	ctx := context.Background()
+
+	if err := tokens.Upsert(ctx, "1", PlatformWeb, "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewSender(tokens, map[string]Provider{PlatformIOS: NewFake()}).SendToUser(ctx, "1", Message{Title: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 0 || res.Failed != 1 || res.LastErr == nil {
+		t.Fatalf("result %+v", res)
+	}
+}
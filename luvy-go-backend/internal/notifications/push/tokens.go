@@ -3,8 +3,16 @@
 import (
 "context"
 "database/sql"
+"time"
 )
 
+type Token struct {
+UserID     string    `json:"user_id"`
+Platform   string    `json:"platform"`
+Token      string    `json:"token"`
+LastSeenAt time.Time `json:"last_seen_at"`
+}
+
 type TokenRepo struct{ DB *sql.DB }
 func NewTokenRepo(db *sql.DB) *TokenRepo { return &TokenRepo{DB: db} }
 
@@ -17,3 +25,36 @@ DO UPDATE SET user_id=EXCLUDED.user_id, last_seen_at=NOW()
 `, userID, platform, token)
 return err
 }
+
+func (r *TokenRepo) ListForUser(ctx context.Context, userID string) ([]Token, error) {
+rows, err := r.DB.QueryContext(ctx, `
+SELECT user_id, platform, token, last_seen_at
+FROM push_tokens
+WHERE user_id=$1
+ORDER BY last_seen_at DESC
+`, userID)
+if err != nil { return nil, err }
+defer rows.Close()
+
+var out []Token
+for rows.Next() {
+var t Token
+if err := rows.Scan(&t.UserID, &t.Platform, &t.Token, &t.LastSeenAt); err != nil { return nil, err }
+out = append(out, t)
+}
+return out, rows.Err()
+}
+
+// Delete removes a token regardless of owner; used when a provider rejects it.
+func (r *TokenRepo) Delete(ctx context.Context, platform, token string) error {
+_, err := r.DB.ExecContext(ctx, `DELETE FROM push_tokens WHERE platform=$1 AND token=$2`, platform, token)
+return err
+}
+
+// DeleteForUser unregisters a token, but only if it belongs to userID.
+func (r *TokenRepo) DeleteForUser(ctx context.Context, userID, platform, token string) (bool, error) {
+res, err := r.DB.ExecContext(ctx, `DELETE FROM push_tokens WHERE user_id=$1 AND platform=$2 AND token=$3`, userID, platform, token)
+if err != nil { return false, err }
+n, err := res.RowsAffected()
+return n > 0, err
+}
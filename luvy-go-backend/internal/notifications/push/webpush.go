@@ -0,0 +1,198 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	webPushTTL        = 24 * time.Hour
+	webPushRecordSize = 4096
+)
+
+// Subscription is the browser PushSubscription JSON; it is what web clients
+// register as their token.
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// WebPush delivers to browsers using VAPID (RFC 8292) and aes128gcm payload
+// encryption (RFC 8291).
+type WebPush struct {
+	Subject string // mailto: or https: contact for the push service
+	Key     *ecdsa.PrivateKey
+	Client  *http.Client
+}
+
+// NewWebPush takes the VAPID private key as a base64url encoded 32-byte
+// scalar, the format the common key generators print.
+func NewWebPush(subject, privateKey string) (*WebPush, error) {
+	d, err := decodeB64(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), d)
+	if err != nil {
+		return nil, err
+	}
+	return &WebPush{Subject: subject, Key: key}, nil
+}
+
+func (w *WebPush) Send(ctx context.Context, token string, msg Message) error {
+	var sub Subscription
+	if err := json.Unmarshal([]byte(token), &sub); err != nil || sub.Endpoint == "" {
+		return ErrInvalidToken
+	}
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Scheme != "https" {
+		return ErrInvalidToken
+	}
+
+	plain, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	body, err := encryptWebPush(sub, plain)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	vapid, err := signES256(w.Key, map[string]any{}, map[string]any{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.Subject,
+	})
+	if err != nil {
+		return err
+	}
+	pub, err := w.Key.PublicKey.Bytes()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", vapid, b64.EncodeToString(pub)))
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(webPushTTL.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := client(w.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone:
+		return ErrInvalidToken
+	default:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("webpush: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+}
+
+// encryptWebPush builds a single-record aes128gcm body (RFC 8188) keyed as
+// described in RFC 8291 section 3.4.
+func encryptWebPush(sub Subscription, plain []byte) ([]byte, error) {
+	uaPublic, err := decodeB64(sub.Keys.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeB64(sub.Keys.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if len(plain)+1+16 > webPushRecordSize-86 {
+		return nil, errors.New("webpush: payload too large")
+	}
+
+	curve := ecdh.P256()
+	uaKey, err := curve.NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	asKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+	shared, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt | record size | key id length | key id (sender public key).
+	var out bytes.Buffer
+	out.Write(salt)
+	_ = binary.Write(&out, binary.BigEndian, uint32(webPushRecordSize))
+	out.WriteByte(byte(len(asPublic)))
+	out.Write(asPublic)
+
+	// 0x02 marks the last (and only) record.
+	out.Write(gcm.Seal(nil, nonce, append(plain, 0x02), nil))
+	return out.Bytes(), nil
+}
+
+func decodeB64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,249 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statuses an event can be in. SKIPPED and CANCELLED are terminal like SENT.
+const (
+	StatusPending    = "PENDING"
+	StatusProcessing = "PROCESSING"
+	StatusSent       = "SENT"
+	StatusFailed     = "FAILED"
+	StatusDead       = "DEAD"
+	StatusSkipped    = "SKIPPED"
+	StatusCancelled  = "CANCELLED"
+)
+
+// DefaultRetentionDays is how long SENT, SKIPPED and CANCELLED events are
+// kept.
+const DefaultRetentionDays = 30
+
+var (
+	ErrNotFound      = errors.New("outbox event not found")
+	ErrInvalidFilter = errors.New("invalid outbox filter")
+	ErrEmptySelector = errors.New("ids or a filter is required")
+)
+
+// EventRow is an outbox event as the admin API shows it.
+type EventRow struct {
+	ID            string          `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   *string         `json:"aggregate_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	Status        string          `json:"status"`
+	AttemptCount  int             `json:"attempt_count"`
+	NextRetryAt   *time.Time      `json:"next_retry_at"`
+	LastError     *string         `json:"last_error"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+type LogEntry struct {
+	Attempt   int       `json:"attempt"`
+	Status    string    `json:"status"`
+	Error     *string   `json:"error"`
+	Actor     *string   `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type EventDetail struct {
+	EventRow
+	History []LogEntry `json:"history"`
+}
+
+// Filter selects events by status, type and creation time. Zero fields match
+// everything.
+type Filter struct {
+	Statuses  []string
+	EventType string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+func validStatus(s string) bool {
+	switch s {
+	case StatusPending, StatusProcessing, StatusSent, StatusFailed, StatusDead, StatusSkipped, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (f Filter) empty() bool {
+	return len(f.Statuses) == 0 && f.EventType == "" && f.From.IsZero() && f.To.IsZero()
+}
+
+// where builds the WHERE clause; args are numbered from 1.
+func (f Filter) where() (string, []any, error) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(f.Statuses) > 0 {
+		for _, s := range f.Statuses {
+			if !validStatus(s) {
+				return "", nil, ErrInvalidFilter
+			}
+		}
+		conds = append(conds, "status = ANY("+arg(f.Statuses)+"::text[])")
+	}
+	if f.EventType != "" {
+		conds = append(conds, "event_type = "+arg(f.EventType))
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_at >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_at < "+arg(f.To))
+	}
+	if len(conds) == 0 {
+		return "TRUE", args, nil
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+const eventColumns = `id, aggregate_type, aggregate_id, event_type, payload, status, attempt_count, next_retry_at, last_error, created_at, updated_at`
+
+func scanEvent(sc interface{ Scan(...any) error }, e *EventRow) error {
+	return sc.Scan(&e.ID, &e.AggregateType, &e.AggregateID, &e.EventType, &e.Payload, &e.Status,
+		&e.AttemptCount, &e.NextRetryAt, &e.LastError, &e.CreatedAt, &e.UpdatedAt)
+}
+
+// List returns matching events newest first, and the total match count.
+func (r *Repo) List(ctx context.Context, f Filter) ([]EventRow, int, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, 0, err
+	}
+	if f.Limit <= 0 || f.Limit > 200 {
+		f.Limit = 50
+	}
+
+	var total int
+	if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox_events WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, fmt.Sprintf(`
+SELECT %s FROM outbox_events
+WHERE %s
+ORDER BY created_at DESC, id
+LIMIT %d OFFSET %d
+`, eventColumns, where, f.Limit, max(f.Offset, 0)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := []EventRow{}
+	for rows.Next() {
+		var e EventRow
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, e)
+	}
+	return out, total, rows.Err()
+}
+
+func (r *Repo) Get(ctx context.Context, id string) (EventDetail, error) {
+	var d EventDetail
+	err := scanEvent(r.DB.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM outbox_events WHERE id::text = $1`, id), &d.EventRow)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return d, ErrNotFound
+		}
+		return d, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT attempt, status, error, actor, created_at
+FROM outbox_event_log WHERE event_id = $1 ORDER BY id
+`, d.ID)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+
+	d.History = []LogEntry{}
+	for rows.Next() {
+		var l LogEntry
+		if err := rows.Scan(&l.Attempt, &l.Status, &l.Error, &l.Actor, &l.CreatedAt); err != nil {
+			return d, err
+		}
+		d.History = append(d.History, l)
+	}
+	return d, rows.Err()
+}
+
+// Requeue moves DEAD or FAILED events back to PENDING with a fresh attempt
+// budget. Select by ids, or by filter when ids is empty. Other statuses are
+// left alone; the count of requeued events is returned.
+func (r *Repo) Requeue(ctx context.Context, ids []string, f Filter, actor string) (int64, error) {
+	return r.transition(ctx, ids, f, actor, []string{StatusDead, StatusFailed}, `
+SET status='PENDING', attempt_count=0, next_retry_at=NULL, updated_at=NOW()`, "REQUEUED")
+}
+
+// Cancel stops PENDING or FAILED events that have not gone out yet.
+func (r *Repo) Cancel(ctx context.Context, ids []string, f Filter, actor string) (int64, error) {
+	return r.transition(ctx, ids, f, actor, []string{StatusPending, StatusFailed}, `
+SET status='CANCELLED', next_retry_at=NULL, updated_at=NOW()`, "CANCELLED")
+}
+
+func (r *Repo) transition(ctx context.Context, ids []string, f Filter, actor string, from []string, set, logStatus string) (int64, error) {
+	if len(ids) == 0 && f.empty() {
+		return 0, ErrEmptySelector
+	}
+
+	where, args := "", []any{}
+	if len(ids) > 0 {
+		args = append(args, ids)
+		where = "id::text = ANY($1::text[])"
+	} else {
+		var err error
+		if where, args, err = f.where(); err != nil {
+			return 0, err
+		}
+	}
+	args = append(args, from, logStatus, actor)
+	n := len(args)
+
+	res, err := r.DB.ExecContext(ctx, fmt.Sprintf(`
+WITH u AS (
+  UPDATE outbox_events %s
+  WHERE %s AND status = ANY($%d::text[])
+  RETURNING id, attempt_count
+)
+INSERT INTO outbox_event_log (event_id, attempt, status, actor)
+SELECT id, attempt_count, $%d, $%d FROM u
+`, set, where, n-2, n-1, n), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeSent deletes SENT, SKIPPED and CANCELLED events last touched before
+// cutoff; DEAD ones stay until someone deals with them. Their log rows go
+// with them (ON DELETE CASCADE).
+func (r *Repo) PurgeSent(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.DB.ExecContext(ctx, `
+DELETE FROM outbox_events WHERE status IN ('SENT', 'SKIPPED', 'CANCELLED') AND updated_at < $1
+`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
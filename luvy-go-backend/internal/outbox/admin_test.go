@@ -0,0 +1,61 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"luvy-go-backend/internal/pgtest"
+)
+
+func TestPurgeSentKeepsDeadAndRecentEvents(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := NewRepo(db)
+	ctx := context.Background()
+
+	old := time.Now().AddDate(0, 0, -40)
+	for _, st := range []struct {
+		status  string
+		updated time.Time
+	}{
+		{StatusSent, old},
+		{StatusSkipped, old},
+		{StatusCancelled, old},
+		{StatusDead, old},
+		{StatusSent, time.Now()},
+	} {
+		if err := repo.Enqueue(ctx, "test", nil, "TEST", map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`
+UPDATE outbox_events SET status=$1, updated_at=$2
+WHERE id = (SELECT id FROM outbox_events WHERE status='PENDING' ORDER BY created_at LIMIT 1)
+`, st.status, st.updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := repo.PurgeSent(ctx, time.Now().AddDate(0, 0, -DefaultRetentionDays))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("purged %d events, want the old SENT, SKIPPED and CANCELLED ones", n)
+	}
+	var left []string
+	rows, err := db.Query(`SELECT status FROM outbox_events ORDER BY status`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		left = append(left, s)
+	}
+	if len(left) != 2 || left[0] != StatusDead || left[1] != StatusSent {
+		t.Fatalf("left %v, want DEAD and the recent SENT", left)
+	}
+}
@@ -0,0 +1,267 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"luvy-go-backend/internal/inbox"
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/notifications/prefs"
+	"luvy-go-backend/internal/notifications/push"
+)
+
+// RealtimePublisher hands a USER_EVENT payload to connected clients. A
+// payload it cannot decode should come back as a Permanent error; anything
+// else is retried.
+type RealtimePublisher interface {
+	PublishRaw(ctx context.Context, id string, payload json.RawMessage) error
+}
+
+// AttachmentSource loads the files an EMAIL_SEND payload refers to by id,
+// so large attachments never sit in the event payload. It returns
+// ErrAttachmentGone for files that were deleted or have expired.
+type AttachmentSource interface {
+	Attachment(ctx context.Context, id string) (email.Attachment, error)
+}
+
+var ErrAttachmentGone = errors.New("attachment no longer available")
+
+// Dispatcher holds the delivery channels for the built-in event types.
+// Nil channels leave their events failing until configured.
+type Dispatcher struct {
+	Email    email.Mailer
+	Realtime RealtimePublisher
+	Push     *push.Sender
+	Inbox    *inbox.Writer
+
+	// Prefs, when set, is consulted before every email, push and in-app
+	// notification; Links adds one-click unsubscribe to emails users can
+	// opt out of.
+	Prefs *prefs.Checker
+	Links *prefs.Links
+
+	Attachments AttachmentSource // required for emails with attachments
+}
+
+type EmailSendPayload struct {
+	To       string            `json:"to"`
+	UserID   string            `json:"user_id,omitempty"`
+	Template string            `json:"template"`
+	Locale   string            `json:"locale,omitempty"`
+	Data     map[string]string `json:"data"`
+
+	Attachments []string `json:"attachments,omitempty"` // ids for Dispatcher.Attachments
+}
+
+func (p EmailSendPayload) Validate() error {
+	if p.To == "" {
+		return errors.New("to is required")
+	}
+	return email.Validate(email.Template(p.Template), p.Data)
+}
+
+// PushSendPayload targets every registered device of one user.
+type PushSendPayload struct {
+	UserID   string            `json:"user_id"`
+	Category string            `json:"category,omitempty"` // default transactional
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+func (p PushSendPayload) Validate() error {
+	if p.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if p.Category != "" && !prefs.ValidCategory(p.Category) {
+		return errors.New("unknown category " + p.Category)
+	}
+	return nil
+}
+
+// InAppPayload puts a template's short form into the user's inbox.
+type InAppPayload struct {
+	UserID   string            `json:"user_id"`
+	Template string            `json:"template"`
+	Locale   string            `json:"locale,omitempty"`
+	Data     map[string]string `json:"data"`
+}
+
+func (p InAppPayload) Validate() error {
+	if p.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	return email.Validate(email.Template(p.Template), p.Data)
+}
+
+// Register adds the built-in event types to reg.
+func (d *Dispatcher) Register(reg *Registry, repo *Repo, log *zap.Logger) {
+	Register(reg, "EMAIL_SEND", Policy{Timeout: time.Minute}, d.sendEmail)
+	reg.RegisterRaw("USER_EVENT", Policy{Timeout: 5 * time.Second, MaxAttempts: 3}, d.publishRealtime)
+	Register(reg, "PUSH_SEND", Policy{Timeout: 30 * time.Second}, func(ctx context.Context, ev Event, p PushSendPayload) error {
+		return d.sendPush(ctx, ev, p, log)
+	})
+	Register(reg, "INAPP_NOTIFY", Policy{Timeout: 10 * time.Second}, func(ctx context.Context, ev Event, p InAppPayload) error {
+		return d.notifyInApp(ctx, ev, p, repo, log)
+	})
+}
+
+func (d *Dispatcher) sendEmail(ctx context.Context, _ Event, p EmailSendPayload) error {
+	if d.Email == nil {
+		return fmt.Errorf("email %w", errNotConfigured)
+	}
+
+	spec := email.Templates()[email.Template(p.Template)]
+	if p.UserID == "" && d.Prefs != nil {
+		uid, err := d.Prefs.UserIDByEmail(ctx, p.To)
+		if err != nil {
+			return err
+		}
+		p.UserID = uid
+	}
+	if err := d.permitted(ctx, p.UserID, prefs.ChannelEmail, spec.Category); err != nil {
+		return err
+	}
+
+	headers, data := d.unsubscribe(p.UserID, spec.Category, p.Data)
+	msg, err := email.Render(email.Template(p.Template), p.Locale, data)
+	if err != nil {
+		// template/data problems do not fix themselves on retry
+		return Permanent(err)
+	}
+	m := msg.Message(p.To)
+	m.Headers = headers
+	for _, id := range p.Attachments {
+		if d.Attachments == nil {
+			return fmt.Errorf("attachments %w", errNotConfigured)
+		}
+		a, err := d.Attachments.Attachment(ctx, id)
+		if errors.Is(err, ErrAttachmentGone) {
+			return Permanent(err)
+		}
+		if err != nil {
+			return err
+		}
+		m.Attachments = append(m.Attachments, a)
+	}
+	return d.Email.Send(ctx, m)
+}
+
+func (d *Dispatcher) publishRealtime(ctx context.Context, ev Event) error {
+	if d.Realtime == nil {
+		return fmt.Errorf("realtime %w", errNotConfigured)
+	}
+	return d.Realtime.PublishRaw(ctx, ev.ID, ev.Payload)
+}
+
+func (d *Dispatcher) sendPush(ctx context.Context, ev Event, p PushSendPayload, log *zap.Logger) error {
+	if d.Push == nil {
+		return fmt.Errorf("push %w", errNotConfigured)
+	}
+	if p.Category == "" {
+		p.Category = prefs.CategoryTransactional
+	}
+	if err := d.permitted(ctx, p.UserID, prefs.ChannelPush, p.Category); err != nil {
+		return err
+	}
+
+	res, err := d.Push.SendToUser(ctx, p.UserID, push.Message{Title: p.Title, Body: p.Body, Data: p.Data})
+	if err != nil {
+		return err
+	}
+	// Retry only when nothing reached a device; retrying a partial fan-out
+	// would duplicate the notification on the devices that got it.
+	if res.Sent == 0 && res.Failed > 0 {
+		return res.LastErr
+	}
+	if res.Failed > 0 {
+		log.Warn("push partially failed", zap.String("id", ev.ID), zap.Int("failed", res.Failed), zap.Error(res.LastErr))
+	}
+	log.Info("push delivered", zap.String("id", ev.ID), zap.Int("devices", res.Sent), zap.Int("pruned", res.Pruned))
+	return nil
+}
+
+func (d *Dispatcher) notifyInApp(ctx context.Context, ev Event, p InAppPayload, repo *Repo, log *zap.Logger) error {
+	if d.Inbox == nil {
+		return fmt.Errorf("inbox %w", errNotConfigured)
+	}
+
+	spec := email.Templates()[email.Template(p.Template)]
+	if err := d.permitted(ctx, p.UserID, prefs.ChannelInApp, spec.Category); err != nil {
+		return err
+	}
+
+	title, body, err := email.RenderInApp(email.Template(p.Template), p.Locale, p.Data)
+	if err != nil {
+		return Permanent(err)
+	}
+	id, err := d.Inbox.Insert(ctx, inbox.Entry{
+		UserID:   p.UserID,
+		Category: spec.Category,
+		Type:     p.Template,
+		Title:    title,
+		Body:     body,
+		Data:     p.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Let open apps bump their badge; replayable like any USER_EVENT. The
+	// entry is already stored, so a failure here must not cause a retry.
+	uid, _ := strconv.ParseUint(p.UserID, 10, 64)
+	if err := repo.Enqueue(ctx, "user", nil, "USER_EVENT", map[string]any{
+		"user_id": uid,
+		"type":    "notification",
+		"data":    map[string]any{"id": id, "title": title, "body": body},
+		"at":      time.Now(),
+	}); err != nil {
+		log.Warn("notification event enqueue failed", zap.String("id", ev.ID), zap.Error(err))
+	}
+	return nil
+}
+
+// permitted applies notification preferences, returning Skip or DeferUntil
+// when the event must not go out now.
+func (d *Dispatcher) permitted(ctx context.Context, userID, channel, category string) error {
+	if d.Prefs == nil || userID == "" {
+		return nil
+	}
+	dec, err := d.Prefs.Check(ctx, userID, channel, category, time.Now())
+	if err != nil {
+		return fmt.Errorf("preferences: %w", err)
+	}
+	if !dec.Allowed {
+		return Skip(dec.Reason)
+	}
+	if !dec.DeferUntil.IsZero() {
+		return DeferUntil(dec.DeferUntil, dec.Reason)
+	}
+	return nil
+}
+
+// unsubscribe returns List-Unsubscribe headers and a copy of data with
+// unsubscribe_url for the footer. Security mail gets neither.
+func (d *Dispatcher) unsubscribe(userID, category string, data map[string]string) (map[string]string, map[string]string) {
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if d.Links == nil || err != nil || category == prefs.CategorySecurity {
+		return nil, data
+	}
+	link := d.Links.URL(uint(id), prefs.ChannelEmail, category)
+
+	out := make(map[string]string, len(data)+1)
+	for k, v := range data {
+		out[k] = v
+	}
+	out["unsubscribe_url"] = link
+	return map[string]string{
+		"List-Unsubscribe":      "<" + link + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}, out
+}
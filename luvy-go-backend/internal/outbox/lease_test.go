@@ -0,0 +1,263 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"luvy-go-backend/internal/pgtest"
+)
+
+func eventState(t *testing.T, db *sql.DB, id string) (status string, lockedBy *string) {
+	t.Helper()
+	if err := db.QueryRow(`SELECT status, locked_by FROM outbox_events WHERE id=$1`, id).Scan(&status, &lockedBy); err != nil {
+		t.Fatal(err)
+	}
+	return status, lockedBy
+}
+
+func TestExpiredLeaseCannotOverwriteNewOwner(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := NewRepo(db)
+	ctx := context.Background()
+
+	if err := repo.Enqueue(ctx, "test", nil, "TEST", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	// a zero lease is expired as soon as the claim commits
+	first, err := repo.ClaimBatch(ctx, "w1", 10, 0)
+	if err != nil || len(first) != 1 {
+		t.Fatalf("claim w1: %v %v", first, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n, err := repo.ReapExpired(ctx, 8); err != nil || n != 1 {
+		t.Fatalf("reap: %d %v", n, err)
+	}
+	second, err := repo.ClaimBatch(ctx, "w2", 10, time.Minute)
+	if err != nil || len(second) != 1 || second[0].ID != first[0].ID {
+		t.Fatalf("claim w2: %v %v", second, err)
+	}
+	id := first[0].ID
+
+	stale := map[string]error{
+		"extend":  repo.Extend(ctx, id, "w1", time.Minute),
+		"sent":    repo.MarkSent(ctx, id, "w1"),
+		"skipped": repo.MarkSkipped(ctx, id, "w1", "opted out"),
+		"defer":   repo.Defer(ctx, id, "w1", time.Now().Add(time.Hour)),
+		"failed":  repo.MarkFailed(ctx, id, "w1", 2, "boom", time.Now(), false),
+	}
+	for op, err := range stale {
+		if !errors.Is(err, ErrLeaseLost) {
+			t.Errorf("%s by expired owner: got %v, want ErrLeaseLost", op, err)
+		}
+	}
+	if status, by := eventState(t, db, id); status != "PROCESSING" || by == nil || *by != "w2" {
+		t.Fatalf("state after stale updates: %s %v", status, by)
+	}
+
+	if err := repo.Extend(ctx, id, "w2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkSent(ctx, id, "w2"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := eventState(t, db, id); status != "SENT" {
+		t.Fatalf("status %s, want SENT", status)
+	}
+	// terminal rows are not PROCESSING any more, so a repeat is refused too
+	if err := repo.MarkSent(ctx, id, "w2"); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("second MarkSent: %v", err)
+	}
+}
+
+func TestWorkerDropsOutcomeWhenLeaseIsTaken(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := NewRepo(db)
+	ctx := context.Background()
+
+	w := NewWorker(repo, nil, zap.NewNop())
+	w.ID = "w1"
+	Register(w.Registry, "TEST", Policy{}, func(ctx context.Context, ev Event, _ map[string]string) error {
+		// the lease runs out mid-handler and another worker claims the event
+		_, err := db.ExecContext(ctx, `UPDATE outbox_events SET locked_by='w2' WHERE id=$1`, ev.ID)
+		return err
+	})
+
+	if err := repo.Enqueue(ctx, "test", nil, "TEST", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	evs, err := repo.ClaimBatch(ctx, w.ID, 10, time.Minute)
+	if err != nil || len(evs) != 1 {
+		t.Fatalf("claim: %v %v", evs, err)
+	}
+	w.handleOne(ctx, evs[0])
+
+	if status, by := eventState(t, db, evs[0].ID); status != "PROCESSING" || by == nil || *by != "w2" {
+		t.Fatalf("worker overwrote the new owner: %s %v", status, by)
+	}
+}
+
+func TestWorkerSkipsEventWhoseLeaseExpiredBeforeItsTurn(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := NewRepo(db)
+	ctx := context.Background()
+
+	w := NewWorker(repo, nil, zap.NewNop())
+	w.ID = "w1"
+	ran := false
+	Register(w.Registry, "TEST", Policy{}, func(context.Context, Event, map[string]string) error {
+		ran = true
+		return nil
+	})
+
+	if err := repo.Enqueue(ctx, "test", nil, "TEST", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	evs, err := repo.ClaimBatch(ctx, w.ID, 10, 0)
+	if err != nil || len(evs) != 1 {
+		t.Fatalf("claim: %v %v", evs, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := repo.ReapExpired(ctx, 8); err != nil {
+		t.Fatal(err)
+	}
+	w.handleOne(ctx, evs[0])
+
+	if ran {
+		t.Fatal("handler ran on a reaped event")
+	}
+	if status, _ := eventState(t, db, evs[0].ID); status != "FAILED" {
+		t.Fatalf("status %s, want FAILED from the reaper", status)
+	}
+}
+
+func TestClaimKeepsAggregateOrder(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := NewRepo(db)
+	ctx := context.Background()
+
+	agg := "42"
+	for _, n := range []string{"first", "second"} {
+		if err := repo.Enqueue(ctx, "receipt", &agg, "TEST", map[string]string{"n": n}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Enqueue(ctx, "notification", nil, "TEST", map[string]string{"n": "free"}); err != nil {
+		t.Fatal(err)
+	}
+
+	evs, err := repo.ClaimBatch(ctx, "w1", 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 2 {
+		t.Fatalf("claimed %d events, want the aggregate's first and the free one", len(evs))
+	}
+	var head Event
+	for _, ev := range evs {
+		if ev.AggregateID != nil {
+			head = ev
+		}
+	}
+	if string(head.Payload) != `{"n": "first"}` {
+		t.Fatalf("claimed %s before the aggregate's first event", head.Payload)
+	}
+
+	// a second worker must not get the aggregate's next event while the
+	// first is still being processed
+	if evs, err := repo.ClaimBatch(ctx, "w2", 10, time.Minute); err != nil || len(evs) != 0 {
+		t.Fatalf("w2 claimed %v %v", evs, err)
+	}
+	if err := repo.MarkSent(ctx, head.ID, "w1"); err != nil {
+		t.Fatal(err)
+	}
+	evs, err = repo.ClaimBatch(ctx, "w2", 10, time.Minute)
+	if err != nil || len(evs) != 1 || string(evs[0].Payload) != `{"n": "second"}` {
+		t.Fatalf("after the first was sent: %v %v", evs, err)
+	}
+}
+
+func TestWorkerDefersEventWhenRateLimitOutlastsLease(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := NewRepo(db)
+	ctx := context.Background()
+
+	w := NewWorker(repo, nil, zap.NewNop())
+	w.ID = "w1"
+	w.Lease = 2 * time.Second
+	sent := 0
+	// one token, then one every 100s: the second event cannot get one
+	// while its lease lasts
+	Register(w.Registry, "TEST", Policy{RatePerSecond: 0.01, Burst: 1}, func(context.Context, Event, map[string]string) error {
+		sent++
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := repo.Enqueue(ctx, "test", nil, "TEST", map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evs, err := repo.ClaimBatch(ctx, w.ID, 10, w.Lease)
+	if err != nil || len(evs) != 2 {
+		t.Fatalf("claim: %v %v", evs, err)
+	}
+	start := time.Now()
+	for _, ev := range evs {
+		w.handleOne(ctx, ev)
+	}
+	if d := time.Since(start); d > w.Lease {
+		t.Fatalf("worker held the events for %v, past the %v lease", d, w.Lease)
+	}
+	if sent != 1 {
+		t.Fatalf("handler ran %d times, want 1", sent)
+	}
+
+	var attempts int
+	status, by := eventState(t, db, evs[1].ID)
+	if err := db.QueryRow(`SELECT attempt_count FROM outbox_events WHERE id=$1`, evs[1].ID).Scan(&attempts); err != nil {
+		t.Fatal(err)
+	}
+	if status != "PENDING" || by != nil || attempts != 0 {
+		t.Fatalf("rate-limited event: %s %v attempts=%d, want PENDING unlocked with no attempt", status, by, attempts)
+	}
+}
+
+func TestClaimKeepsOrderWithinOneTransaction(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := NewRepo(db)
+	ctx := context.Background()
+
+	// NOW() is the same for every insert in a transaction
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := "7"
+	for i := 0; i < 5; i++ {
+		if err := repo.EnqueueTx(ctx, tx, "user_events", &agg, "TEST", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		evs, err := repo.ClaimBatch(ctx, "w1", 10, time.Minute)
+		if err != nil || len(evs) != 1 {
+			t.Fatalf("claim %d: %v %v", i, evs, err)
+		}
+		if want := `{"n": ` + strconv.Itoa(i) + `}`; string(evs[0].Payload) != want {
+			t.Fatalf("claimed %s, want %s", evs[0].Payload, want)
+		}
+		if err := repo.MarkSent(ctx, evs[0].ID, "w1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
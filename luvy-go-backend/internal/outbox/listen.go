@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+)
+
+// NotifyChannel is where the outbox_events insert trigger announces new rows.
+const NotifyChannel = "outbox_events"
+
+// Listen holds one connection in LISTEN on NotifyChannel and signals wake on
+// every notification (coalesced: wake needs a buffer of one). It reconnects
+// after errors and returns when ctx is done.
+func Listen(ctx context.Context, db *sql.DB, wake chan<- struct{}, log *zap.Logger) {
+	Subscribe(ctx, db, NotifyChannel, func(string) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}, log)
+}
+
+// Subscribe holds one connection in LISTEN on channel and calls fn with each
+// notification's payload, in order, on that connection's goroutine.
+// Notifications sent while it reconnects are lost, so callers need another
+// way to catch up. It returns when ctx is done.
+func Subscribe(ctx context.Context, db *sql.DB, channel string, fn func(payload string), log *zap.Logger) {
+	for ctx.Err() == nil {
+		err := listenOnce(ctx, db, channel, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("listen interrupted, reconnecting", zap.String("channel", channel), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, db *sql.DB, channel string, fn func(string)) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		sc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("listen needs the pgx driver, got %T", driverConn)
+		}
+		pg := sc.Conn()
+		if _, err := pg.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		for {
+			n, err := pg.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			fn(n.Payload)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a single handler run when its Policy sets none.
+const DefaultTimeout = 30 * time.Second
+
+// Policy is the per-event-type delivery contract. Zero fields fall back to
+// the worker defaults.
+type Policy struct {
+	Timeout     time.Duration
+	MaxAttempts int
+	Backoff     func(attempt int) time.Duration
+
+	// RatePerSecond caps how often handlers of this type run, across all
+	// of the worker's goroutines; Burst allows short spikes. Zero is
+	// unlimited.
+	RatePerSecond float64
+	Burst         int
+}
+
+// Validator is implemented by payload types that check themselves after
+// decoding. A failing payload is dead on arrival; retrying cannot fix it.
+type Validator interface {
+	Validate() error
+}
+
+type registration struct {
+	policy  Policy
+	limiter *limiter
+	handle  func(ctx context.Context, ev Event) error
+}
+
+// Registry maps event types to handlers. Packages add their own event types
+// with Register; the worker only looks them up.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]registration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: map[string]registration{}}
+}
+
+// Register adds a typed handler. The payload is decoded into T (unknown
+// fields rejected) and validated before fn runs. Registering an event type
+// twice panics, as it is a wiring bug.
+func Register[T any](r *Registry, eventType string, policy Policy, fn func(ctx context.Context, ev Event, payload T) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.handlers[eventType]; dup {
+		panic("outbox: handler already registered for " + eventType)
+	}
+	r.handlers[eventType] = registration{
+		policy:  policy,
+		limiter: limiterFor(policy),
+		handle: func(ctx context.Context, ev Event) error {
+			p, err := decode[T](ev.Payload)
+			if err != nil {
+				return Permanent(fmt.Errorf("invalid payload: %w", err))
+			}
+			return fn(ctx, ev, p)
+		},
+	}
+}
+
+// RegisterRaw adds a handler that takes the payload as is.
+func (r *Registry) RegisterRaw(eventType string, policy Policy, fn func(ctx context.Context, ev Event) error) {
+	Register(r, eventType, policy, func(ctx context.Context, ev Event, _ json.RawMessage) error {
+		return fn(ctx, ev)
+	})
+}
+
+// Limit changes the rate limit of a registered event type, e.g. from
+// configuration to match what the SMTP provider allows.
+func (r *Registry) Limit(eventType string, perSecond float64, burst int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reg, ok := r.handlers[eventType]
+	if !ok {
+		return
+	}
+	reg.policy.RatePerSecond, reg.policy.Burst = perSecond, burst
+	reg.limiter = limiterFor(reg.policy)
+	r.handlers[eventType] = reg
+}
+
+func limiterFor(p Policy) *limiter {
+	if p.RatePerSecond <= 0 {
+		return nil
+	}
+	return newLimiter(p.RatePerSecond, p.Burst)
+}
+
+// EventTypes lists what is registered, for diagnostics.
+func (r *Registry) EventTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.handlers))
+	for t := range r.handlers {
+		out = append(out, t)
+	}
+	return out
+}
+
+func (r *Registry) lookup(eventType string) (registration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	reg, ok := r.handlers[eventType]
+	return reg, ok
+}
+
+func decode[T any](raw json.RawMessage) (T, error) {
+	var p T
+	if _, ok := any(&p).(*json.RawMessage); ok {
+		return any(raw).(T), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, err
+	}
+	if v, ok := any(&p).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return p, err
+		}
+	} else if v, ok := any(p).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// Outcomes other than success or a retryable error. Handlers return these
+// to steer what the worker does with the event.
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks the event DEAD immediately, without further retries.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type skipError struct{ reason string }
+
+func (e *skipError) Error() string { return "skipped: " + e.reason }
+
+// Skip closes the event as SKIPPED, e.g. because the user opted out.
+func Skip(reason string) error {
+	return &skipError{reason: reason}
+}
+
+type deferError struct {
+	until  time.Time
+	reason string
+}
+
+func (e *deferError) Error() string { return "deferred: " + e.reason }
+
+// DeferUntil puts the event back in the queue until t without counting an
+// attempt, e.g. for quiet hours.
+func DeferUntil(t time.Time, reason string) error {
+	return &deferError{until: t, reason: reason}
+}
+
+var errNotConfigured = errors.New("dispatcher not configured")
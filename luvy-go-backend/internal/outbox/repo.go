@@ -4,17 +4,40 @@ import (
 "context"
 "database/sql"
 "encoding/json"
+"errors"
+"strconv"
 "time"
+
+"luvy-go-backend/internal/notifications/email"
 )
 
 type Repo struct{ DB *sql.DB }
 func NewRepo(db *sql.DB) *Repo { return &Repo{DB: db} }
 
+// DBTX is what the enqueue methods write through: *sql.DB, *sql.Tx, or a
+// gorm transaction's Statement.ConnPool.
+type DBTX interface {
+ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// AggregateID formats a gorm primary key for the aggregate_id column.
+func AggregateID(id uint) *string {
+s := strconv.FormatUint(uint64(id), 10)
+return &s
+}
+
 func (r *Repo) Enqueue(ctx context.Context, aggregateType string, aggregateID *string, eventType string, payload any) error {
+return r.EnqueueTx(ctx, r.DB, aggregateType, aggregateID, eventType, payload)
+}
+
+// EnqueueTx writes the event through q, so passing the caller's transaction
+// makes the event commit or roll back together with the caller's writes.
+func (r *Repo) EnqueueTx(ctx context.Context, q DBTX, aggregateType string, aggregateID *string, eventType string, payload any) error {
 b, err := json.Marshal(payload)
 if err != nil { return err }
 
-_, err = r.DB.ExecContext(ctx, `
+_, err = q.ExecContext(ctx, `
 INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload)
 VALUES ($1, $2, $3, $4::jsonb)
 `, aggregateType, aggregateID, eventType, string(b))
@@ -22,54 +45,183 @@ VALUES ($1, $2, $3, $4::jsonb)
 return err
 }
 
+// EnqueueEmail validates the template data up front; a payload that cannot
+// render is rejected here instead of dying in the worker. When the recipient
+// is a user, the same message also goes to their in-app inbox; both events
+// are written in one transaction, so neither goes out without the other.
+func (r *Repo) EnqueueEmail(ctx context.Context, p EmailSendPayload) error {
+tx, err := r.DB.BeginTx(ctx, nil)
+if err != nil { return err }
+defer tx.Rollback()
+if err := r.EnqueueEmailTx(ctx, tx, p); err != nil { return err }
+return tx.Commit()
+}
+
+func (r *Repo) EnqueueEmailTx(ctx context.Context, q DBTX, p EmailSendPayload) error {
+if err := email.Validate(email.Template(p.Template), p.Data); err != nil { return err }
+p.Locale = email.NormalizeLocale(p.Locale)
+
+if p.UserID == "" {
+var id int64
+err := q.QueryRowContext(ctx, `SELECT id FROM users WHERE email=$1`, p.To).Scan(&id)
+if err != nil && !errors.Is(err, sql.ErrNoRows) { return err }
+if err == nil { p.UserID = strconv.FormatInt(id, 10) }
+}
+
+if err := r.EnqueueTx(ctx, q, "notification", nil, "EMAIL_SEND", p); err != nil { return err }
+if p.UserID == "" { return nil }
+return r.EnqueueTx(ctx, q, "notification", nil, "INAPP_NOTIFY", InAppPayload{
+UserID:   p.UserID,
+Template: p.Template,
+Locale:   p.Locale,
+Data:     p.Data,
+})
+}
+
 type Event struct {
 ID string
+AggregateType string
+AggregateID *string
 EventType string
 Payload json.RawMessage
 AttemptCount int
+
+// leasedUntil is when the claim's lease runs out, by this process's clock
+// and on the early side: it is taken before the claim query runs.
+leasedUntil time.Time
 }
 
-func (r *Repo) ClaimBatch(ctx context.Context, limit int) ([]Event, error) {
+// ClaimBatch leases up to limit due events to workerID: new PENDING ones and
+// FAILED ones whose next_retry_at has passed. The lease runs until
+// locked_until; ReapExpired returns rows whose worker never finished.
+//
+// Only the oldest open event of an aggregate is claimable, so events sharing
+// an aggregate (type and id) go out in order even across workers.
+func (r *Repo) ClaimBatch(ctx context.Context, workerID string, limit int, lease time.Duration) ([]Event, error) {
+until := time.Now().Add(lease)
 rows, err := r.DB.QueryContext(ctx, `
 WITH cte AS (
-  SELECT id
-  FROM outbox_events
-  WHERE status='PENDING'
-    AND (next_retry_at IS NULL OR next_retry_at <= NOW())
-  ORDER BY created_at ASC
+  SELECT e.id
+  FROM outbox_events e
+  WHERE e.status IN ('PENDING', 'FAILED')
+    AND (e.next_retry_at IS NULL OR e.next_retry_at <= NOW())
+    AND (e.aggregate_id IS NULL OR NOT EXISTS (
+      SELECT 1 FROM outbox_events prev
+      WHERE prev.aggregate_type = e.aggregate_type
+        AND prev.aggregate_id = e.aggregate_id
+        AND prev.status IN ('PENDING', 'PROCESSING', 'FAILED')
+        AND (prev.created_at, prev.seq) < (e.created_at, e.seq)
+    ))
+  ORDER BY e.created_at ASC, e.seq ASC
   LIMIT $1
   FOR UPDATE SKIP LOCKED
 )
 UPDATE outbox_events o
-SET status='PROCESSING', updated_at=NOW()
+SET status='PROCESSING', locked_by=$2, locked_until=NOW() + make_interval(secs => $3), updated_at=NOW()
 FROM cte
 WHERE o.id = cte.id
-RETURNING o.id, o.event_type, o.payload, o.attempt_count
-`, limit)
+RETURNING o.id, o.aggregate_type, o.aggregate_id, o.event_type, o.payload, o.attempt_count
+`, limit, workerID, lease.Seconds())
 if err != nil { return nil, err }
 defer rows.Close()
 
 var out []Event
 for rows.Next() {
 var e Event
-if err := rows.Scan(&e.ID, &e.EventType, &e.Payload, &e.AttemptCount); err != nil { return nil, err }
+if err := rows.Scan(&e.ID, &e.AggregateType, &e.AggregateID, &e.EventType, &e.Payload, &e.AttemptCount); err != nil { return nil, err }
+e.leasedUntil = until
 out = append(out, e)
 }
 return out, rows.Err()
 }
 
-func (r *Repo) MarkSent(ctx context.Context, id string) error {
-_, err := r.DB.ExecContext(ctx, `UPDATE outbox_events SET status='SENT', updated_at=NOW() WHERE id=$1`, id)
-return err
+// ReapExpired returns PROCESSING events with an expired lease to FAILED
+// (due now), counting the lost run as an attempt so an event that keeps
+// crashing its worker still ends up DEAD after maxAttempts.
+func (r *Repo) ReapExpired(ctx context.Context, maxAttempts int) (int64, error) {
+res, err := r.DB.ExecContext(ctx, `
+WITH u AS (
+  UPDATE outbox_events
+  SET status = CASE WHEN attempt_count + 1 >= $1 THEN 'DEAD' ELSE 'FAILED' END,
+      attempt_count = attempt_count + 1,
+      last_error = 'lease expired on ' || COALESCE(locked_by, 'unknown worker'),
+      next_retry_at = NOW(),
+      locked_by = NULL,
+      locked_until = NULL,
+      updated_at = NOW()
+  WHERE status = 'PROCESSING' AND locked_until < NOW()
+  RETURNING id, attempt_count, status, last_error
+)
+INSERT INTO outbox_event_log (event_id, attempt, status, error)
+SELECT id, attempt_count, status, last_error FROM u
+`, maxAttempts)
+if err != nil { return 0, err }
+return res.RowsAffected()
+}
+
+// ErrLeaseLost means the event is no longer PROCESSING under this worker's
+// lease: it expired and the reaper or another worker has taken the event
+// over. The outcome must then be dropped, not written over the new owner's.
+var ErrLeaseLost = errors.New("outbox: lease lost")
+
+// leased runs a terminal update that only applies while workerID still
+// holds the lease, reporting ErrLeaseLost otherwise.
+func (r *Repo) leased(ctx context.Context, query string, args ...any) error {
+res, err := r.DB.ExecContext(ctx, query, args...)
+if err != nil { return err }
+n, err := res.RowsAffected()
+if err != nil { return err }
+if n == 0 { return ErrLeaseLost }
+return nil
+}
+
+// Extend renews workerID's lease on one event to lease from now. The worker
+// calls it before each handler run, since a batch is claimed at once and
+// the events at its end may otherwise run on an expired lease.
+func (r *Repo) Extend(ctx context.Context, id, workerID string, lease time.Duration) error {
+return r.leased(ctx, `
+UPDATE outbox_events SET locked_until=NOW() + make_interval(secs => $3), updated_at=NOW()
+WHERE id=$1 AND status='PROCESSING' AND locked_by=$2
+`, id, workerID, lease.Seconds())
 }
 
-func (r *Repo) MarkFailed(ctx context.Context, id string, attempt int, errMsg string, nextRetry time.Time, dead bool) error {
+func (r *Repo) MarkSent(ctx context.Context, id, workerID string) error {
+return r.leased(ctx, `
+UPDATE outbox_events SET status='SENT', locked_by=NULL, locked_until=NULL, updated_at=NOW()
+WHERE id=$1 AND status='PROCESSING' AND locked_by=$2
+`, id, workerID)
+}
+
+// MarkFailed also appends the error to outbox_event_log, so the history
+// survives the next attempt overwriting last_error.
+func (r *Repo) MarkFailed(ctx context.Context, id, workerID string, attempt int, errMsg string, nextRetry time.Time, dead bool) error {
 status := "FAILED"
 if dead { status = "DEAD" }
-_, err := r.DB.ExecContext(ctx, `
-UPDATE outbox_events
-SET status=$2, attempt_count=$3, last_error=$4, next_retry_at=$5, updated_at=NOW()
-WHERE id=$1
-`, id, status, attempt, errMsg, nextRetry)
-return err
+return r.leased(ctx, `
+WITH u AS (
+  UPDATE outbox_events
+  SET status=$2, attempt_count=$3, last_error=$4, next_retry_at=$5, locked_by=NULL, locked_until=NULL, updated_at=NOW()
+  WHERE id=$1 AND status='PROCESSING' AND locked_by=$6
+  RETURNING id
+)
+INSERT INTO outbox_event_log (event_id, attempt, status, error)
+SELECT id, $3, $2, $4 FROM u
+`, id, status, attempt, errMsg, nextRetry, workerID)
+}
+
+// MarkSkipped closes an event that was deliberately not delivered, e.g. the
+// user opted out. SKIPPED is terminal like SENT.
+func (r *Repo) MarkSkipped(ctx context.Context, id, workerID, reason string) error {
+return r.leased(ctx, `
+UPDATE outbox_events SET status='SKIPPED', last_error=$3, locked_by=NULL, locked_until=NULL, updated_at=NOW()
+WHERE id=$1 AND status='PROCESSING' AND locked_by=$2
+`, id, workerID, reason)
+}
+
+// Defer puts an event back to PENDING until at without counting an attempt.
+func (r *Repo) Defer(ctx context.Context, id, workerID string, at time.Time) error {
+return r.leased(ctx, `
+UPDATE outbox_events SET status='PENDING', next_retry_at=$3, locked_by=NULL, locked_until=NULL, updated_at=NOW()
+WHERE id=$1 AND status='PROCESSING' AND locked_by=$2
+`, id, workerID, at)
 }
@@ -2,112 +2,267 @@
 
 import (
 "context"
-"encoding/json"
+"crypto/rand"
+"encoding/hex"
 "errors"
+"fmt"
+"hash/fnv"
+"os"
+"sync"
 "time"
 
 "go.uber.org/zap"
-
-"luvy-go-backend/internal/notifications/email"
 )
 
-type Dispatcher struct {
-Email *email.SMTPClient
-}
-
 type Worker struct {
-Repo       *Repo
-Dispatcher *Dispatcher
-Log        *zap.Logger
+Repo     *Repo
+Registry *Registry
+Log      *zap.Logger
+
+// ID identifies this worker's leases; Lease is how long a claimed event
+// may stay PROCESSING before the reaper hands it to someone else. Each
+// event's lease is renewed to cover its handler timeout right before the
+// handler runs.
+ID          string
+Lease       time.Duration
+ReapEvery   time.Duration
 
 BatchSize   int
 MaxAttempts int
 PollEvery   time.Duration
+
+// Concurrency is how many goroutines work a batch. Events of the same
+// aggregate always land on the same goroutine, in claim order.
+Concurrency int
+
+// Wake, when set, triggers an immediate poll instead of waiting for
+// PollEvery (see Listen).
+Wake <-chan struct{}
 }
 
+// NewWorker registers d's built-in event types; more can be added to
+// w.Registry before Run.
 func NewWorker(repo *Repo, d *Dispatcher, log *zap.Logger) *Worker {
+reg := NewRegistry()
+if d != nil {
+d.Register(reg, repo, log)
+}
 return &Worker{
 Repo:        repo,
-Dispatcher:  d,
+Registry:    reg,
 Log:         log,
+ID:          workerID(),
+Lease:       5 * time.Minute,
+ReapEvery:   30 * time.Second,
 BatchSize:   25,
 MaxAttempts: 8,
 PollEvery:   2 * time.Second,
+Concurrency: 4,
 }
 }
 
+func workerID() string {
+host, _ := os.Hostname()
+b := make([]byte, 4)
+_, _ = rand.Read(b)
+return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
 func (w *Worker) Run(ctx context.Context) error {
 if w.Log == nil {
 return errors.New("logger is nil")
 }
-w.Log.Info("outbox worker started")
+w.Log.Info("outbox worker started", zap.String("worker_id", w.ID), zap.Int("concurrency", w.Concurrency))
 
 t := time.NewTicker(w.PollEvery)
 defer t.Stop()
+reap := time.NewTicker(w.ReapEvery)
+defer reap.Stop()
 
 for {
 select {
 case <-ctx.Done():
 w.Log.Info("outbox worker stopped")
 return nil
+case <-reap.C:
+n, err := w.Repo.ReapExpired(ctx, w.MaxAttempts)
+if err != nil {
+w.Log.Error("reap expired leases failed", zap.Error(err))
+} else if n > 0 {
+w.Log.Warn("expired leases returned to queue", zap.Int64("count", n))
+}
 case <-t.C:
-events, err := w.Repo.ClaimBatch(ctx, w.BatchSize)
+w.drain(ctx)
+case <-w.Wake:
+w.drain(ctx)
+}
+}
+}
+
+// drain claims and processes batches back to back while they come back
+// full, so a backlog is not throttled to one batch per PollEvery.
+func (w *Worker) drain(ctx context.Context) {
+for ctx.Err() == nil {
+events, err := w.Repo.ClaimBatch(ctx, w.ID, w.BatchSize, w.Lease)
 if err != nil {
 w.Log.Error("claim batch failed", zap.Error(err))
-continue
+return
+}
+w.process(ctx, events)
+if len(events) < w.BatchSize {
+return
+}
+}
 }
+
+// process spreads a batch over Concurrency goroutines. Events are sharded
+// by aggregate_id so one aggregate's events run one after another; events
+// without an aggregate are spread round robin.
+func (w *Worker) process(ctx context.Context, events []Event) {
+n := w.Concurrency
+if n < 1 {
+n = 1
+}
+if n > len(events) {
+n = len(events)
+}
+if n <= 1 {
 for _, ev := range events {
 w.handleOne(ctx, ev)
 }
+return
 }
+
+shards := make([][]Event, n)
+for i, ev := range events {
+k := i % n
+if ev.AggregateID != nil {
+h := fnv.New32a()
+h.Write([]byte(ev.AggregateType + ":" + *ev.AggregateID))
+k = int(h.Sum32() % uint32(n))
 }
+shards[k] = append(shards[k], ev)
 }
 
-type EmailSendPayload struct {
-To       string            `json:"to"`
-Template string            `json:"template"`
-Data     map[string]string `json:"data"`
+var wg sync.WaitGroup
+for _, shard := range shards {
+if len(shard) == 0 {
+continue
+}
+wg.Add(1)
+go func(evs []Event) {
+defer wg.Done()
+for _, ev := range evs {
+w.handleOne(ctx, ev)
+}
+}(shard)
+}
+wg.Wait()
 }
 
+// handleOne runs the registered handler under its timeout and records the
+// outcome the handler asked for.
 func (w *Worker) handleOne(ctx context.Context, ev Event) {
 attempt := ev.AttemptCount + 1
 
-switch ev.EventType {
-case "EMAIL_SEND":
-var p EmailSendPayload
-if err := json.Unmarshal(ev.Payload, &p); err != nil {
-w.fail(ctx, ev.ID, attempt, "invalid payload: "+err.Error())
+reg, ok := w.Registry.lookup(ev.EventType)
+if !ok {
+// bilinmeyen event tipi => dead
+w.dead(ctx, ev.ID, attempt, "unknown event_type: "+ev.EventType)
 return
 }
 
-if w.Dispatcher == nil || w.Dispatcher.Email == nil {
-w.fail(ctx, ev.ID, attempt, "email dispatcher not configured")
+if reg.limiter != nil {
+// Wait for a token only while the claim's lease lasts, keeping a tenth
+// of it for the renewal; past that the reaper could hand the event to
+// another worker while this one sends it.
+wctx, cancel := context.WithDeadline(ctx, ev.leasedUntil.Add(-w.Lease/10))
+err := reg.limiter.Wait(wctx)
+cancel()
+if ctx.Err() != nil {
+// shutting down; the lease expires and the event is reaped
+return
+}
+if err != nil {
+// back to the queue without counting an attempt
+if err := w.Repo.Defer(ctx, ev.ID, w.ID, time.Now().Add(w.PollEvery)); err != nil {
+w.leaseError(ev.ID, "defer", err)
+return
+}
+w.Log.Info("event deferred", zap.String("id", ev.ID), zap.String("reason", "rate limit"))
 return
 }
+}
 
-sub, html := email.Render(email.Template(p.Template), p.Data)
-if err := w.Dispatcher.Email.SendHTML(p.To, sub, html); err != nil {
-w.fail(ctx, ev.ID, attempt, err.Error())
+timeout := reg.policy.Timeout
+if timeout <= 0 {
+timeout = DefaultTimeout
+}
+// Events further down the batch have been waiting since the claim; renew
+// the lease so it outlives the handler, or drop the event if it is gone.
+if err := w.Repo.Extend(ctx, ev.ID, w.ID, timeout+w.Lease); err != nil {
+w.leaseError(ev.ID, "extend", err)
 return
 }
+hctx, cancel := context.WithTimeout(ctx, timeout)
+err := reg.handle(hctx, ev)
+cancel()
 
-if err := w.Repo.MarkSent(ctx, ev.ID); err != nil {
-w.Log.Error("mark sent failed", zap.String("id", ev.ID), zap.Error(err))
+var (
+skip *skipError
+def  *deferError
+perm *permanentError
+)
+switch {
+case err == nil:
+if err := w.Repo.MarkSent(ctx, ev.ID, w.ID); err != nil {
+w.leaseError(ev.ID, "mark sent", err)
 return
 }
 w.Log.Info("event sent", zap.String("id", ev.ID), zap.String("type", ev.EventType))
+case errors.As(err, &skip):
+if err := w.Repo.MarkSkipped(ctx, ev.ID, w.ID, skip.reason); err != nil {
+w.leaseError(ev.ID, "mark skipped", err)
+return
+}
+w.Log.Info("event skipped", zap.String("id", ev.ID), zap.String("reason", skip.reason))
+case errors.As(err, &def):
+if err := w.Repo.Defer(ctx, ev.ID, w.ID, def.until); err != nil {
+w.leaseError(ev.ID, "defer", err)
+return
+}
+w.Log.Info("event deferred", zap.String("id", ev.ID), zap.String("reason", def.reason), zap.Time("until", def.until))
+case errors.As(err, &perm):
+w.dead(ctx, ev.ID, attempt, perm.Error())
 default:
-// bilinmeyen event tipi => dead
-w.dead(ctx, ev.ID, attempt, "unknown event_type: "+ev.EventType)
+w.fail(ctx, ev.ID, attempt, err.Error(), reg.policy)
 }
 }
 
-func (w *Worker) fail(ctx context.Context, id string, attempt int, msg string) {
-next := time.Now().Add(backoff(attempt))
-dead := attempt >= w.MaxAttempts
+// leaseError logs a failed state update. A lost lease is a warning: the
+// event belongs to another run now, which records its own outcome.
+func (w *Worker) leaseError(id, op string, err error) {
+if errors.Is(err, ErrLeaseLost) {
+w.Log.Warn("lease lost, outcome dropped", zap.String("id", id), zap.String("op", op))
+return
+}
+w.Log.Error(op+" failed", zap.String("id", id), zap.Error(err))
+}
+
+func (w *Worker) fail(ctx context.Context, id string, attempt int, msg string, p Policy) {
+wait := backoff
+if p.Backoff != nil {
+wait = p.Backoff
+}
+limit := w.MaxAttempts
+if p.MaxAttempts > 0 {
+limit = p.MaxAttempts
+}
+next := time.Now().Add(wait(attempt))
+dead := attempt >= limit
 
-if err := w.Repo.MarkFailed(ctx, id, attempt, msg, next, dead); err != nil {
-w.Log.Error("mark failed failed", zap.String("id", id), zap.Error(err))
+if err := w.Repo.MarkFailed(ctx, id, w.ID, attempt, msg, next, dead); err != nil {
+w.leaseError(id, "mark failed", err)
 return
 }
 
@@ -120,7 +275,10 @@ w.Log.Warn("event failed", zap.String("id", id), zap.Int("attempt", attempt), za
 
 func (w *Worker) dead(ctx context.Context, id string, attempt int, msg string) {
 next := time.Now().Add(24 * time.Hour)
-_ = w.Repo.MarkFailed(ctx, id, attempt, msg, next, true)
+if err := w.Repo.MarkFailed(ctx, id, w.ID, attempt, msg, next, true); err != nil {
+w.leaseError(id, "mark dead", err)
+return
+}
 w.Log.Error("event dead", zap.String("id", id), zap.String("err", msg))
 }
 
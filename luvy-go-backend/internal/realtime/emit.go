@@ -0,0 +1,127 @@
+package realtime
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"luvy-go-backend/internal/outbox"
+)
+
+// Emitter is what request handlers use to notify a user's clients.
+type Emitter interface {
+	Emit(ctx context.Context, userID uint, eventType string, data any) error
+}
+
+func newEvent(userID uint, eventType string, data any) (Event, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{UserID: userID, Type: eventType, Data: b, At: time.Now().UTC()}, nil
+}
+
+// TxEmitter is an Emitter that can queue an event in the caller's
+// transaction, so the event is sent if and only if the transaction commits.
+type TxEmitter interface {
+	Emitter
+	EmitTx(ctx context.Context, q outbox.DBTX, userID uint, eventType string, data any) error
+}
+
+// AggregateUserEvents orders each user's events in the outbox. It is kept
+// apart from the domain events' user aggregate so a retrying webhook never
+// holds up a live update.
+const AggregateUserEvents = "user_events"
+
+// OutboxEmitter queues events in outbox_events; the outbox worker hands them
+// to the hub, so nothing is lost if the process dies in between. A user's
+// events share an aggregate and are delivered in the order they were queued.
+type OutboxEmitter struct {
+	Repo *outbox.Repo
+}
+
+func (e OutboxEmitter) Emit(ctx context.Context, userID uint, eventType string, data any) error {
+	return e.EmitTx(ctx, e.Repo.DB, userID, eventType, data)
+}
+
+func (e OutboxEmitter) EmitTx(ctx context.Context, q outbox.DBTX, userID uint, eventType string, data any) error {
+	ev, err := newEvent(userID, eventType, data)
+	if err != nil {
+		return err
+	}
+	return e.Repo.EnqueueTx(ctx, q, AggregateUserEvents, outbox.AggregateID(userID), OutboxEventType, ev)
+}
+
+// DirectEmitter publishes straight to an in-process hub. It is used when the
+// server runs without the outbox (SQLite) and gives no delivery guarantees.
+type DirectEmitter struct {
+	Hub *Hub
+}
+
+func (e DirectEmitter) Emit(_ context.Context, userID uint, eventType string, data any) error {
+	ev, err := newEvent(userID, eventType, data)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	ev.ID = hex.EncodeToString(b)
+	e.Hub.Publish(ev)
+	return nil
+}
+
+// Replayer returns a user's events published after lastID.
+type Replayer interface {
+	Since(ctx context.Context, userID uint, lastID string) ([]Event, error)
+}
+
+// MaxReplay caps how many missed events a reconnecting client receives.
+const MaxReplay = 500
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// OutboxReplay reads already delivered events back out of outbox_events.
+type OutboxReplay struct {
+	DB *sql.DB
+}
+
+func (r OutboxReplay) Since(ctx context.Context, userID uint, lastID string) ([]Event, error) {
+	if !uuidRe.MatchString(lastID) {
+		return nil, nil
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT id, payload
+FROM outbox_events
+WHERE event_type=$1
+  AND status='SENT'
+  AND (payload->>'user_id')::bigint=$2
+  AND (created_at, seq) > (SELECT created_at, seq FROM outbox_events WHERE id=$3::uuid)
+ORDER BY created_at ASC, seq ASC
+LIMIT $4
+`, OutboxEventType, userID, lastID, MaxReplay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Event
+	for rows.Next() {
+		var id string
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+		var ev Event
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			continue
+		}
+		ev.ID = id
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,65 @@
+package realtime
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"luvy-go-backend/internal/outbox"
+)
+
+// FanoutChannel carries USER_EVENTs from the instance whose outbox worker
+// claimed them to every instance, where the user's connections may be.
+const FanoutChannel = "realtime_events"
+
+// Broadcast is the outbox's RealtimePublisher when clients are spread over
+// several instances: it NOTIFYs FanoutChannel and Relay on each instance
+// publishes to the local hub. The notification carries "<user id>:<event
+// id>" only, which stays under the 8000-byte NOTIFY limit; instances with
+// the user connected read the payload back from outbox_events.
+type Broadcast struct {
+	DB *sql.DB
+}
+
+func (b Broadcast) PublishRaw(ctx context.Context, id string, payload json.RawMessage) error {
+	var ev Event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return outbox.Permanent(err)
+	}
+	_, err := b.DB.ExecContext(ctx, `SELECT pg_notify($1, $2)`, FanoutChannel, fmt.Sprintf("%d:%s", ev.UserID, id))
+	return err
+}
+
+// Relay listens on FanoutChannel and publishes the announced events to hub
+// until ctx is done. Events announced while it reconnects are not relayed;
+// clients pick them up through the Last-Event-ID replay.
+func Relay(ctx context.Context, db *sql.DB, hub *Hub, log *zap.Logger) {
+	outbox.Subscribe(ctx, db, FanoutChannel, func(msg string) {
+		user, id, _ := strings.Cut(msg, ":")
+		userID, err := strconv.ParseUint(user, 10, 64)
+		if err != nil || !uuidRe.MatchString(id) {
+			log.Warn("realtime relay: malformed notification", zap.String("payload", msg))
+			return
+		}
+		if hub.Connected(uint(userID)) == 0 {
+			return
+		}
+		var payload []byte
+		err = db.QueryRowContext(ctx, `SELECT payload FROM outbox_events WHERE id=$1::uuid`, id).Scan(&payload)
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		if err == nil {
+			err = hub.PublishRaw(ctx, id, payload)
+		}
+		if err != nil {
+			log.Warn("realtime relay", zap.String("id", id), zap.Error(err))
+		}
+	}, log)
+}
@@ -0,0 +1,50 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/pgtest"
+)
+
+// Two hubs stand in for two instances: the event is claimed on one and must
+// reach the user connected to the other.
+func TestBroadcastReachesOtherInstances(t *testing.T) {
+	db := pgtest.Open(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	local, remote := NewHub(), NewHub()
+	for _, h := range []*Hub{local, remote} {
+		go Relay(ctx, db, h, zap.NewNop())
+	}
+	c := remote.subscribe(7)
+	defer remote.unsubscribe(c)
+	// LISTEN must be in place before the NOTIFY
+	time.Sleep(200 * time.Millisecond)
+
+	repo := outbox.NewRepo(db)
+	if err := (OutboxEmitter{Repo: repo}).Emit(ctx, 7, TypeTokensEarned, map[string]int{"amount": 5}); err != nil {
+		t.Fatal(err)
+	}
+	evs, err := repo.ClaimBatch(ctx, "local", 10, time.Minute)
+	if err != nil || len(evs) != 1 {
+		t.Fatalf("claim: %v %v", evs, err)
+	}
+	if err := (Broadcast{DB: db}).PublishRaw(ctx, evs[0].ID, evs[0].Payload); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-c.ch:
+		if ev.ID != evs[0].ID || ev.UserID != 7 || ev.Type != TypeTokensEarned {
+			t.Fatalf("relayed %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event did not reach the other instance")
+	}
+}
@@ -0,0 +1,122 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"luvy-go-backend/internal/outbox"
+)
+
+// Event types delivered to clients.
+const (
+	TypeReceiptApproved = "receipt_approved"
+	TypeReceiptRejected = "receipt_rejected"
+	TypeTokensEarned    = "tokens_earned"
+	TypeLevelUp         = "level_up"
+	TypeBalanceChanged  = "balance_changed"
+	TypeNotification    = "notification"
+)
+
+// OutboxEventType is the outbox event_type carrying a realtime Event.
+const OutboxEventType = "USER_EVENT"
+
+// DefaultBuffer is how many undelivered events a client may lag behind
+// before it is dropped and has to resume with Last-Event-ID.
+const DefaultBuffer = 64
+
+type Event struct {
+	ID     string          `json:"id"`
+	UserID uint            `json:"user_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+	At     time.Time       `json:"at"`
+}
+
+type client struct {
+	userID uint
+	ch     chan Event
+	once   sync.Once
+	// dropped is closed when the hub gives up on a slow client.
+	dropped chan struct{}
+}
+
+func (c *client) drop() {
+	c.once.Do(func() { close(c.dropped) })
+}
+
+// Hub fans events out to every open connection of the target user.
+type Hub struct {
+	BufferSize int
+
+	mu      sync.RWMutex
+	clients map[uint]map[*client]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		BufferSize: DefaultBuffer,
+		clients:    make(map[uint]map[*client]struct{}),
+	}
+}
+
+// Publish never blocks: a client whose buffer is full is disconnected
+// instead of holding up delivery to everyone else.
+func (h *Hub) Publish(ev Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients[ev.UserID] {
+		select {
+		case c.ch <- ev:
+		default:
+			c.drop()
+		}
+	}
+}
+
+// PublishRaw decodes an outbox payload and publishes it under the outbox
+// event id, so clients can resume from it after a restart. It only reaches
+// this process's clients; see Broadcast for several instances.
+func (h *Hub) PublishRaw(_ context.Context, id string, payload json.RawMessage) error {
+	var ev Event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return outbox.Permanent(err)
+	}
+	ev.ID = id
+	h.Publish(ev)
+	return nil
+}
+
+// Connected reports how many connections a user currently has open.
+func (h *Hub) Connected(userID uint) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID])
+}
+
+func (h *Hub) subscribe(userID uint) *client {
+	size := h.BufferSize
+	if size <= 0 {
+		size = DefaultBuffer
+	}
+	c := &client{userID: userID, ch: make(chan Event, size), dropped: make(chan struct{})}
+
+	h.mu.Lock()
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*client]struct{})
+	}
+	h.clients[userID][c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+func (h *Hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	delete(h.clients[c.userID], c)
+	if len(h.clients[c.userID]) == 0 {
+		delete(h.clients, c.userID)
+	}
+	h.mu.Unlock()
+}
@@ -0,0 +1,222 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"luvy-go-backend/internal/platform/httpx"
+)
+
+const (
+	DefaultHeartbeat = 25 * time.Second
+	writeTimeout     = 10 * time.Second
+)
+
+var errSlowClient = errors.New("client too slow, dropped")
+
+type ctxKey struct{}
+
+// Server exposes a Hub over WebSocket and, as a fallback for networks that
+// break upgrades, Server-Sent Events.
+type Server struct {
+	Hub       *Hub
+	Tokens    *Tokens
+	Replay    Replayer
+	Heartbeat time.Duration
+
+	// AllowedOrigins are the web origins whose pages may open a stream, the
+	// same list CORS allows. Requests without an Origin header (mobile and
+	// server clients) are not browsers and are let through on the token.
+	AllowedOrigins []string
+}
+
+var errOrigin = errors.New("origin not allowed")
+
+// checkOrigin stops other sites' pages from opening a stream with a token
+// they got hold of; WebSocket upgrades are not covered by CORS.
+func (s *Server) checkOrigin(r *http.Request) error {
+	origin := strings.TrimSuffix(r.Header.Get("Origin"), "/")
+	if origin == "" {
+		return nil
+	}
+	for _, o := range s.AllowedOrigins {
+		if strings.EqualFold(origin, strings.TrimSuffix(o, "/")) {
+			return nil
+		}
+	}
+	return errOrigin
+}
+
+func (s *Server) authenticate(r *http.Request) (uint, error) {
+	token := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); token == "" && strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimPrefix(h, "Bearer ")
+	}
+	return s.Tokens.Verify(token)
+}
+
+func (s *Server) heartbeat() time.Duration {
+	if s.Heartbeat > 0 {
+		return s.Heartbeat
+	}
+	return DefaultHeartbeat
+}
+
+// stream subscribes first and replays second, so nothing published in
+// between is lost; live events already sent by the replay are skipped.
+func (s *Server) stream(ctx context.Context, userID uint, lastID string, send func(Event) error, ping func() error) error {
+	c := s.Hub.subscribe(userID)
+	defer s.Hub.unsubscribe(c)
+
+	seen := map[string]bool{}
+	if s.Replay != nil && lastID != "" {
+		missed, err := s.Replay.Since(ctx, userID, lastID)
+		if err != nil {
+			return err
+		}
+		for _, ev := range missed {
+			if err := send(ev); err != nil {
+				return err
+			}
+			seen[ev.ID] = true
+		}
+	}
+
+	t := time.NewTicker(s.heartbeat())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.dropped:
+			return errSlowClient
+		case ev := <-c.ch:
+			if seen[ev.ID] {
+				delete(seen, ev.ID)
+				continue
+			}
+			if err := send(ev); err != nil {
+				return err
+			}
+		case <-t.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ServeSSE streams events as text/event-stream. EventSource reconnects on its
+// own and sends Last-Event-ID, which drives the replay.
+func (s *Server) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkOrigin(r); err != nil {
+		httpx.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+	userID, err := s.authenticate(r)
+	if err != nil {
+		httpx.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpx.Error(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	send := func(ev Event) error {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, b); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	ping := func() error {
+		if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := s.stream(r.Context(), userID, lastID, send, ping); errors.Is(err, errSlowClient) {
+		fmt.Fprint(w, "event: overflow\ndata: {}\n\n")
+		flusher.Flush()
+	}
+}
+
+// WebSocket returns the upgrade handler. The token is checked before the
+// upgrade so unauthenticated clients get a plain 401; a foreign Origin fails
+// the handshake with 403.
+func (s *Server) WebSocket() http.Handler {
+	ws := websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error { return s.checkOrigin(r) },
+		Handler:   s.serveWS,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := s.authenticate(r)
+		if err != nil {
+			httpx.Error(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		ws.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, userID)))
+	})
+}
+
+func (s *Server) serveWS(conn *websocket.Conn) {
+	defer conn.Close()
+
+	r := conn.Request()
+	userID, _ := r.Context().Value(ctxKey{}).(uint)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Clients don't send anything meaningful; reading just notices the close.
+	go func() {
+		defer cancel()
+		var msg string
+		for {
+			if err := websocket.Message.Receive(conn, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(v any) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return websocket.JSON.Send(conn, v)
+	}
+	send := func(ev Event) error { return write(ev) }
+	ping := func() error { return write(map[string]string{"type": "ping"}) }
+
+	if err := s.stream(ctx, userID, r.URL.Query().Get("last_event_id"), send, ping); errors.Is(err, errSlowClient) {
+		_ = write(map[string]string{"type": "overflow"})
+	}
+}
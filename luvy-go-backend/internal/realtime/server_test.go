@@ -0,0 +1,67 @@
+package realtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketChecksOrigin(t *testing.T) {
+	s := &Server{Hub: NewHub(), Tokens: NewTokens("test"), AllowedOrigins: []string{"http://localhost:3000"}}
+	srv := httptest.NewServer(s.WebSocket())
+	defer srv.Close()
+
+	token, _ := s.Tokens.Issue(7)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?token=" + token
+
+	conn, err := websocket.Dial(url, "", "http://localhost:3000")
+	if err != nil {
+		t.Fatalf("allowed origin: %v", err)
+	}
+	conn.Close()
+
+	if conn, err := websocket.Dial(url, "", "https://evil.example"); err == nil {
+		conn.Close()
+		t.Fatal("foreign origin was upgraded")
+	}
+}
+
+func TestSSEChecksOrigin(t *testing.T) {
+	s := &Server{Hub: NewHub(), Tokens: NewTokens("test"), AllowedOrigins: []string{"http://localhost:3000"}}
+	token, _ := s.Tokens.Issue(7)
+
+	r := httptest.NewRequest(http.MethodGet, "/?token="+token, nil)
+	r.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	s.ServeSSE(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("foreign origin: %d", w.Code)
+	}
+}
+
+func TestHubDeliversToUsersConnections(t *testing.T) {
+	h := NewHub()
+	c := h.subscribe(7)
+	defer h.unsubscribe(c)
+	other := h.subscribe(8)
+	defer h.unsubscribe(other)
+
+	h.Publish(Event{ID: "1", UserID: 7, Type: TypeTokensEarned})
+	select {
+	case ev := <-c.ch:
+		if ev.ID != "1" {
+			t.Fatalf("got %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+	select {
+	case ev := <-other.ch:
+		t.Fatalf("user 8 got user 7's event %+v", ev)
+	default:
+	}
+}
@@ -0,0 +1,304 @@
+package referrals
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/src/models"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusExpired   = "expired"
+)
+
+// Defaults carried over from the Node gamification service.
+const (
+	DefaultReferrerReward = 50
+	DefaultReferredReward = 25
+	DefaultValidFor       = 30 * 24 * time.Hour
+
+	// maxSameDomain caps how many referrals one referrer may collect from a
+	// single private email domain before further ones are refused.
+	maxSameDomain = 3
+)
+
+var (
+	ErrCodeNotFound    = errors.New("referral code not found")
+	ErrAlreadyReferred = errors.New("user was already referred")
+	ErrSelfReferral    = errors.New("cannot redeem your own referral code")
+	ErrSuspicious      = errors.New("referral rejected")
+)
+
+// publicDomains are shared mail providers; many users legitimately share them.
+var publicDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true,
+	"live.com": true, "yahoo.com": true, "icloud.com": true, "gmx.de": true,
+	"gmx.net": true, "web.de": true, "t-online.de": true,
+}
+
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+type Service struct {
+	DB *gorm.DB
+
+	ReferrerReward float64
+	ReferredReward float64
+	ValidFor       time.Duration
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		DB:             db,
+		ReferrerReward: DefaultReferrerReward,
+		ReferredReward: DefaultReferredReward,
+		ValidFor:       DefaultValidFor,
+	}
+}
+
+// CodeFor returns the user's referral code, generating one on first use.
+func (s *Service) CodeFor(userID uint) (models.ReferralCode, error) {
+	var rc models.ReferralCode
+	err := s.DB.Where("user_id = ?", userID).First(&rc).Error
+	if err == nil {
+		return rc, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return rc, err
+	}
+
+	for i := 0; i < 5; i++ {
+		rc = models.ReferralCode{UserID: userID, Code: "LUVY" + randomCode(6)}
+		if err = s.DB.Create(&rc).Error; err == nil {
+			return rc, nil
+		}
+		// Lost a race with another request for the same user.
+		if s.DB.Where("user_id = ?", userID).First(&rc).Error == nil {
+			return rc, nil
+		}
+	}
+	return rc, err
+}
+
+// Redeem links a freshly registered user to the owner of code. Rewards are
+// held back until the referred user's first approved receipt.
+func (s *Service) Redeem(tx *gorm.DB, referred models.User, code string) (models.Referral, error) {
+	var ref models.Referral
+
+	var rc models.ReferralCode
+	if err := tx.Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).First(&rc).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ref, ErrCodeNotFound
+		}
+		return ref, err
+	}
+	if rc.UserID == referred.ID {
+		return ref, ErrSelfReferral
+	}
+
+	var existing int64
+	if err := tx.Model(&models.Referral{}).Where("referred_user_id = ?", referred.ID).Count(&existing).Error; err != nil {
+		return ref, err
+	}
+	if existing > 0 {
+		return ref, ErrAlreadyReferred
+	}
+
+	var referrer models.User
+	if err := tx.First(&referrer, rc.UserID).Error; err != nil {
+		return ref, err
+	}
+	if err := s.checkAbuse(tx, referrer, referred); err != nil {
+		return ref, err
+	}
+
+	ref = models.Referral{
+		ReferrerID:     referrer.ID,
+		ReferredUserID: referred.ID,
+		ReferralCode:   rc.Code,
+		Status:         StatusPending,
+		ReferrerReward: s.ReferrerReward,
+		ReferredReward: s.ReferredReward,
+		ExpiresAt:      time.Now().Add(s.ValidFor),
+	}
+	return ref, tx.Create(&ref).Error
+}
+
+func (s *Service) checkAbuse(tx *gorm.DB, referrer, referred models.User) error {
+	if referred.SignupDeviceID != "" && referred.SignupDeviceID == referrer.SignupDeviceID {
+		return ErrSuspicious
+	}
+	if normalizeEmail(referred.Email) == normalizeEmail(referrer.Email) {
+		return ErrSuspicious
+	}
+
+	// A referred B, now B's invitee is A again (or someone on A's device).
+	var loop int64
+	if err := tx.Model(&models.Referral{}).
+		Where("referrer_id = ? AND referred_user_id = ?", referred.ID, referrer.ID).
+		Count(&loop).Error; err != nil {
+		return err
+	}
+	if loop > 0 {
+		return ErrSuspicious
+	}
+
+	domain := emailDomain(referred.Email)
+	if domain == "" || publicDomains[domain] {
+		return nil
+	}
+	var sameDomain int64
+	if err := tx.Model(&models.Referral{}).
+		Joins("JOIN users ON users.id = referrals.referred_user_id").
+		Where("referrals.referrer_id = ? AND LOWER(users.email) LIKE ?", referrer.ID, "%@"+domain).
+		Count(&sameDomain).Error; err != nil {
+		return err
+	}
+	if sameDomain >= maxSameDomain {
+		return ErrSuspicious
+	}
+	return nil
+}
+
+// OnReceiptApproved completes a pending referral on the referred user's first
+// approved receipt and pays both sides.
+func (s *Service) OnReceiptApproved(tx *gorm.DB, receipt *models.Receipt) (*models.Referral, error) {
+	var ref models.Referral
+	err := tx.Where("referred_user_id = ? AND status = ?", receipt.UserID, StatusPending).First(&ref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.After(ref.ExpiresAt) {
+		return nil, tx.Model(&ref).Where("status = ?", StatusPending).Update("status", StatusExpired).Error
+	}
+
+	res := tx.Model(&models.Referral{}).
+		Where("id = ? AND status = ?", ref.ID, StatusPending).
+		Updates(map[string]interface{}{"status": StatusCompleted, "completed_at": &now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	if ref.ReferrerReward > 0 {
+		if err := ledger.Credit(tx, &models.Transaction{
+			UserID:        ref.ReferrerID,
+			Type:          ledger.TypeBonus,
+			Amount:        ref.ReferrerReward,
+			Description:   "Referral bonus - Friend joined",
+			ReferenceType: "referral",
+			ReferenceID:   ref.ID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if ref.ReferredReward > 0 {
+		if err := ledger.Credit(tx, &models.Transaction{
+			UserID:        ref.ReferredUserID,
+			Type:          ledger.TypeBonus,
+			Amount:        ref.ReferredReward,
+			Description:   "Welcome bonus - Referral",
+			ReferenceType: "referral",
+			ReferenceID:   ref.ID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	ref.Status = StatusCompleted
+	ref.CompletedAt = &now
+	return &ref, nil
+}
+
+// ExpireStale marks pending referrals past their deadline as expired.
+func (s *Service) ExpireStale() (int64, error) {
+	res := s.DB.Model(&models.Referral{}).
+		Where("status = ? AND expires_at < ?", StatusPending, time.Now()).
+		Update("status", StatusExpired)
+	return res.RowsAffected, res.Error
+}
+
+type Summary struct {
+	Code      string            `json:"code"`
+	Pending   int               `json:"pending"`
+	Completed int               `json:"completed"`
+	Earned    float64           `json:"earned"`
+	Referrals []models.Referral `json:"referrals"`
+}
+
+func (s *Service) Summary(userID uint) (Summary, error) {
+	var out Summary
+
+	if _, err := s.ExpireStale(); err != nil {
+		return out, err
+	}
+
+	rc, err := s.CodeFor(userID)
+	if err != nil {
+		return out, err
+	}
+	out.Code = rc.Code
+
+	if err := s.DB.Where("referrer_id = ?", userID).Order("created_at DESC").Find(&out.Referrals).Error; err != nil {
+		return out, err
+	}
+	for _, r := range out.Referrals {
+		switch r.Status {
+		case StatusPending:
+			out.Pending++
+		case StatusCompleted:
+			out.Completed++
+			out.Earned += r.ReferrerReward
+		}
+	}
+	return out, nil
+}
+
+func randomCode(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b)
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// normalizeEmail folds plus-addressing and gmail dots so aliases of the
+// same inbox compare equal.
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if i := strings.Index(local, "+"); i >= 0 {
+		local = local[:i]
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
@@ -0,0 +1,204 @@
+package referrals
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"luvy-go-backend/src/models"
+)
+
+// newTestService enforces foreign keys, so reward credits are checked
+// against the receipts table as they are on Postgres.
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "referrals.db") + "?_foreign_keys=on"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Receipt{}, &models.Transaction{}, &models.ReferralCode{}, &models.Referral{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db)
+}
+
+func createUser(t *testing.T, s *Service, address, device string) models.User {
+	t.Helper()
+	u := models.User{Name: "Test", Email: address, SignupDeviceID: device}
+	if err := s.DB.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func codeOf(t *testing.T, s *Service, u models.User) string {
+	t.Helper()
+	rc, err := s.CodeFor(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rc.Code
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	cases := map[string]string{
+		"Ana@Example.com":         "ana@example.com",
+		"ana+luvy@example.com":    "ana@example.com",
+		"a.n.a@gmail.com":         "ana@gmail.com",
+		" A.na+x@GoogleMail.com ": "ana@gmail.com",
+		"a.na@example.com":        "a.na@example.com", // dots only fold for gmail
+		"no-at-sign":              "no-at-sign",
+	}
+	for in, want := range cases {
+		if got := normalizeEmail(in); got != want {
+			t.Errorf("normalizeEmail(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRedeemRejections(t *testing.T) {
+	s := newTestService(t)
+	referrer := createUser(t, s, "a.na@gmail.com", "device-1")
+	code := codeOf(t, s, referrer)
+
+	cases := []struct {
+		name     string
+		referred models.User
+		code     string
+		want     error
+	}{
+		{"unknown code", createUser(t, s, "x@example.com", ""), "LUVYNOPE", ErrCodeNotFound},
+		{"own code", referrer, code, ErrSelfReferral},
+		{"same device", createUser(t, s, "bo@example.com", "device-1"), code, ErrSuspicious},
+		{"alias of the referrer", createUser(t, s, "ana+2@gmail.com", "device-2"), code, ErrSuspicious},
+	}
+	for _, c := range cases {
+		if _, err := s.Redeem(s.DB, c.referred, c.code); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	// lower case and stray spaces still find the code
+	ok := createUser(t, s, "cem@example.com", "device-3")
+	if _, err := s.Redeem(s.DB, ok, "  "+strings.ToLower(code)+" "); err != nil {
+		t.Fatalf("valid redeem: %v", err)
+	}
+	if _, err := s.Redeem(s.DB, ok, code); !errors.Is(err, ErrAlreadyReferred) {
+		t.Fatalf("second redeem: got %v, want ErrAlreadyReferred", err)
+	}
+}
+
+func TestRedeemRejectsReferralLoop(t *testing.T) {
+	s := newTestService(t)
+	a := createUser(t, s, "a@example.com", "device-a")
+	b := createUser(t, s, "b@example.com", "device-b")
+
+	if _, err := s.Redeem(s.DB, b, codeOf(t, s, a)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Redeem(s.DB, a, codeOf(t, s, b)); !errors.Is(err, ErrSuspicious) {
+		t.Fatalf("b referring a back: got %v, want ErrSuspicious", err)
+	}
+}
+
+func TestRedeemCapsReferralsFromOnePrivateDomain(t *testing.T) {
+	s := newTestService(t)
+	referrer := createUser(t, s, "boss@example.com", "device-0")
+	code := codeOf(t, s, referrer)
+
+	for i := 0; i < maxSameDomain; i++ {
+		u := createUser(t, s, fmt.Sprintf("staff%d@Acme.io", i), fmt.Sprintf("acme-%d", i))
+		if _, err := s.Redeem(s.DB, u, code); err != nil {
+			t.Fatalf("referral %d from acme.io: %v", i+1, err)
+		}
+	}
+	over := createUser(t, s, "staff9@acme.io", "acme-9")
+	if _, err := s.Redeem(s.DB, over, code); !errors.Is(err, ErrSuspicious) {
+		t.Fatalf("referral past the cap: got %v, want ErrSuspicious", err)
+	}
+
+	// shared providers are not capped
+	for i := 0; i <= maxSameDomain; i++ {
+		u := createUser(t, s, fmt.Sprintf("friend%d@gmail.com", i), fmt.Sprintf("gmail-%d", i))
+		if _, err := s.Redeem(s.DB, u, code); err != nil {
+			t.Fatalf("gmail referral %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestFirstReceiptPaysBothSidesOnce(t *testing.T) {
+	s := newTestService(t)
+	referrer := createUser(t, s, "a@example.com", "device-a")
+	referred := createUser(t, s, "b@example.com", "device-b")
+	ref, err := s.Redeem(s.DB, referred, codeOf(t, s, referrer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			r := models.Receipt{UserID: referred.ID, Merchant: "Migros", Amount: 10, Status: "completed"}
+			if err := tx.Create(&r).Error; err != nil {
+				return err
+			}
+			_, err := s.OnReceiptApproved(tx, &r)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("receipt %d: %v", i+1, err)
+		}
+	}
+
+	if err := s.DB.First(&ref, ref.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ref.Status != StatusCompleted || ref.CompletedAt == nil {
+		t.Fatalf("referral %+v, want completed", ref)
+	}
+	for _, c := range []struct {
+		user models.User
+		want float64
+	}{{referrer, DefaultReferrerReward}, {referred, DefaultReferredReward}} {
+		var u models.User
+		if err := s.DB.First(&u, c.user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		var n int64
+		s.DB.Model(&models.Transaction{}).Where("user_id = ? AND reference_type = ?", u.ID, "referral").Count(&n)
+		if u.LuvyBalance != c.want || n != 1 {
+			t.Errorf("user %d: balance %v from %d entries, want %v from one", u.ID, u.LuvyBalance, n, c.want)
+		}
+	}
+}
+
+func TestExpiredReferralPaysNothing(t *testing.T) {
+	s := newTestService(t)
+	referrer := createUser(t, s, "a@example.com", "device-a")
+	referred := createUser(t, s, "b@example.com", "device-b")
+	s.ValidFor = -time.Minute
+	ref, err := s.Redeem(s.DB, referred, codeOf(t, s, referrer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.OnReceiptApproved(s.DB, &models.Receipt{UserID: referred.ID})
+	if err != nil || got != nil {
+		t.Fatalf("got %v %v, want no completion", got, err)
+	}
+	if err := s.DB.First(&ref, ref.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	s.DB.Model(&models.Transaction{}).Count(&n)
+	if ref.Status != StatusExpired || n != 0 {
+		t.Fatalf("status %s with %d ledger entries, want expired with none", ref.Status, n)
+	}
+}
@@ -0,0 +1,224 @@
+package streaks
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // user time zones must resolve on hosts without zoneinfo
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"luvy-go-backend/src/models"
+)
+
+const DefaultTimeZone = "Europe/Berlin"
+
+const dayLayout = "2006-01-02"
+
+type tier struct {
+	Min        int
+	Multiplier float64
+}
+
+// Bonus tiers, lowest first. The better of the day and week tier applies.
+var (
+	dayTiers = []tier{{3, 1.1}, {7, 1.25}, {14, 1.5}, {30, 2.0}}
+	weekTier = []tier{{4, 1.1}, {8, 1.25}, {12, 1.5}}
+)
+
+type Service struct {
+	DB *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+// State is the streak as seen by the user at a given moment.
+type State struct {
+	CurrentDays  int     `json:"current_days"`
+	LongestDays  int     `json:"longest_days"`
+	CurrentWeeks int     `json:"current_weeks"`
+	LongestWeeks int     `json:"longest_weeks"`
+	Multiplier   float64 `json:"multiplier"`
+	FreezeUsed   bool    `json:"freeze_used"`
+	FreezesLeft  int     `json:"freezes_left"`
+}
+
+// Location resolves a user's time zone, falling back to DefaultTimeZone.
+func Location(name string) *time.Location {
+	if name == "" {
+		name = DefaultTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc, _ = time.LoadLocation(DefaultTimeZone)
+	}
+	return loc
+}
+
+// ValidTimeZone reports whether name is a known IANA zone.
+func ValidTimeZone(name string) bool {
+	_, err := time.LoadLocation(name)
+	return err == nil && name != ""
+}
+
+// OnReceiptApproved records activity at `at` and returns the updated state,
+// including the multiplier the receipt's reward should be scaled by.
+func (s *Service) OnReceiptApproved(tx *gorm.DB, userID uint, at time.Time) (State, error) {
+	var user models.User
+	if err := tx.Select("id, time_zone").Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return State{}, err
+	}
+	local := at.In(Location(user.TimeZone))
+
+	st, err := streakFor(tx, userID)
+	if err != nil {
+		return State{}, err
+	}
+	froze := advance(&st, local)
+	if err := tx.Save(&st).Error; err != nil {
+		return State{}, err
+	}
+
+	out := stateOf(st, local.Format("2006-01"))
+	out.FreezeUsed = froze
+	return out, nil
+}
+
+// streakFor returns the user's streak row locked for update, creating it on
+// first use, so concurrent approvals take turns instead of overwriting each
+// other's counts.
+func streakFor(tx *gorm.DB, userID uint) (models.UserStreak, error) {
+	// a racing insert waits for the other one and then does nothing
+	fresh := models.UserStreak{UserID: userID}
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).Create(&fresh).Error
+	if err != nil {
+		return fresh, err
+	}
+
+	var st models.UserStreak
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&st).Error
+	return st, err
+}
+
+// advance counts activity at local, the user's wall clock, into st and
+// reports whether the monthly freeze bridged a missed day.
+func advance(st *models.UserStreak, local time.Time) bool {
+	today := local.Format(dayLayout)
+	thisWeek := weekKey(local)
+	month := local.Format("2006-01")
+	froze := false
+
+	switch gap := daysBetween(st.LastDay, today); {
+	case st.LastDay == "" || gap < 0:
+		st.CurrentDays = 1
+	case gap == 0:
+		if st.CurrentDays == 0 {
+			st.CurrentDays = 1
+		}
+	case gap == 1:
+		st.CurrentDays++
+	case gap == 2 && st.FreezeMonth != month:
+		// One missed day per month is forgiven.
+		st.CurrentDays++
+		st.FreezeMonth = month
+		froze = true
+	default:
+		st.CurrentDays = 1
+	}
+	st.LastDay = today
+
+	if st.LastWeek != thisWeek {
+		if st.LastWeek == weekKey(local.AddDate(0, 0, -7)) {
+			st.CurrentWeeks++
+		} else {
+			st.CurrentWeeks = 1
+		}
+		st.LastWeek = thisWeek
+	}
+
+	if st.CurrentDays > st.LongestDays {
+		st.LongestDays = st.CurrentDays
+	}
+	if st.CurrentWeeks > st.LongestWeeks {
+		st.LongestWeeks = st.CurrentWeeks
+	}
+	return froze
+}
+
+// Current returns the streak as of now; a streak that can no longer be
+// continued is reported as zero without touching the stored row.
+func (s *Service) Current(userID uint) (State, error) {
+	var user models.User
+	if err := s.DB.Select("id, time_zone").Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return State{}, err
+	}
+
+	var st models.UserStreak
+	if err := s.DB.Where("user_id = ?", userID).Limit(1).Find(&st).Error; err != nil {
+		return State{}, err
+	}
+	return current(st, time.Now().In(Location(user.TimeZone))), nil
+}
+
+// current is st as seen at local, the user's wall clock.
+func current(st models.UserStreak, local time.Time) State {
+	month := local.Format("2006-01")
+	gap := daysBetween(st.LastDay, local.Format(dayLayout))
+	if st.LastDay == "" || gap > 2 || (gap == 2 && st.FreezeMonth == month) {
+		st.CurrentDays = 0
+	}
+	if st.LastWeek != weekKey(local) && st.LastWeek != weekKey(local.AddDate(0, 0, -7)) {
+		st.CurrentWeeks = 0
+	}
+	return stateOf(st, month)
+}
+
+func stateOf(st models.UserStreak, month string) State {
+	out := State{
+		CurrentDays:  st.CurrentDays,
+		LongestDays:  st.LongestDays,
+		CurrentWeeks: st.CurrentWeeks,
+		LongestWeeks: st.LongestWeeks,
+		Multiplier:   Multiplier(st.CurrentDays, st.CurrentWeeks),
+	}
+	if st.FreezeMonth != month {
+		out.FreezesLeft = 1
+	}
+	return out
+}
+
+// Multiplier picks the best bonus tier reached by either streak.
+func Multiplier(days, weeks int) float64 {
+	m := 1.0
+	for _, t := range dayTiers {
+		if days >= t.Min && t.Multiplier > m {
+			m = t.Multiplier
+		}
+	}
+	for _, t := range weekTier {
+		if weeks >= t.Min && t.Multiplier > m {
+			m = t.Multiplier
+		}
+	}
+	return m
+}
+
+func weekKey(t time.Time) string {
+	y, w := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", y, w)
+}
+
+// daysBetween counts calendar days from a to b (both YYYY-MM-DD).
+func daysBetween(a, b string) int {
+	da, err := time.Parse(dayLayout, a)
+	if err != nil {
+		return 0
+	}
+	db, err := time.Parse(dayLayout, b)
+	if err != nil {
+		return 0
+	}
+	return int(db.Sub(da).Hours() / 24)
+}
@@ -0,0 +1,232 @@
+package streaks
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"luvy-go-backend/internal/pgtest"
+	"luvy-go-backend/src/models"
+)
+
+var berlin = Location("Europe/Berlin")
+
+func at(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestAdvance(t *testing.T) {
+	type step struct {
+		at          string
+		days, weeks int
+		froze       bool
+	}
+	cases := []struct {
+		name  string
+		steps []step
+	}{
+		{"consecutive days over the spring DST change", []step{
+			{"2026-03-28 23:30", 1, 1, false},
+			{"2026-03-29 03:30", 2, 1, false}, // 23 hours long
+			{"2026-03-30 00:10", 3, 2, false}, // Monday, next ISO week
+		}},
+		{"consecutive days over the autumn DST change", []step{
+			{"2026-10-24 23:59", 1, 1, false},
+			{"2026-10-25 23:59", 2, 1, false}, // 25 hours long
+			{"2026-10-26 00:01", 3, 2, false},
+		}},
+		{"a second receipt on the same day", []step{
+			{"2026-05-05 08:00", 1, 1, false},
+			{"2026-05-05 21:00", 1, 1, false},
+		}},
+		{"one missed day a month is frozen", []step{
+			{"2026-05-04 12:00", 1, 1, false},
+			{"2026-05-06 12:00", 2, 1, true},
+			{"2026-05-08 12:00", 1, 1, false}, // May's freeze is used up
+		}},
+		{"the freeze comes back with the new month", []step{
+			{"2026-05-29 12:00", 1, 1, false},
+			{"2026-05-31 12:00", 2, 1, true},
+			{"2026-06-02 12:00", 3, 2, true},
+		}},
+		{"two missed days end the streak", []step{
+			{"2026-05-04 12:00", 1, 1, false},
+			{"2026-05-05 12:00", 2, 1, false},
+			{"2026-05-08 12:00", 1, 1, false},
+		}},
+		{"weeks carry over the year end", []step{
+			{"2026-12-31 12:00", 1, 1, false}, // 2026-W53
+			{"2027-01-04 12:00", 1, 2, false}, // 2027-W01
+		}},
+		{"a skipped week ends the weekly streak", []step{
+			{"2026-05-04 12:00", 1, 1, false},
+			{"2026-05-11 12:00", 1, 2, false},
+			{"2026-05-25 12:00", 1, 1, false},
+		}},
+	}
+	for _, c := range cases {
+		var st models.UserStreak
+		for i, s := range c.steps {
+			froze := advance(&st, at(t, s.at))
+			if st.CurrentDays != s.days || st.CurrentWeeks != s.weeks || froze != s.froze {
+				t.Errorf("%s, step %d (%s): days=%d weeks=%d froze=%v, want %d %d %v",
+					c.name, i+1, s.at, st.CurrentDays, st.CurrentWeeks, froze, s.days, s.weeks, s.froze)
+			}
+		}
+	}
+}
+
+func TestAdvanceKeepsLongest(t *testing.T) {
+	var st models.UserStreak
+	for _, s := range []string{"2026-05-04 12:00", "2026-05-05 12:00", "2026-05-06 12:00", "2026-05-20 12:00"} {
+		advance(&st, at(t, s))
+	}
+	if st.CurrentDays != 1 || st.LongestDays != 3 || st.LongestWeeks != 1 {
+		t.Fatalf("current %d longest %d/%d weeks, want 1 and 3/1", st.CurrentDays, st.LongestDays, st.LongestWeeks)
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	now := at(t, "2026-05-10 12:00") // Sunday of 2026-W19
+	cases := []struct {
+		name        string
+		st          models.UserStreak
+		days, weeks int
+		freezes     int
+	}{
+		{"active yesterday", models.UserStreak{CurrentDays: 5, LastDay: "2026-05-09", CurrentWeeks: 3, LastWeek: "2026-W19"}, 5, 3, 1},
+		{"one day missed, freeze left", models.UserStreak{CurrentDays: 5, LastDay: "2026-05-08", CurrentWeeks: 3, LastWeek: "2026-W19"}, 5, 3, 1},
+		{"one day missed, freeze used", models.UserStreak{CurrentDays: 5, LastDay: "2026-05-08", FreezeMonth: "2026-05", CurrentWeeks: 3, LastWeek: "2026-W19"}, 0, 3, 0},
+		{"last month's freeze does not count", models.UserStreak{CurrentDays: 5, LastDay: "2026-05-08", FreezeMonth: "2026-04", CurrentWeeks: 3, LastWeek: "2026-W19"}, 5, 3, 1},
+		{"two days missed", models.UserStreak{CurrentDays: 5, LastDay: "2026-05-07", CurrentWeeks: 3, LastWeek: "2026-W19"}, 0, 3, 1},
+		{"last active the week before", models.UserStreak{CurrentDays: 1, LastDay: "2026-05-03", CurrentWeeks: 3, LastWeek: "2026-W18"}, 0, 3, 1},
+		{"last active two weeks ago", models.UserStreak{CurrentDays: 1, LastDay: "2026-04-26", CurrentWeeks: 3, LastWeek: "2026-W17"}, 0, 0, 1},
+		{"never active", models.UserStreak{}, 0, 0, 1},
+	}
+	for _, c := range cases {
+		got := current(c.st, now)
+		if got.CurrentDays != c.days || got.CurrentWeeks != c.weeks || got.FreezesLeft != c.freezes {
+			t.Errorf("%s: days=%d weeks=%d freezes=%d, want %d %d %d", c.name, got.CurrentDays, got.CurrentWeeks, got.FreezesLeft, c.days, c.weeks, c.freezes)
+		}
+	}
+}
+
+func TestMultiplier(t *testing.T) {
+	cases := []struct {
+		days, weeks int
+		want        float64
+	}{
+		{0, 0, 1.0},
+		{2, 3, 1.0},
+		{3, 0, 1.1},
+		{7, 0, 1.25},
+		{13, 0, 1.25},
+		{14, 0, 1.5},
+		{30, 0, 2.0},
+		{0, 4, 1.1},
+		{0, 8, 1.25},
+		{1, 12, 1.5},
+		{7, 12, 1.5}, // the better of the two tiers
+		{30, 12, 2.0},
+	}
+	for _, c := range cases {
+		if got := Multiplier(c.days, c.weeks); got != c.want {
+			t.Errorf("Multiplier(%d, %d) = %v, want %v", c.days, c.weeks, got, c.want)
+		}
+	}
+}
+
+func TestLocationFallsBackToDefault(t *testing.T) {
+	for _, name := range []string{"", "Mars/Olympus"} {
+		if got := Location(name).String(); got != DefaultTimeZone {
+			t.Errorf("Location(%q) = %s, want %s", name, got, DefaultTimeZone)
+		}
+	}
+	if ValidTimeZone("") || ValidTimeZone("Mars/Olympus") || !ValidTimeZone("Europe/Istanbul") {
+		t.Error("ValidTimeZone")
+	}
+}
+
+func TestOnReceiptApprovedUsesTheUsersDay(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "streaks.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserStreak{}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(db)
+	user := models.User{Name: "Test", Email: "ana@example.com", TimeZone: "Europe/Istanbul"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 22:30 UTC is already the next day in Istanbul (UTC+3)
+	for i, ts := range []time.Time{
+		time.Date(2026, 5, 4, 20, 0, 0, 0, time.UTC),
+		time.Date(2026, 5, 4, 22, 30, 0, 0, time.UTC),
+	} {
+		st, err := s.OnReceiptApproved(db, user.ID, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.CurrentDays != i+1 {
+			t.Fatalf("receipt %d: %d days, want %d", i+1, st.CurrentDays, i+1)
+		}
+	}
+
+	var rows []models.UserStreak
+	db.Where("user_id = ?", user.ID).Find(&rows)
+	if len(rows) != 1 || rows[0].LastDay != "2026-05-05" {
+		t.Fatalf("streak rows %+v", rows)
+	}
+}
+
+func TestConcurrentFirstApprovalsShareOneRow(t *testing.T) {
+	db := pgtest.Gorm(t, pgtest.Open(t, &models.User{}, &models.UserStreak{}))
+	s := NewService(db)
+	user := models.User{Name: "Test", Email: "ana@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10
+	now := time.Now()
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.Transaction(func(tx *gorm.DB) error {
+				_, err := s.OnReceiptApproved(tx, user.ID, now)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rows []models.UserStreak
+	if err := db.Where("user_id = ?", user.ID).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].CurrentDays != 1 || rows[0].CurrentWeeks != 1 {
+		t.Fatalf("rows %+v, want one streak of one day", rows)
+	}
+}
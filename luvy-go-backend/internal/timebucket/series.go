@@ -0,0 +1,63 @@
+package timebucket
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Point is one period of a series. Periods without rows are zero.
+type Point struct {
+	Start  time.Time `json:"-"`
+	Period string    `json:"period"` // YYYY-MM-DD of the period start
+	Total  float64   `json:"total"`
+	Count  int       `json:"count"`
+}
+
+// Series sums value over the rows of q (already scoped to a model and its
+// filters) per period of column, in loc, for every period of r.
+func Series(q *gorm.DB, g Granularity, column, value string, loc *time.Location, r Range) ([]Point, error) {
+	buckets, err := Range{From: r.From.In(loc), To: r.To.In(loc)}.Buckets(g)
+	if err != nil {
+		return nil, err
+	}
+
+	d := DialectOf(q)
+	key, keyArgs := KeyExpr(d, g, column, loc, r)
+	cond, condArgs := RangeCond(d, column, r)
+
+	var rows []struct {
+		Period string
+		Total  float64
+		Count  int
+	}
+	err = q.Select(key+" AS period, COALESCE(SUM("+value+"), 0) AS total, COUNT(*) AS count", keyArgs...).
+		Where(cond, condArgs...).
+		Group("period").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]int, len(rows))
+	for i, row := range rows {
+		byKey[row.Period] = i
+	}
+	out := make([]Point, len(buckets))
+	for i, b := range buckets {
+		out[i] = Point{Start: b, Period: Key(b)}
+		if j, ok := byKey[out[i].Period]; ok {
+			out[i].Total, out[i].Count = rows[j].Total, rows[j].Count
+		}
+	}
+	return out, nil
+}
+
+// Sum adds up a series.
+func Sum(points []Point) (total float64, count int) {
+	for _, p := range points {
+		total += p.Total
+		count += p.Count
+	}
+	return total, count
+}
@@ -0,0 +1,125 @@
+package timebucket
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// DialectOf reports which SQL flavour db speaks.
+func DialectOf(db *gorm.DB) Dialect {
+	if db.Dialector.Name() == "postgres" {
+		return Postgres
+	}
+	return SQLite
+}
+
+// RangeCond restricts column to r. SQLite keeps timestamps as text with
+// varying offsets, so it compares them as julian days.
+func RangeCond(d Dialect, column string, r Range) (string, []any) {
+	from, to := r.From.UTC(), r.To.UTC()
+	if d == Postgres {
+		return column + " >= ? AND " + column + " < ?", []any{from, to}
+	}
+	return "julianday(" + column + ") >= julianday(?) AND julianday(" + column + ") < julianday(?)", []any{from, to}
+}
+
+// KeyExpr is a SQL expression for the 'YYYY-MM-DD' start of the period
+// containing column, in loc; it matches Key on the Go side. r bounds the
+// rows the expression will see: SQLite has no time zone database, so the
+// expression carries loc's UTC offsets for that range, DST changes included.
+func KeyExpr(d Dialect, g Granularity, column string, loc *time.Location, r Range) (string, []any) {
+	if d == Postgres {
+		return "to_char(date_trunc(?, " + column + " AT TIME ZONE ?), 'YYYY-MM-DD')", []any{string(g), zoneName(loc)}
+	}
+
+	local, args := sqliteLocal(column, loc, r)
+	switch g {
+	case Week:
+		// SQLite weekdays start on Sunday (%w = 0)
+		expr := "date(" + local + ", '-' || ((CAST(strftime('%w', " + local + ") AS INTEGER) + 6) % 7) || ' days')"
+		return expr, append(args, args...)
+	case Month:
+		return "strftime('%Y-%m-01', " + local + ")", args
+	case Year:
+		return "strftime('%Y-01-01', " + local + ")", args
+	default:
+		return "strftime('%Y-%m-%d', " + local + ")", args
+	}
+}
+
+func zoneName(loc *time.Location) string {
+	if loc == nil || loc.String() == "Local" {
+		return "UTC"
+	}
+	return loc.String()
+}
+
+// sqliteLocal shifts column into loc: one CASE branch per UTC offset loc
+// uses within r (plus a day either side).
+func sqliteLocal(column string, loc *time.Location, r Range) (string, []any) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	segs := offsets(loc, r.From.Add(-24*time.Hour), r.To.Add(24*time.Hour))
+	if len(segs) == 1 {
+		return "datetime(" + column + ", ?)", []any{modifier(segs[0].offset)}
+	}
+
+	var sb strings.Builder
+	var args []any
+	sb.WriteString("CASE")
+	for _, s := range segs[:len(segs)-1] {
+		sb.WriteString(" WHEN julianday(" + column + ") < julianday(?) THEN datetime(" + column + ", ?)")
+		args = append(args, s.until.UTC(), modifier(s.offset))
+	}
+	sb.WriteString(" ELSE datetime(" + column + ", ?) END")
+	args = append(args, modifier(segs[len(segs)-1].offset))
+	return sb.String(), args
+}
+
+func modifier(offsetSec int) string {
+	return fmt.Sprintf("%+d minutes", offsetSec/60)
+}
+
+type segment struct {
+	offset int
+	until  time.Time // exclusive; zero for the last segment
+}
+
+// offsets splits [from, to) where loc's UTC offset changes.
+func offsets(loc *time.Location, from, to time.Time) []segment {
+	_, off := from.In(loc).Zone()
+	var out []segment
+	for t := from; t.Before(to); {
+		next := t.Add(24 * time.Hour)
+		if _, o := next.In(loc).Zone(); o != off {
+			// find the transition to the second
+			lo, hi := t, next
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if _, m := mid.In(loc).Zone(); m == off {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			hi = hi.Truncate(time.Second)
+			out = append(out, segment{offset: off, until: hi})
+			_, off = hi.In(loc).Zone()
+			t = hi
+			continue
+		}
+		t = next
+	}
+	return append(out, segment{offset: off})
+}
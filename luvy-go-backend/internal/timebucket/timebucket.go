@@ -0,0 +1,122 @@
+// Package timebucket groups timestamps into calendar periods (day, week,
+// month, year) in a user's time zone, in Go and in SQL for both databases
+// the server runs on.
+package timebucket
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Granularity string
+
+const (
+	Day   Granularity = "day"
+	Week  Granularity = "week" // ISO weeks, starting Monday
+	Month Granularity = "month"
+	Year  Granularity = "year"
+)
+
+// MaxBuckets caps how many periods one query may return.
+const MaxBuckets = 1000
+
+var (
+	ErrGranularity = errors.New("granularity must be day, week, month or year")
+	ErrRange       = errors.New("invalid time range")
+)
+
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(strings.ToLower(s)); g {
+	case Day, Week, Month, Year:
+		return g, nil
+	}
+	return "", ErrGranularity
+}
+
+// Truncate returns the start of the period containing t, in t's location.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch g {
+	case Week:
+		wd := (int(t.Weekday()) + 6) % 7 // Monday = 0
+		return time.Date(y, m, d-wd, 0, 0, 0, 0, loc)
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case Year:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+// Add moves t by n periods, keeping the wall clock across DST changes.
+func (g Granularity) Add(t time.Time, n int) time.Time {
+	switch g {
+	case Week:
+		return t.AddDate(0, 0, 7*n)
+	case Month:
+		return t.AddDate(0, n, 0)
+	case Year:
+		return t.AddDate(n, 0, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// Key formats a period start the way KeyExpr does.
+func Key(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// Range is the half-open interval [From, To). Both ends carry the time zone
+// the periods are computed in.
+type Range struct {
+	From, To time.Time
+}
+
+func (r Range) Validate() error {
+	if !r.From.Before(r.To) {
+		return fmt.Errorf("%w: from must be before to", ErrRange)
+	}
+	return nil
+}
+
+// Buckets lists the start of every period overlapping r, so periods without
+// data still show up (zero-filled by the caller).
+func (r Range) Buckets(g Granularity) ([]time.Time, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	var out []time.Time
+	for t := g.Truncate(r.From); t.Before(r.To); t = g.Add(t, 1) {
+		if len(out) == MaxBuckets {
+			return nil, fmt.Errorf("%w: more than %d %ss", ErrRange, MaxBuckets, g)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// Previous is the period of the same length right before r. Ranges that
+// start and end on period boundaries move by whole periods (May–Oct becomes
+// Nov–Apr); others by their duration.
+func (r Range) Previous(g Granularity) Range {
+	if g.Truncate(r.From).Equal(r.From) && g.Truncate(r.To).Equal(r.To) {
+		n := 0
+		for t := r.From; t.Before(r.To); t = g.Add(t, 1) {
+			n++
+		}
+		return Range{From: g.Add(r.From, -n), To: r.From}
+	}
+	d := r.To.Sub(r.From)
+	return Range{From: r.From.Add(-d), To: r.From}
+}
+
+// LastN is the range covering the n periods up to and including now's.
+func LastN(g Granularity, n int, now time.Time) Range {
+	end := g.Add(g.Truncate(now), 1)
+	return Range{From: g.Add(end, -n), To: end}
+}
@@ -0,0 +1,255 @@
+package timebucket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	return loc
+}
+
+func utc(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// edges are instants either side of Berlin's midnights around the 2026 DST
+// changes (29 March, 25 October) and the month and year turns.
+var edges = []string{
+	"2025-12-31T22:59:59Z", // Wed 23:59:59 CET
+	"2025-12-31T23:00:00Z", // Thu 1 Jan 00:00 CET, week of Mon 29 Dec
+	"2026-03-28T22:59:59Z", // Sat 23:59:59 CET
+	"2026-03-28T23:00:00Z", // Sun 00:00 CET
+	"2026-03-29T00:59:59Z", // Sun 01:59:59 CET, last second before the gap
+	"2026-03-29T01:00:00Z", // Sun 03:00 CEST
+	"2026-03-29T21:59:59Z", // Sun 23:59:59 CEST
+	"2026-03-29T22:00:00Z", // Mon 30 Mar 00:00 CEST, new week
+	"2026-03-31T21:59:59Z", // Tue 23:59:59 CEST
+	"2026-03-31T22:00:00Z", // Wed 1 Apr 00:00 CEST
+	"2026-10-24T21:59:59Z", // Sat 23:59:59 CEST
+	"2026-10-24T22:00:00Z", // Sun 00:00 CEST
+	"2026-10-25T00:30:00Z", // Sun 02:30 CEST
+	"2026-10-25T01:30:00Z", // Sun 02:30 CET, the repeated hour
+	"2026-10-25T22:59:59Z", // Sun 23:59:59 CET
+	"2026-10-25T23:00:00Z", // Mon 26 Oct 00:00 CET, new week
+	"2026-10-31T22:59:59Z", // Sat 31 Oct 23:59:59 CET
+	"2026-10-31T23:00:00Z", // Sun 1 Nov 00:00 CET
+}
+
+type event struct {
+	ID uint
+	At time.Time
+}
+
+// newTestDB stores every edge twice, once in UTC and once with Berlin's
+// offset, the way rows written from different places end up in SQLite.
+func newTestDB(t *testing.T, loc *time.Location) (*gorm.DB, map[uint]time.Time) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // every connection would get its own database
+	if err := db.AutoMigrate(&event{}); err != nil {
+		t.Fatal(err)
+	}
+	at := map[uint]time.Time{}
+	for _, s := range edges {
+		for _, in := range []*time.Location{time.UTC, loc} {
+			e := event{At: utc(s).In(in)}
+			if err := db.Create(&e).Error; err != nil {
+				t.Fatal(err)
+			}
+			at[e.ID] = utc(s)
+		}
+	}
+	return db, at
+}
+
+func TestKeyExprMatchesGoAcrossDSTInSQLite(t *testing.T) {
+	loc := berlin(t)
+	db, at := newTestDB(t, loc)
+
+	ranges := map[string]Range{
+		"both changes": {From: utc("2025-12-01T00:00:00Z"), To: utc("2026-12-01T00:00:00Z")},
+		"spring only":  {From: utc("2026-03-28T00:00:00Z"), To: utc("2026-04-02T00:00:00Z")},
+		"autumn only":  {From: utc("2026-10-24T00:00:00Z"), To: utc("2026-11-02T00:00:00Z")},
+	}
+	for name, r := range ranges {
+		for _, g := range []Granularity{Day, Week, Month, Year} {
+			key, args := KeyExpr(SQLite, g, "at", loc, r)
+			var rows []struct {
+				ID     uint
+				Period string
+			}
+			cond, condArgs := RangeCond(SQLite, "at", r)
+			err := db.Model(&event{}).Select("id, "+key+" AS period", args...).Where(cond, condArgs...).Scan(&rows).Error
+			if err != nil {
+				t.Fatalf("%s/%s: %v", name, g, err)
+			}
+			if len(rows) == 0 {
+				t.Fatalf("%s/%s: no rows in range", name, g)
+			}
+			for _, row := range rows {
+				want := Key(g.Truncate(at[row.ID].In(loc)))
+				if row.Period != want {
+					t.Errorf("%s/%s: %s (stored as row %d) is in %s, SQLite says %s", name, g, at[row.ID].In(loc), row.ID, want, row.Period)
+				}
+			}
+		}
+	}
+}
+
+func TestSQLiteLocalUsesOneOffsetWithoutAChange(t *testing.T) {
+	loc := berlin(t)
+	expr, args := sqliteLocal("at", loc, Range{From: utc("2026-06-01T00:00:00Z"), To: utc("2026-07-01T00:00:00Z")})
+	if expr != "datetime(at, ?)" || len(args) != 1 || args[0] != "+120 minutes" {
+		t.Fatalf("got %s %v", expr, args)
+	}
+	expr, args = sqliteLocal("at", nil, Range{From: utc("2026-03-01T00:00:00Z"), To: utc("2026-11-01T00:00:00Z")})
+	if expr != "datetime(at, ?)" || args[0] != "+0 minutes" {
+		t.Fatalf("nil location: got %s %v", expr, args)
+	}
+}
+
+func TestOffsetsFindTransitionsToTheSecond(t *testing.T) {
+	loc := berlin(t)
+	segs := offsets(loc, utc("2026-01-01T00:00:00Z"), utc("2027-01-01T00:00:00Z"))
+	want := []segment{
+		{offset: 3600, until: utc("2026-03-29T01:00:00Z")},
+		{offset: 7200, until: utc("2026-10-25T01:00:00Z")},
+		{offset: 3600},
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("got %d segments %+v, want %d", len(segs), segs, len(want))
+	}
+	for i := range want {
+		if segs[i].offset != want[i].offset || !segs[i].until.Equal(want[i].until) {
+			t.Errorf("segment %d: got %+v, want %+v", i, segs[i], want[i])
+		}
+	}
+
+	// a range that starts right on a change is a single segment
+	if segs := offsets(loc, utc("2026-03-29T01:00:00Z"), utc("2026-05-01T00:00:00Z")); len(segs) != 1 || segs[0].offset != 7200 {
+		t.Fatalf("got %+v", segs)
+	}
+}
+
+func TestSeriesZeroFillsAndCountsAcrossDST(t *testing.T) {
+	loc := berlin(t)
+	db, _ := newTestDB(t, loc)
+
+	r := Range{From: time.Date(2026, 3, 23, 0, 0, 0, 0, loc), To: time.Date(2026, 4, 13, 0, 0, 0, 0, loc)}
+	points, err := Series(db.Model(&event{}), Week, "at", "1", loc, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		period string
+		count  int
+	}{
+		{"2026-03-23", 10}, // Sat, Sun 00:00, Sun 01:59:59, Sun 03:00, Sun 23:59:59, twice each
+		{"2026-03-30", 6},
+		{"2026-04-06", 0},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d", len(points), len(want))
+	}
+	for i, w := range want {
+		if points[i].Period != w.period || points[i].Count != w.count {
+			t.Errorf("point %d: got %s/%d, want %s/%d", i, points[i].Period, points[i].Count, w.period, w.count)
+		}
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	loc := berlin(t)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, loc) }
+
+	for _, tc := range []struct {
+		name string
+		g    Granularity
+		r    Range
+		want []time.Time
+	}{
+		{"days across spring DST", Day, Range{From: day(2026, 3, 28), To: day(2026, 3, 31)},
+			[]time.Time{day(2026, 3, 28), day(2026, 3, 29), day(2026, 3, 30)}},
+		{"days across autumn DST", Day, Range{From: day(2026, 10, 24).Add(12 * time.Hour), To: day(2026, 10, 26).Add(time.Second)},
+			[]time.Time{day(2026, 10, 24), day(2026, 10, 25), day(2026, 10, 26)}},
+		{"weeks start on Monday", Week, Range{From: day(2026, 3, 25), To: day(2026, 4, 2)},
+			[]time.Time{day(2026, 3, 23), day(2026, 3, 30)}},
+		{"week spanning new year", Week, Range{From: day(2026, 1, 1), To: day(2026, 1, 6)},
+			[]time.Time{day(2025, 12, 29), day(2026, 1, 5)}},
+		{"months", Month, Range{From: day(2026, 1, 31), To: day(2026, 3, 1)},
+			[]time.Time{day(2026, 1, 1), day(2026, 2, 1)}},
+		{"years", Year, Range{From: day(2025, 12, 31), To: day(2026, 1, 2)},
+			[]time.Time{day(2025, 1, 1), day(2026, 1, 1)}},
+	} {
+		got, err := tc.r.Buckets(tc.g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		for i := range got {
+			if !got[i].Equal(tc.want[i]) {
+				t.Errorf("%s: bucket %d is %s, want %s", tc.name, i, got[i], tc.want[i])
+			}
+		}
+	}
+
+	if _, err := (Range{From: day(2026, 3, 2), To: day(2026, 3, 1)}).Buckets(Day); !errors.Is(err, ErrRange) {
+		t.Fatalf("reversed range: got %v", err)
+	}
+	if _, err := (Range{From: day(2020, 1, 1), To: day(2026, 1, 1)}).Buckets(Day); !errors.Is(err, ErrRange) {
+		t.Fatalf("over MaxBuckets: got %v", err)
+	}
+}
+
+func TestPrevious(t *testing.T) {
+	loc := berlin(t)
+	at := func(y int, m time.Month, d, h int) time.Time { return time.Date(y, m, d, h, 0, 0, 0, loc) }
+
+	for _, tc := range []struct {
+		name string
+		g    Granularity
+		r    Range
+		want Range
+	}{
+		{"whole months", Month, Range{From: at(2026, 5, 1, 0), To: at(2026, 11, 1, 0)},
+			Range{From: at(2025, 11, 1, 0), To: at(2026, 5, 1, 0)}},
+		{"whole days over spring DST keep midnight", Day, Range{From: at(2026, 3, 30, 0), To: at(2026, 4, 1, 0)},
+			Range{From: at(2026, 3, 28, 0), To: at(2026, 3, 30, 0)}},
+		{"whole weeks", Week, Range{From: at(2026, 3, 23, 0), To: at(2026, 4, 6, 0)},
+			Range{From: at(2026, 3, 9, 0), To: at(2026, 3, 23, 0)}},
+		{"whole year", Year, Range{From: at(2026, 1, 1, 0), To: at(2027, 1, 1, 0)},
+			Range{From: at(2025, 1, 1, 0), To: at(2026, 1, 1, 0)}},
+		// 47 hours, as the night to 29 March is an hour short
+		{"partial days move by duration", Day, Range{From: at(2026, 3, 28, 12), To: at(2026, 3, 30, 12)},
+			Range{From: utc("2026-03-26T12:00:00Z"), To: at(2026, 3, 28, 12)}},
+	} {
+		got := tc.r.Previous(tc.g)
+		if !got.From.Equal(tc.want.From) || !got.To.Equal(tc.want.To) {
+			t.Errorf("%s: got %s – %s, want %s – %s", tc.name, got.From, got.To, tc.want.From, tc.want.To)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"luvy-go-backend/internal/outbox"
+)
+
+// DeliverEventType is the outbox event that carries one webhook to one
+// subscription. Failed deliveries retry on the outbox backoff curve.
+const DeliverEventType = "WEBHOOK_DELIVER"
+
+// Envelope is the JSON body every receiver gets. ID stays the same across
+// retries so receivers can drop duplicates.
+type Envelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type DeliverPayload struct {
+	SubscriptionID string   `json:"subscription_id"`
+	Envelope       Envelope `json:"envelope"`
+}
+
+func (p DeliverPayload) Validate() error {
+	if p.SubscriptionID == "" || p.Envelope.ID == "" || p.Envelope.Type == "" {
+		return errors.New("subscription_id, envelope.id and envelope.type are required")
+	}
+	return nil
+}
+
+// Deliverer publishes events to subscriptions and performs the HTTP calls.
+type Deliverer struct {
+	Repo   *Repo
+	Outbox *outbox.Repo
+	Client *http.Client
+}
+
+func NewDeliverer(repo *Repo) *Deliverer {
+	return &Deliverer{Repo: repo, Client: NewClient()}
+}
+
+// Register adds WEBHOOK_DELIVER to reg; repo is where Publish enqueues.
+func (d *Deliverer) Register(reg *outbox.Registry, repo *outbox.Repo) {
+	d.Outbox = repo
+	outbox.Register(reg, DeliverEventType, outbox.Policy{Timeout: 30 * time.Second}, d.deliver)
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// Publish queues one WEBHOOK_DELIVER per active subscription to eventType
+// (and merchant) through q, so callers can make it part of a transaction.
+func (d *Deliverer) Publish(ctx context.Context, q outbox.DBTX, eventType, merchant string, data any) error {
+	subs, err := d.Repo.Matching(ctx, eventType, merchant)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, s := range subs {
+		p := DeliverPayload{
+			SubscriptionID: s.ID,
+			Envelope:       Envelope{ID: newEventID(), Type: eventType, CreatedAt: now, Data: b},
+		}
+		if err := d.Outbox.EnqueueTx(ctx, q, "webhook_subscription", &s.ID, DeliverEventType, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Deliverer) deliver(ctx context.Context, ev outbox.Event, p DeliverPayload) error {
+	sub, err := d.Repo.Get(ctx, p.SubscriptionID)
+	if errors.Is(err, ErrNotFound) {
+		return outbox.Skip("webhook subscription deleted")
+	}
+	if err != nil {
+		return err
+	}
+	if !sub.Active {
+		return outbox.Skip("webhook subscription inactive")
+	}
+
+	_, err = d.Send(ctx, sub, p.Envelope, ev.AttemptCount+1)
+	return err
+}
+
+// Test sends a webhook.test event to the subscription right away, whether
+// or not it is active, and returns the logged attempt.
+func (d *Deliverer) Test(ctx context.Context, id string) (Delivery, error) {
+	sub, err := d.Repo.Get(ctx, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	env := Envelope{
+		ID:        newEventID(),
+		Type:      EventTest,
+		CreatedAt: time.Now().UTC(),
+		Data:      json.RawMessage(`{"message":"This is a test delivery from LUVY."}`),
+	}
+	return d.Send(ctx, sub, env, 1)
+}
+
+// Send POSTs env to the subscription once and logs the attempt. A non-2xx
+// response is an error; a private address is a permanent one.
+func (d *Deliverer) Send(ctx context.Context, sub Subscription, env Envelope, attempt int) (Delivery, error) {
+	secrets, err := d.Repo.Secrets(ctx, sub.ID)
+	if err != nil {
+		return Delivery{}, err
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return Delivery{}, outbox.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Luvy-Webhooks/1")
+	req.Header.Set("X-Luvy-Event", env.Type)
+	req.Header.Set("X-Luvy-Delivery", env.ID)
+	req.Header.Set(SignatureHeader, Sign(body, time.Now(), secrets...))
+
+	del := Delivery{SubscriptionID: sub.ID, WebhookEventID: env.ID, EventType: env.Type, Attempt: attempt}
+	start := time.Now()
+	resp, sendErr := d.client().Do(req)
+	del.DurationMS = int(time.Since(start).Milliseconds())
+	if sendErr == nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		del.StatusCode = &resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			sendErr = fmt.Errorf("webhook answered HTTP %d", resp.StatusCode)
+		}
+	}
+	if sendErr != nil {
+		msg := sendErr.Error()
+		del.Error = &msg
+	}
+
+	if err := d.Repo.LogDelivery(ctx, del); err != nil {
+		return del, errors.Join(sendErr, err)
+	}
+	del.CreatedAt = time.Now()
+	if errors.Is(sendErr, ErrForbiddenAddress) {
+		// retrying cannot make the address public
+		return del, outbox.Permanent(sendErr)
+	}
+	return del, sendErr
+}
+
+var defaultClient = NewClient()
+
+func (d *Deliverer) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return defaultClient
+}
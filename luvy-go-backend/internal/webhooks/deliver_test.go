@@ -0,0 +1,182 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/pgtest"
+)
+
+// receiver records every request and answers with the next status in
+// statuses, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	got      []received
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.got = append(rc.got, received{r.Header.Clone(), body})
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestDeliverSignsRetriesAndLogs(t *testing.T) {
+	db := pgtest.Open(t)
+	ctx := context.Background()
+
+	rc := &receiver{statuses: []int{http.StatusInternalServerError}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	repo := NewRepo(db)
+	sub, err := repo.Create(ctx, Subscription{URL: "https://hooks.example.com/luvy", EventTypes: []string{EventReceiptApproved}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Validate refuses loopback URLs; point the stored one at the receiver
+	if _, err := db.ExecContext(ctx, `UPDATE webhook_subscriptions SET url=$2 WHERE id::text=$1`, sub.ID, srv.URL+"/hook"); err != nil {
+		t.Fatal(err)
+	}
+
+	ob := outbox.NewRepo(db)
+	d := NewDeliverer(repo)
+	d.Client = NewClient(netip.MustParsePrefix("127.0.0.0/8"))
+	d.Register(outbox.NewRegistry(), ob)
+
+	if err := d.Publish(ctx, db, EventReceiptApproved, "Acme", map[string]any{"receipt_id": 7}); err != nil {
+		t.Fatal(err)
+	}
+	evs, err := ob.ClaimBatch(ctx, "test", 10, time.Minute)
+	if err != nil || len(evs) != 1 {
+		t.Fatalf("claim: %v %v", evs, err)
+	}
+	ev := evs[0]
+	var p DeliverPayload
+	if err := json.Unmarshal(ev.Payload, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first attempt gets a 500 and must fail so the outbox retries it
+	if err := d.deliver(ctx, ev, p); err == nil {
+		t.Fatal("a 500 response was treated as delivered")
+	}
+	ev.AttemptCount++
+	if err := d.deliver(ctx, ev, p); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	if len(rc.got) != 2 {
+		t.Fatalf("receiver got %d requests, want 2", len(rc.got))
+	}
+	for i, r := range rc.got {
+		if err := Verify(r.header.Get(SignatureHeader), r.body, sub.Secret, time.Now()); err != nil {
+			t.Errorf("request %d: %v", i+1, err)
+		}
+		if id := r.header.Get("X-Luvy-Delivery"); id != p.Envelope.ID {
+			t.Errorf("request %d: delivery id %q, want %q on every retry", i+1, id, p.Envelope.ID)
+		}
+		if typ := r.header.Get("X-Luvy-Event"); typ != EventReceiptApproved {
+			t.Errorf("request %d: event %q", i+1, typ)
+		}
+	}
+	if err := Verify(rc.got[0].header.Get(SignatureHeader), rc.got[0].body, "whsec_wrong", time.Now()); err != ErrBadSignature {
+		t.Errorf("wrong secret: got %v, want ErrBadSignature", err)
+	}
+
+	log, err := repo.Deliveries(ctx, sub.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 {
+		t.Fatalf("logged %d deliveries, want 2", len(log))
+	}
+	retry, first := log[0], log[1]
+	if first.Attempt != 1 || first.StatusCode == nil || *first.StatusCode != 500 || first.Error == nil {
+		t.Errorf("first attempt logged as %+v", first)
+	}
+	if retry.Attempt != 2 || retry.StatusCode == nil || *retry.StatusCode != 200 || retry.Error != nil {
+		t.Errorf("retry logged as %+v", retry)
+	}
+	if first.WebhookEventID != p.Envelope.ID || retry.WebhookEventID != p.Envelope.ID {
+		t.Errorf("logged event ids %s, %s, want %s", first.WebhookEventID, retry.WebhookEventID, p.Envelope.ID)
+	}
+}
+
+func TestDeliverSignsWithBothSecretsDuringRotation(t *testing.T) {
+	db := pgtest.Open(t)
+	ctx := context.Background()
+
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	repo := NewRepo(db)
+	old, err := repo.Create(ctx, Subscription{URL: "https://hooks.example.com/luvy", EventTypes: []string{EventReceiptApproved}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE webhook_subscriptions SET url=$2 WHERE id::text=$1`, old.ID, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := repo.RotateSecret(ctx, old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDeliverer(repo)
+	d.Client = NewClient(netip.MustParsePrefix("127.0.0.0/8"))
+	if _, err := d.Test(ctx, old.ID); err != nil {
+		t.Fatal(err)
+	}
+	r := rc.got[0]
+	for _, secret := range []string{old.Secret, rotated.Secret} {
+		if err := Verify(r.header.Get(SignatureHeader), r.body, secret, time.Now()); err != nil {
+			t.Errorf("during rotation: %v", err)
+		}
+	}
+}
+
+func TestSendToPrivateAddressIsPermanent(t *testing.T) {
+	db := pgtest.Open(t)
+	ctx := context.Background()
+
+	srv := httptest.NewServer(&receiver{})
+	defer srv.Close()
+
+	repo := NewRepo(db)
+	sub, err := repo.Create(ctx, Subscription{URL: "https://hooks.example.com/luvy", EventTypes: []string{EventReceiptApproved}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as if the name had been rebound to loopback after validation
+	sub.URL = srv.URL
+
+	del, err := NewDeliverer(repo).Send(ctx, sub, Envelope{ID: "evt_1", Type: EventTest}, 1)
+	if err == nil || !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("got %v, want ErrForbiddenAddress", err)
+	}
+	if del.StatusCode != nil || del.Error == nil {
+		t.Fatalf("logged %+v", del)
+	}
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook URL points, or resolves, to
+// an address on a private network.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// reserved ranges that IsPrivate and IsGlobalUnicast let through.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, also cloud metadata
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// public reports whether ip is routable on the internet: not loopback,
+// link-local (where cloud metadata lives), RFC 1918, unique local or
+// otherwise reserved.
+func public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range reserved {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Guard vets every address the delivery client connects to. It runs after
+// DNS resolution, on each dial, so a name that resolves to a public address
+// at validation time and to an internal one later is still refused. Allow
+// lists ranges that are let through anyway.
+type Guard struct {
+	Allow []netip.Prefix
+}
+
+// Control is a net.Dialer Control function.
+func (g Guard) Control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	ip := ap.Addr().Unmap()
+	for _, p := range g.Allow {
+		if p.Contains(ip) {
+			return nil
+		}
+	}
+	if !public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// NewClient returns the HTTP client deliveries go through. It dials only
+// public addresses, plus allow, and ignores proxy settings, which would
+// otherwise make the proxy the only address checked.
+func NewClient(allow ...netip.Prefix) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   Guard{Allow: allow}.Control,
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// checkHost rejects URLs naming a private address or localhost outright, so
+// the mistake shows when the subscription is saved. Names that resolve to
+// one are caught by Guard at delivery time.
+func checkHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
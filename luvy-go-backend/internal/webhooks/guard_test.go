@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false, // cloud metadata
+		"100.100.100.200": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00:ec2::254":   false,
+		"::ffff:10.0.0.1": false,
+		"224.0.0.1":       false,
+	} {
+		if got := public(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("public(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestValidateRejectsPrivateHosts(t *testing.T) {
+	for _, u := range []string{
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"https://10.0.0.5/hook",
+	} {
+		s := Subscription{URL: u, EventTypes: []string{EventReceiptApproved}}
+		if err := s.Validate(); !errors.Is(err, ErrInvalid) || !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: got %v", u, err)
+		}
+	}
+	s := Subscription{URL: "https://hooks.example.com/luvy", EventTypes: []string{EventReceiptApproved}}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	if _, err := NewClient().Do(req); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("guarded client reached %s: %v", srv.URL, err)
+	}
+
+	resp, err := NewClient(netip.MustParsePrefix("127.0.0.0/8")).Do(req)
+	if err != nil {
+		t.Fatalf("allow-listed loopback: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries "t=<unix seconds>,v1=<hex>[,v1=<hex>]". Each v1 is
+// HMAC-SHA256 over "<t>.<body>" with one of the subscription's secrets.
+const SignatureHeader = "X-Luvy-Signature"
+
+// Tolerance is how old a signature timestamp Verify accepts.
+const Tolerance = 5 * time.Minute
+
+var (
+	ErrBadSignature = errors.New("webhook signature does not match")
+	ErrStale        = errors.New("webhook signature timestamp outside tolerance")
+)
+
+// Sign builds the SignatureHeader value for body at t.
+func Sign(body []byte, t time.Time, secrets ...string) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	parts := []string{"t=" + ts}
+	for _, s := range secrets {
+		parts = append(parts, "v1="+mac(s, ts, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verify checks a SignatureHeader value the way a receiver should: the
+// timestamp must be within Tolerance of now and any v1 may match.
+func Verify(header string, body []byte, secret string, now time.Time) error {
+	var ts string
+	var sigs []string
+	for _, p := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > Tolerance || d < -Tolerance {
+		return ErrStale
+	}
+
+	want := mac(secret, ts, body)
+	for _, s := range sigs {
+		if hmac.Equal([]byte(s), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+func mac(secret, ts string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
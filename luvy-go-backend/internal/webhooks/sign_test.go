@@ -0,0 +1,30 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+	header := Sign(body, now, "whsec_new", "whsec_old")
+
+	for _, secret := range []string{"whsec_new", "whsec_old"} {
+		if err := Verify(header, body, secret, now); err != nil {
+			t.Errorf("%s: %v", secret, err)
+		}
+	}
+	if err := Verify(header, body, "whsec_other", now); err != ErrBadSignature {
+		t.Errorf("other secret: %v", err)
+	}
+	if err := Verify(header, []byte(`{"id":"evt_2"}`), "whsec_new", now); err != ErrBadSignature {
+		t.Errorf("altered body: %v", err)
+	}
+	if err := Verify(header, body, "whsec_new", now.Add(Tolerance+time.Second)); err != ErrStale {
+		t.Errorf("replayed late: %v", err)
+	}
+	if err := Verify("v1=abc", body, "whsec_new", now); err != ErrBadSignature {
+		t.Errorf("no timestamp: %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event types partners can subscribe to.
+const (
+	EventReceiptApproved = "receipt.approved"
+	// EventTest is only sent by the test endpoint and cannot be subscribed.
+	EventTest = "webhook.test"
+)
+
+// EventTypes lists the subscribable event types.
+var EventTypes = []string{EventReceiptApproved}
+
+// RotationGrace is how long the old secret keeps signing after a rotation.
+const RotationGrace = 24 * time.Hour
+
+var (
+	ErrNotFound = errors.New("webhook subscription not found")
+	ErrInvalid  = errors.New("invalid webhook subscription")
+)
+
+// Subscription is a partner endpoint. Secret is only filled in when it was
+// just created or rotated; listings never show it.
+type Subscription struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventTypes  []string  `json:"event_types"`
+	Merchant    *string   `json:"merchant"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Validate checks the URL, which must not name a private address, and the
+// event types, and normalises the merchant.
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalid)
+	}
+	if err := checkHost(u.Hostname()); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if len(s.EventTypes) == 0 {
+		return fmt.Errorf("%w: at least one event type is required", ErrInvalid)
+	}
+	for _, t := range s.EventTypes {
+		if !knownType(t) {
+			return fmt.Errorf("%w: unknown event type %s", ErrInvalid, t)
+		}
+	}
+	if s.Merchant != nil {
+		if m := strings.TrimSpace(*s.Merchant); m == "" {
+			s.Merchant = nil
+		} else {
+			s.Merchant = &m
+		}
+	}
+	return nil
+}
+
+func knownType(t string) bool {
+	for _, k := range EventTypes {
+		if k == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one logged HTTP attempt.
+type Delivery struct {
+	ID             int64     `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	WebhookEventID string    `json:"webhook_event_id"`
+	EventType      string    `json:"event_type"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     *int      `json:"status_code"`
+	Error          *string   `json:"error"`
+	DurationMS     int       `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Repo struct{ DB *sql.DB }
+
+func NewRepo(db *sql.DB) *Repo { return &Repo{DB: db} }
+
+func newSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+const subscriptionColumns = `id, url, event_types, merchant, description, active, created_at, updated_at`
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scanSubscription(sc scanner, s *Subscription) error {
+	var types []byte
+	if err := sc.Scan(&s.ID, &s.URL, &types, &s.Merchant, &s.Description, &s.Active, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(types, &s.EventTypes)
+}
+
+// Create stores s with a fresh secret and returns it, secret included.
+func (r *Repo) Create(ctx context.Context, s Subscription) (Subscription, error) {
+	if err := s.Validate(); err != nil {
+		return s, err
+	}
+	types, _ := json.Marshal(s.EventTypes)
+	secret := newSecret()
+
+	var out Subscription
+	err := scanSubscription(r.DB.QueryRowContext(ctx, `
+INSERT INTO webhook_subscriptions (url, secret, event_types, merchant, description)
+VALUES ($1, $2, $3::jsonb, $4, $5)
+RETURNING `+subscriptionColumns, s.URL, secret, string(types), s.Merchant, s.Description), &out)
+	out.Secret = secret
+	return out, err
+}
+
+func (r *Repo) List(ctx context.Context) ([]Subscription, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Subscription{}
+	for rows.Next() {
+		var s Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) Get(ctx context.Context, id string) (Subscription, error) {
+	var s Subscription
+	err := scanSubscription(r.DB.QueryRowContext(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id::text = $1`, id), &s)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+// Update replaces url, event types, merchant, description and active.
+func (r *Repo) Update(ctx context.Context, s Subscription) (Subscription, error) {
+	if err := s.Validate(); err != nil {
+		return s, err
+	}
+	types, _ := json.Marshal(s.EventTypes)
+
+	var out Subscription
+	err := scanSubscription(r.DB.QueryRowContext(ctx, `
+UPDATE webhook_subscriptions
+SET url=$2, event_types=$3::jsonb, merchant=$4, description=$5, active=$6, updated_at=NOW()
+WHERE id::text = $1
+RETURNING `+subscriptionColumns, s.ID, s.URL, string(types), s.Merchant, s.Description, s.Active), &out)
+	if errors.Is(err, sql.ErrNoRows) {
+		return out, ErrNotFound
+	}
+	return out, err
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id::text = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RotateSecret issues a new secret. The old one keeps signing for
+// RotationGrace so the receiver can deploy the new one first.
+func (r *Repo) RotateSecret(ctx context.Context, id string) (Subscription, error) {
+	secret := newSecret()
+	var out Subscription
+	err := scanSubscription(r.DB.QueryRowContext(ctx, `
+UPDATE webhook_subscriptions
+SET previous_secret=secret, previous_expires_at=NOW() + make_interval(secs => $3), secret=$2, updated_at=NOW()
+WHERE id::text = $1
+RETURNING `+subscriptionColumns, id, secret, RotationGrace.Seconds()), &out)
+	if errors.Is(err, sql.ErrNoRows) {
+		return out, ErrNotFound
+	}
+	out.Secret = secret
+	return out, err
+}
+
+// Secrets returns the secrets a delivery is signed with now: the current one
+// and, during a rotation, the previous one.
+func (r *Repo) Secrets(ctx context.Context, id string) ([]string, error) {
+	var secret string
+	var previous sql.NullString
+	err := r.DB.QueryRowContext(ctx, `
+SELECT secret, CASE WHEN previous_expires_at > NOW() THEN previous_secret END
+FROM webhook_subscriptions WHERE id::text = $1
+`, id).Scan(&secret, &previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if previous.Valid {
+		return []string{secret, previous.String}, nil
+	}
+	return []string{secret}, nil
+}
+
+// Matching returns the active subscriptions for eventType. merchant narrows
+// to subscriptions for that merchant or for all merchants.
+func (r *Repo) Matching(ctx context.Context, eventType, merchant string) ([]Subscription, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT `+subscriptionColumns+` FROM webhook_subscriptions
+WHERE active
+  AND event_types @> jsonb_build_array($1::text)
+  AND (merchant IS NULL OR lower(merchant) = lower($2))
+`, eventType, merchant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) LogDelivery(ctx context.Context, d Delivery) error {
+	_, err := r.DB.ExecContext(ctx, `
+INSERT INTO webhook_deliveries (subscription_id, webhook_event_id, event_type, attempt, status_code, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+`, d.SubscriptionID, d.WebhookEventID, d.EventType, d.Attempt, d.StatusCode, d.Error, d.DurationMS)
+	return err
+}
+
+// Deliveries returns the newest attempts for a subscription first.
+func (r *Repo) Deliveries(ctx context.Context, id string, limit int) ([]Delivery, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT id, subscription_id, webhook_event_id, event_type, attempt, status_code, error, duration_ms, created_at
+FROM webhook_deliveries
+WHERE subscription_id::text = $1
+ORDER BY id DESC
+LIMIT $2
+`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.WebhookEventID, &d.EventType, &d.Attempt, &d.StatusCode, &d.Error, &d.DurationMS, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -1,20 +1,46 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
 	"log"
+	"math"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 
 	"luvy-go-backend/database"
+	"luvy-go-backend/internal/account"
+	"luvy-go-backend/internal/analytics"
+	"luvy-go-backend/internal/audit"
+	"luvy-go-backend/internal/domain"
+	"luvy-go-backend/internal/exports"
+	"luvy-go-backend/internal/gamification"
+	"luvy-go-backend/internal/gdpr"
+	"luvy-go-backend/internal/inbox"
+	"luvy-go-backend/internal/leaderboard"
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/notifications/prefs"
+	"luvy-go-backend/internal/notifications/push"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/platform/logger"
+	"luvy-go-backend/internal/realtime"
+	"luvy-go-backend/internal/webhooks"
 	"luvy-go-backend/src/handlers"
 	"luvy-go-backend/src/models"
 )
 
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
 func main() {
 	// ---- DB CONNECT ----
 	db, err := database.Connect()
@@ -28,19 +54,102 @@ func main() {
 		&models.Receipt{},
 		&models.Transaction{},
 		&models.Merchant{},
+		&models.UserLevel{},
+		&models.Achievement{},
+		&models.UserAchievement{},
+		&models.Challenge{},
+		&models.UserChallenge{},
+		&models.ReferralCode{},
+		&models.Referral{},
+		&models.LeaderboardEntry{},
+		&models.UserStreak{},
+		&models.NotificationPreference{},
+		&models.Notification{},
+		&models.Budget{},
+		&models.BudgetAlert{},
+		&models.UserInsight{},
+		&models.AccountToken{},
 	); err != nil {
 		panic(err)
 	}
 
+	if err := leaderboard.NewService(db).BackfillIfEmpty(); err != nil {
+		panic(err)
+	}
+
+	// ---- OUTBOX & REALTIME ----
+	hub := realtime.NewHub()
+	allowedOrigins := []string{"http://localhost:3000"}
+	realtimeServer := &realtime.Server{Hub: hub, Tokens: realtime.NewTokens(os.Getenv("REALTIME_SECRET")), AllowedOrigins: allowedOrigins}
+	var events realtime.Emitter = realtime.DirectEmitter{Hub: hub}
+	pushHandler := handlers.NewPushHandler(nil, nil)
+	outboxHandler := handlers.NewOutboxHandler(nil)
+	webhookHandler := handlers.NewWebhookHandler(nil, nil)
+	exportHandler := handlers.NewExportHandler(nil, nil)
+	privacyHandler := handlers.NewPrivacyHandler(nil)
+	var auditLog *audit.Log
+	var privacy *gdpr.Service
+	var outboxRepo *outbox.Repo
+	eventsHandler := handlers.NewEventsHandler(nil, nil)
+	unsubscribeLinks := prefs.NewLinks(os.Getenv("UNSUBSCRIBE_SECRET"), publicBaseURL())
+
+	if database.IsPostgres(db) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			panic(err)
+		}
+		pushTokens := push.NewTokenRepo(sqlDB)
+		hooks := webhooks.NewRepo(sqlDB)
+		deliverer := webhooks.NewDeliverer(hooks)
+		exportRepo := exports.NewRepo(sqlDB)
+		exportGen := exports.NewGenerator(exportRepo, publicBaseURL())
+		auditLog = audit.NewLog(sqlDB)
+		privacy = gdpr.NewService(sqlDB, exportRepo, auditLog)
+		zl, err := logger.New("luvy-go-backend")
+		if err != nil {
+			panic(err)
+		}
+		tracker := analytics.NewBuffer(sqlDB, 10000, zl)
+		repo := startOutbox(sqlDB, zl, &outbox.Dispatcher{
+			Email:       smtpFromEnv(),
+			Realtime:    realtime.Broadcast{DB: sqlDB},
+			Push:        push.NewSender(pushTokens, pushProvidersFromEnv()),
+			Inbox:       inbox.NewWriter(sqlDB),
+			Prefs:       prefs.NewChecker(sqlDB),
+			Links:       unsubscribeLinks,
+			Attachments: exportRepo,
+		}, domain.Reactions{Webhooks: deliverer, Analytics: tracker}.Register, deliverer.Register, exportGen.Register, privacy.Register)
+		if err := db.Use(domain.Recorder{Repo: repo}); err != nil {
+			panic(err)
+		}
+		go tracker.Run(context.Background())
+		go auditLog.Run(context.Background(), time.Second)
+		go realtime.Relay(context.Background(), sqlDB, hub, zl)
+		events = realtime.OutboxEmitter{Repo: repo}
+		outboxRepo = repo
+		pushHandler = handlers.NewPushHandler(pushTokens, repo)
+		outboxHandler = handlers.NewOutboxHandler(repo)
+		outboxHandler.RetentionDays = outboxRetentionDays()
+		webhookHandler = handlers.NewWebhookHandler(hooks, deliverer)
+		exportHandler = handlers.NewExportHandler(exportRepo, repo)
+		go purgeExports(exportRepo)
+		privacyHandler = handlers.NewPrivacyHandler(privacy)
+		eventsHandler = handlers.NewEventsHandler(tracker, analytics.NewStore(sqlDB))
+		realtimeServer.Replay = realtime.OutboxReplay{DB: sqlDB}
+	} else {
+		log.Println("⚠️  SQLite mode: outbox disabled, realtime events are not persisted")
+	}
+
 	// Seed merchants
 	seedMerchants(db)
+	seedAchievements(db)
 
 	// ---- INIT GIN ----
 	r := gin.Default()
 
 	// ---- CORS ----
 	cfg := cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
+		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		AllowCredentials: true,
@@ -48,20 +157,6 @@ func main() {
 	}
 	r.Use(cors.New(cfg))
 
-	// ---- SIMPLE USER MIDDLEWARE ----
-	r.Use(func(c *gin.Context) {
-		userID := uint(1) // Default user
-
-		if v := c.GetHeader("X-User-ID"); v != "" {
-			if n, e := strconv.ParseUint(v, 10, 64); e == nil && n > 0 {
-				userID = uint(n)
-			}
-		}
-
-		c.Set("userID", userID)
-		c.Next()
-	})
-
 	// ---- HEALTH CHECK ----
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
@@ -69,9 +164,32 @@ func main() {
 
 	// ---- INIT HANDLERS ----
 	receiptH := handlers.NewReceiptHandler(db)
+	receiptH.Events = events
+	receiptH.Audit = auditLog
+	receiptH.Budgets.Outbox = outboxRepo
 	userHandler := handlers.NewUserHandler(db)
 	analyticsHandler := handlers.NewAnalyticsHandler(db)
+	go analyticsHandler.Insights.Run(context.Background(), insightsInterval())
 	adminHandler := handlers.NewAdminHandler(db)
+	auditHandler := handlers.NewAuditHandler(auditLog)
+	gamificationHandler := handlers.NewGamificationHandler(db)
+	challengeHandler := handlers.NewChallengeHandler(db)
+	accounts := account.NewService(db)
+	accounts.Outbox = outboxRepo
+	accounts.Audit = auditLog
+	accounts.Privacy = privacy
+	accounts.BaseURL = publicBaseURL()
+	accounts.ResetURL = passwordResetURL()
+	userHandler.Account = accounts
+	authHandler := handlers.NewAuthHandler(db)
+	authHandler.Account = accounts
+	referralHandler := handlers.NewReferralHandler(db)
+	leaderboardHandler := handlers.NewLeaderboardHandler(db)
+	realtimeHandler := handlers.NewRealtimeHandler(realtimeServer)
+	emailHandler := handlers.NewEmailHandler()
+	notificationHandler := handlers.NewNotificationHandler(db, unsubscribeLinks)
+	inboxHandler := handlers.NewInboxHandler(db)
+	budgetHandler := handlers.NewBudgetHandler(db)
 
 	// ---- PUBLIC ROUTES ----
 	r.GET("/api/merchants", func(c *gin.Context) {
@@ -83,13 +201,41 @@ func main() {
 		c.JSON(200, gin.H{"merchants": merchants})
 	})
 
+	r.POST("/api/auth/register", authHandler.Register)
+	r.POST("/api/auth/login", authHandler.Login)
+	r.POST("/api/auth/logout", authHandler.Logout)
+	r.GET("/api/auth/verify-email", authHandler.VerifyEmail)
+	r.POST("/api/auth/verify-email", authHandler.VerifyEmail)
+	r.POST("/api/auth/password/forgot", authHandler.ForgotPassword)
+	r.POST("/api/auth/password/reset", authHandler.ResetPassword)
+
+	// Deactivated accounts may only come back, with their password; every
+	// other route refuses them.
+	r.POST("/api/user/reactivate", userHandler.Reactivate)
+
+	// Streams authenticate with the token from /api/realtime/token, passed
+	// as ?token= since browsers cannot set headers on them.
+	r.GET("/api/realtime/ws", realtimeHandler.WebSocket)
+	r.GET("/api/realtime/events", realtimeHandler.Events)
+
+	// Signed links from emails; the token identifies the user.
+	r.GET("/api/notifications/unsubscribe", notificationHandler.Unsubscribe)
+	r.POST("/api/notifications/unsubscribe", notificationHandler.Unsubscribe)
+	r.GET("/api/exports/download/:token", exportHandler.DownloadByToken)
+
 	// ---- API ROUTES ----
-	api := r.Group("/api")
+	// A session from /api/auth/login identifies the user; see
+	// legacyUserHeader for clients that do not log in yet.
+	authenticate := account.Authenticate(accounts, legacyUserHeader())
+	api := r.Group("/api", authenticate, account.RequireActive(db))
 	{
 		// User routes
 		api.GET("/user/profile", userHandler.GetProfile)
 		api.PUT("/user/profile", userHandler.UpdateProfile)
 		api.GET("/user/balance", userHandler.GetBalance)
+		api.POST("/user/verify-email/resend", userHandler.ResendVerification)
+		api.POST("/user/deactivate", userHandler.Deactivate)
+		api.DELETE("/user/account", userHandler.DeleteAccount)
 
 		// Receipt routes
 		receipts := api.Group("/receipts")
@@ -109,23 +255,293 @@ func main() {
 			analytics.GET("/spending", analyticsHandler.GetSpending)
 			analytics.GET("/categories", analyticsHandler.GetCategories)
 			analytics.GET("/merchants", analyticsHandler.GetTopMerchants)
+			analytics.GET("/insights", analyticsHandler.GetInsights)
+			analytics.POST("/events", eventsHandler.Ingest)
+			analytics.GET("/events/schemas", eventsHandler.Schemas)
+		}
+
+		// Budget routes
+		api.GET("/budgets", budgetHandler.List)
+		api.GET("/budgets/summary", budgetHandler.Summary)
+		api.POST("/budgets", budgetHandler.Set)
+		api.PUT("/budgets/:id", budgetHandler.Update)
+		api.DELETE("/budgets/:id", budgetHandler.Delete)
+
+		// Data export routes
+		api.GET("/exports", exportHandler.List)
+		api.POST("/exports", exportHandler.Create)
+		api.GET("/exports/:id", exportHandler.Get)
+		api.GET("/exports/:id/download", exportHandler.Download)
+
+		// Privacy (GDPR) routes
+		api.GET("/privacy/requests", privacyHandler.List)
+		api.POST("/privacy/access", privacyHandler.RequestAccess)
+		api.POST("/privacy/erasure", privacyHandler.RequestErasure)
+		api.POST("/privacy/erasure/cancel", privacyHandler.CancelErasure)
+
+		// Gamification routes
+		gamificationRoutes := api.Group("/gamification")
+		{
+			gamificationRoutes.GET("/level", gamificationHandler.GetLevel)
+			gamificationRoutes.GET("/achievements", gamificationHandler.GetAchievements)
 		}
 
+		// Challenge routes
+		api.GET("/challenges", challengeHandler.GetProgress)
+
+		// Referral routes
+		api.GET("/referrals", referralHandler.GetReferrals)
+		api.GET("/referrals/code", referralHandler.GetCode)
+
+		// Leaderboard routes
+		api.GET("/leaderboard", leaderboardHandler.GetLeaderboard)
+		api.PUT("/leaderboard/privacy", leaderboardHandler.UpdatePrivacy)
+
+		// Realtime routes (the streams are routed above)
+		api.POST("/realtime/token", realtimeHandler.IssueToken)
+
+		// In-app inbox routes
+		api.GET("/notifications", inboxHandler.List)
+		api.GET("/notifications/unread-count", inboxHandler.UnreadCount)
+		api.POST("/notifications/read-all", inboxHandler.MarkAllRead)
+		api.POST("/notifications/:id/read", inboxHandler.MarkRead)
+		api.DELETE("/notifications/:id", inboxHandler.Delete)
+
+		// Notification preference routes
+		api.GET("/notifications/preferences", notificationHandler.GetPreferences)
+		api.PUT("/notifications/preferences", notificationHandler.UpdatePreferences)
+
+		// Push routes
+		api.POST("/push/tokens", pushHandler.RegisterToken)
+		api.DELETE("/push/tokens", pushHandler.UnregisterToken)
+
 		// Admin routes
-		admin := api.Group("/admin")
+		// Every admin write, and the reads tagged below, go to the audit log.
+		admin := api.Group("/admin", audit.Middleware(auditLog, audit.RoleAdmin))
 		{
 			admin.GET("/dashboard", adminHandler.GetDashboard)
-			admin.GET("/users", adminHandler.GetUsers)
+			admin.GET("/users", audit.Tag("user.list"), adminHandler.GetUsers)
 			admin.GET("/revenue", adminHandler.GetRevenue)
 			admin.GET("/merchants", adminHandler.GetTopMerchants)
+			admin.GET("/challenges", challengeHandler.ListChallenges)
+			admin.POST("/challenges", audit.Tag("challenge.create"), challengeHandler.CreateChallenge)
+			admin.DELETE("/challenges/:id", audit.Tag("challenge.deactivate"), challengeHandler.DeactivateChallenge)
+			admin.POST("/push", audit.Tag("push.send"), pushHandler.SendPush)
+			admin.GET("/outbox", outboxHandler.List)
+			admin.GET("/outbox/:id", audit.Tag("outbox.read"), outboxHandler.Get)
+			admin.POST("/outbox/requeue", audit.Tag("outbox.requeue"), outboxHandler.Requeue)
+			admin.POST("/outbox/cancel", audit.Tag("outbox.cancel"), outboxHandler.Cancel)
+			admin.POST("/outbox/purge", audit.Tag("outbox.purge"), outboxHandler.Purge)
+			admin.POST("/outbox/:id/requeue", audit.Tag("outbox.requeue"), outboxHandler.Requeue)
+			admin.POST("/outbox/:id/cancel", audit.Tag("outbox.cancel"), outboxHandler.Cancel)
+			admin.GET("/webhooks", webhookHandler.List)
+			admin.POST("/webhooks", audit.Tag("webhook.create"), webhookHandler.Create)
+			admin.GET("/webhooks/:id", webhookHandler.Get)
+			admin.PUT("/webhooks/:id", audit.Tag("webhook.update"), webhookHandler.Update)
+			admin.DELETE("/webhooks/:id", audit.Tag("webhook.delete"), webhookHandler.Delete)
+			admin.POST("/webhooks/:id/rotate-secret", audit.Tag("webhook.rotate_secret"), webhookHandler.RotateSecret)
+			admin.POST("/webhooks/:id/test", audit.Tag("webhook.test"), webhookHandler.Test)
+			admin.GET("/webhooks/:id/deliveries", webhookHandler.Deliveries)
+			admin.GET("/analytics/funnel", eventsHandler.Funnel)
+			admin.GET("/analytics/retention", eventsHandler.Retention)
+			admin.GET("/audit", audit.Tag("audit.read"), auditHandler.List)
+			admin.GET("/audit/export", audit.Tag("audit.export"), auditHandler.Export)
+			admin.GET("/audit/verify", auditHandler.Verify)
+			admin.GET("/privacy/requests", audit.Tag("privacy.list"), privacyHandler.AdminList)
+			admin.GET("/emails/templates", emailHandler.ListTemplates)
+			admin.GET("/emails/templates/:template/preview", emailHandler.Preview)
+			admin.POST("/emails/templates/:template/preview", emailHandler.Preview)
 		}
 	}
 
+	// Version 2 of the period charts: zero-filled periods, oldest first,
+	// keyed "period" and "total". The /api routes keep their original shape.
+	v2 := r.Group("/api/v2", authenticate, account.RequireActive(db))
+	{
+		v2.GET("/analytics/spending", analyticsHandler.GetSpendingV2)
+		v2.GET("/admin/revenue", audit.Middleware(auditLog, audit.RoleAdmin), adminHandler.GetRevenueV2)
+	}
+
 	// ---- START SERVER ----
 	log.Println("🚀 Server starting on port 8080")
 	r.Run(":8080")
 }
 
+// startOutbox applies the SQL migrations and runs the outbox worker in the
+// background. Postgres only. Each register func adds event handlers beyond
+// the Dispatcher's built-ins.
+func startOutbox(sqlDB *sql.DB, zl *zap.Logger, d *outbox.Dispatcher, register ...func(*outbox.Registry, *outbox.Repo)) *outbox.Repo {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	if err := database.Migrate(sqlDB, migrations); err != nil {
+		panic(err)
+	}
+
+	repo := outbox.NewRepo(sqlDB)
+	worker := outbox.NewWorker(repo, d, zl)
+	for _, fn := range register {
+		fn(worker.Registry, repo)
+	}
+	if n, err := strconv.Atoi(os.Getenv("OUTBOX_CONCURRENCY")); err == nil && n > 0 {
+		worker.Concurrency = n
+	}
+	// SMTP_MAX_PER_SECOND keeps bursts (e.g. a campaign) under the mail
+	// provider's sending limit.
+	if rate, err := strconv.ParseFloat(os.Getenv("SMTP_MAX_PER_SECOND"), 64); err == nil && rate > 0 {
+		worker.Registry.Limit("EMAIL_SEND", rate, int(math.Ceil(rate)))
+	}
+	wake := make(chan struct{}, 1)
+	worker.Wake = wake
+	go outbox.Listen(context.Background(), sqlDB, wake, zl)
+	go func() {
+		if err := worker.Run(context.Background()); err != nil {
+			log.Println("outbox worker stopped:", err)
+		}
+	}()
+
+	retention := outboxRetentionDays()
+	go func() {
+		for ; ; time.Sleep(24 * time.Hour) {
+			n, err := repo.PurgeSent(context.Background(), time.Now().AddDate(0, 0, -retention))
+			if err != nil {
+				log.Println("outbox purge failed:", err)
+			} else if n > 0 {
+				log.Printf("outbox purge: %d events older than %d days removed", n, retention)
+			}
+		}
+	}()
+
+	log.Println("✅ Outbox worker started")
+	return repo
+}
+
+// smtpFromEnv returns nil when SMTP_HOST is not set; email events then fail
+// and are retried until a mail server is configured. EMAIL_FAKE=1 keeps mails
+// in memory instead. SMTP_TLS is starttls, tls (implicit) or none; empty
+// upgrades with STARTTLS when the server offers it.
+func smtpFromEnv() email.Mailer {
+	if os.Getenv("EMAIL_FAKE") == "1" {
+		return email.NewMemory(os.Getenv("SMTP_FROM"))
+	}
+	if os.Getenv("SMTP_HOST") == "" {
+		return nil
+	}
+	return email.NewSMTP(email.SMTPConfig{
+		Host: os.Getenv("SMTP_HOST"),
+		Port: os.Getenv("SMTP_PORT"),
+		User: os.Getenv("SMTP_USER"),
+		Pass: os.Getenv("SMTP_PASS"),
+		From: os.Getenv("SMTP_FROM"),
+		TLS:  os.Getenv("SMTP_TLS"),
+	})
+}
+
+// publicBaseURL is where links in emails point, e.g. https://api.luvy.app.
+func publicBaseURL() string {
+	if u := os.Getenv("PUBLIC_BASE_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:8080"
+}
+
+// legacyUserHeader keeps requests without a session working as before
+// login existed: X-User-ID picks the user, user 1 when it is missing.
+// AUTH_LEGACY_HEADER=0 turns that off once every client logs in.
+func legacyUserHeader() bool {
+	return os.Getenv("AUTH_LEGACY_HEADER") != "0"
+}
+
+// passwordResetURL is the app page reset emails link to; it gets the token
+// as ?token= and posts it with the new password to /api/auth/password/reset.
+func passwordResetURL() string {
+	if u := os.Getenv("PASSWORD_RESET_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:3000/reset-password"
+}
+
+// purgeExports drops expired export files once an hour.
+func purgeExports(repo *exports.Repo) {
+	for ; ; time.Sleep(time.Hour) {
+		n, err := repo.PurgeExpired(context.Background())
+		if err != nil {
+			log.Println("export purge failed:", err)
+		} else if n > 0 {
+			log.Printf("export purge: %d expired files removed", n)
+		}
+	}
+}
+
+// outboxRetentionDays is how long delivered outbox events are kept, for
+// the daily purge and the admin purge endpoint alike.
+func outboxRetentionDays() int {
+	if n, err := strconv.Atoi(os.Getenv("OUTBOX_RETENTION_DAYS")); err == nil && n > 0 {
+		return n
+	}
+	return outbox.DefaultRetentionDays
+}
+
+// insightsInterval is how often stale insight reports are recomputed,
+// INSIGHTS_REFRESH_INTERVAL as a Go duration (default 1h).
+func insightsInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("INSIGHTS_REFRESH_INTERVAL")); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
+// pushProvidersFromEnv configures a provider per platform from whatever
+// credentials are present. PUSH_FAKE=1 swaps in the in-memory provider for
+// local runs. Platforms without a provider fail their sends until configured.
+func pushProvidersFromEnv() map[string]push.Provider {
+	providers := map[string]push.Provider{}
+
+	if os.Getenv("PUSH_FAKE") == "1" {
+		fake := push.NewFake()
+		for _, p := range []string{push.PlatformIOS, push.PlatformAndroid, push.PlatformWeb} {
+			providers[p] = fake
+		}
+		return providers
+	}
+
+	if path := os.Getenv("FCM_SERVICE_ACCOUNT"); path != "" {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		projectID, token, err := push.ServiceAccountToken(key, nil)
+		if err != nil {
+			panic(err)
+		}
+		providers[push.PlatformAndroid] = &push.FCM{ProjectID: projectID, AccessToken: token}
+	}
+
+	if path := os.Getenv("APNS_KEY_FILE"); path != "" {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		apns, err := push.NewAPNs(os.Getenv("APNS_TEAM_ID"), os.Getenv("APNS_KEY_ID"), key,
+			os.Getenv("APNS_TOPIC"), os.Getenv("APNS_PRODUCTION") == "1")
+		if err != nil {
+			panic(err)
+		}
+		providers[push.PlatformIOS] = apns
+	}
+
+	if key := os.Getenv("VAPID_PRIVATE_KEY"); key != "" {
+		wp, err := push.NewWebPush(os.Getenv("VAPID_SUBJECT"), key)
+		if err != nil {
+			panic(err)
+		}
+		providers[push.PlatformWeb] = wp
+	}
+
+	return providers
+}
+
 // Seed merchants
 func seedMerchants(db *gorm.DB) {
 	merchants := []models.Merchant{
@@ -162,3 +578,12 @@ func seedMerchants(db *gorm.DB) {
 
 	log.Println("✅ Merchants seeded successfully")
 }
+
+// Seed achievements
+func seedAchievements(db *gorm.DB) {
+	for _, a := range gamification.DefaultAchievements {
+		db.Where(models.Achievement{Code: a.Code}).FirstOrCreate(&a)
+	}
+
+	log.Println("✅ Achievements seeded successfully")
+}
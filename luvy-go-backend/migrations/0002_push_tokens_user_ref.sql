@@ -0,0 +1,2 @@
+-- users.id is a serial integer in the Go API, not a UUID.
+ALTER TABLE push_tokens ALTER COLUMN user_id TYPE TEXT USING user_id::text;
@@ -0,0 +1,15 @@
+-- Every failure and every admin action on an outbox event, so dead letters
+-- can be inspected and replayed. Statuses gain SKIPPED (opted out) and
+-- CANCELLED (stopped by an admin).
+CREATE TABLE IF NOT EXISTS outbox_event_log (
+  id BIGSERIAL PRIMARY KEY,
+  event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+  attempt INT NOT NULL DEFAULT 0,
+  status TEXT NOT NULL, -- FAILED|DEAD|REQUEUED|CANCELLED
+  error TEXT NULL,
+  actor TEXT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_event_log_event ON outbox_event_log(event_id, id);
+CREATE INDEX IF NOT EXISTS idx_outbox_type_created ON outbox_events(event_type, created_at);
@@ -0,0 +1,6 @@
+-- PROCESSING rows carry a lease. A worker that dies mid-batch leaves its
+-- lease to expire, and the reaper puts the row back in the queue.
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS locked_by TEXT NULL;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_processing_lease ON outbox_events(locked_until) WHERE status = 'PROCESSING';
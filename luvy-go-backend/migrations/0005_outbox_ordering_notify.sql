@@ -0,0 +1,18 @@
+-- Claiming only the oldest open event per aggregate needs a cheap lookup of
+-- older open siblings.
+CREATE INDEX IF NOT EXISTS idx_outbox_open_aggregate ON outbox_events(aggregate_id, created_at)
+  WHERE aggregate_id IS NOT NULL AND status IN ('PENDING', 'PROCESSING', 'FAILED');
+
+-- Wake idle workers as soon as something is enqueued instead of on the
+-- next poll tick.
+CREATE OR REPLACE FUNCTION outbox_events_notify() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('outbox_events', NEW.event_type);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_events_notify ON outbox_events;
+CREATE TRIGGER outbox_events_notify
+  AFTER INSERT ON outbox_events
+  FOR EACH ROW EXECUTE FUNCTION outbox_events_notify();
@@ -0,0 +1,7 @@
+-- Aggregates are gorm rows with serial integer ids (receipt 42, user 7), not
+-- UUIDs. Ordering is per (aggregate_type, aggregate_id), so receipt 7 and
+-- user 7 do not wait on each other.
+DROP INDEX IF EXISTS idx_outbox_open_aggregate;
+ALTER TABLE outbox_events ALTER COLUMN aggregate_id TYPE TEXT USING aggregate_id::text;
+CREATE INDEX IF NOT EXISTS idx_outbox_open_aggregate ON outbox_events(aggregate_type, aggregate_id, created_at)
+  WHERE aggregate_id IS NOT NULL AND status IN ('PENDING', 'PROCESSING', 'FAILED');
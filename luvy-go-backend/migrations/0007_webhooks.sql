@@ -0,0 +1,31 @@
+-- Partner webhooks. secret signs every delivery; after a rotation the
+-- previous secret keeps signing alongside it until previous_expires_at, so
+-- receivers can switch over without dropping events.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  previous_secret TEXT NULL,
+  previous_expires_at TIMESTAMP NULL,
+  event_types JSONB NOT NULL DEFAULT '[]'::jsonb,
+  merchant TEXT NULL, -- only receipts from this merchant; NULL = all
+  description TEXT NOT NULL DEFAULT '',
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- One row per HTTP attempt, including test pings.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+  webhook_event_id TEXT NOT NULL, -- the id receivers see, stable across retries
+  event_type TEXT NOT NULL,
+  attempt INT NOT NULL,
+  status_code INT NULL,
+  error TEXT NULL,
+  duration_ms INT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_sub ON webhook_deliveries(subscription_id, id);
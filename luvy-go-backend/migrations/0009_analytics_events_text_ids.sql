@@ -0,0 +1,6 @@
+-- users.id and merchants.id are serial integers in the Go API, not UUIDs.
+ALTER TABLE analytics_events ALTER COLUMN user_id TYPE TEXT USING user_id::text;
+ALTER TABLE analytics_events ALTER COLUMN merchant_id TYPE TEXT USING merchant_id::text;
+
+-- funnels and retention walk one user's events in time order
+CREATE INDEX IF NOT EXISTS idx_analytics_events_user_name_time ON analytics_events(user_id, event_name, created_at);
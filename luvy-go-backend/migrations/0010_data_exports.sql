@@ -0,0 +1,25 @@
+-- User data exports. A row is requested PENDING, filled in by the
+-- EXPORT_GENERATE outbox event and served by token until expires_at, after
+-- which the file is dropped and the row kept as EXPIRED.
+CREATE TABLE IF NOT EXISTS data_exports (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id BIGINT NOT NULL,
+  kind TEXT NOT NULL,     -- receipts | transactions | statement
+  format TEXT NOT NULL,   -- csv | json | pdf
+  delivery TEXT NOT NULL, -- link | email
+  range_from TIMESTAMPTZ NOT NULL,
+  range_to TIMESTAMPTZ NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING', -- PENDING | READY | FAILED | EXPIRED
+  filename TEXT NULL,
+  content_type TEXT NULL,
+  size_bytes INT NULL,
+  file BYTEA NULL,
+  token TEXT NULL UNIQUE,
+  error TEXT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  completed_at TIMESTAMP NULL,
+  expires_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_expiry ON data_exports(expires_at) WHERE status = 'READY';
@@ -0,0 +1,22 @@
+-- GDPR data subject requests. An access request is fulfilled by a
+-- personal_data export; an erasure request waits SCHEDULED until
+-- scheduled_for (the grace period, during which the user can cancel) and is
+-- then carried out by the GDPR_ERASE outbox event.
+CREATE TABLE IF NOT EXISTS privacy_requests (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id BIGINT NOT NULL,
+  kind TEXT NOT NULL,   -- access | erasure
+  status TEXT NOT NULL, -- PENDING | SCHEDULED | COMPLETED | CANCELLED | FAILED
+  export_id UUID NULL REFERENCES data_exports(id) ON DELETE SET NULL,
+  scheduled_for TIMESTAMP NULL,
+  completed_at TIMESTAMP NULL,
+  cancelled_at TIMESTAMP NULL,
+  summary JSONB NULL, -- what an erasure changed, per table
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_privacy_requests_user ON privacy_requests(user_id, created_at DESC);
+
+-- one open erasure per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_privacy_requests_open_erasure
+  ON privacy_requests(user_id) WHERE kind = 'erasure' AND status = 'SCHEDULED';
@@ -0,0 +1,12 @@
+-- Requests no longer chain audit entries themselves: Record drops them into
+-- audit_pending and the chainer moves them onto the audit_logs chain in
+-- batches, so the chain lock is only ever held in the background.
+CREATE TABLE IF NOT EXISTS audit_pending (
+  id BIGSERIAL PRIMARY KEY,
+  actor_id TEXT NULL,
+  actor_role TEXT NOT NULL,
+  action TEXT NOT NULL,
+  resource TEXT NULL,
+  metadata JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
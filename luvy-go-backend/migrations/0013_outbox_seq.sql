@@ -0,0 +1,9 @@
+-- Events queued in one transaction share created_at (NOW() is the
+-- transaction's start), which left their order to the random UUID. seq
+-- numbers rows as they are inserted and breaks those ties, so a user's
+-- events go out, and are replayed, in the order they were queued.
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+DROP INDEX IF EXISTS idx_outbox_open_aggregate;
+CREATE INDEX IF NOT EXISTS idx_outbox_open_aggregate ON outbox_events(aggregate_type, aggregate_id, created_at, seq)
+  WHERE aggregate_id IS NOT NULL AND status IN ('PENDING', 'PROCESSING', 'FAILED');
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"luvy-go-backend/internal/timebucket"
 	"luvy-go-backend/src/models"
 )
 
@@ -67,36 +69,73 @@ func (h *AdminHandler) GetUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"users": usersWithStats})
 }
 
+// GetRevenue is the original daily chart: the last 30 UTC days that have
+// receipts, newest first, as date ("YYYY-MM-DD") and amount. GetRevenueV2
+// serves other periods and ranges.
 func (h *AdminHandler) GetRevenue(c *gin.Context) {
-	var results []struct {
+	points, err := timebucket.Series(h.DB.Model(&models.Receipt{}), timebucket.Day, "created_at", "amount", time.UTC,
+		timebucket.LastN(timebucket.Day, 30, time.Now().UTC()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revenue"})
+		return
+	}
+
+	type day struct {
 		Date   string  `json:"date"`
 		Amount float64 `json:"amount"`
 	}
-
-	h.DB.Raw(`
-		SELECT DATE(created_at) as date, SUM(amount) as amount
-		FROM receipts
-		GROUP BY date
-		ORDER BY date DESC
-		LIMIT 30
-	`).Scan(&results)
+	results := []day{}
+	for i := len(points) - 1; i >= 0; i-- {
+		if points[i].Count > 0 {
+			results = append(results, day{Date: points[i].Period, Amount: points[i].Total})
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"revenue": results})
 }
 
-func (h *AdminHandler) GetTopMerchants(c *gin.Context) {
-	var results []struct {
-		Merchant string  `json:"merchant"`
-		Total    float64 `json:"total"`
-		Count    int     `json:"count"`
+// GetRevenueV2 sums receipt amounts per period, oldest first, zero-filling
+// quiet periods. It defaults to the last 30 days in UTC and takes the same
+// granularity, from, to and tz parameters as the user spending chart.
+func (h *AdminHandler) GetRevenueV2(c *gin.Context) {
+	q, err := parsePeriodQuery(c, time.UTC, timebucket.Day, 30)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revenue, err := timebucket.Series(h.DB.Model(&models.Receipt{}), q.Granularity, "created_at", "amount", q.Loc, q.Range)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	total, count := timebucket.Sum(revenue)
+
+	c.JSON(http.StatusOK, gin.H{
+		"granularity": q.Granularity,
+		"time_zone":   q.Loc.String(),
+		"from":        q.Range.From,
+		"to":          q.Range.To,
+		"revenue":     revenue,
+		"total":       total,
+		"count":       count,
+	})
+}
 
-	h.DB.Model(&models.Receipt{}).
-		Select("merchant, SUM(amount) as total, COUNT(*) as count").
-		Group("merchant").
-		Order("total DESC").
-		Limit(10).
-		Scan(&results)
+// GetTopMerchants lists the ten biggest merchants across all users, over
+// all time or over the range given as for the revenue chart (UTC unless tz
+// is set).
+func (h *AdminHandler) GetTopMerchants(c *gin.Context) {
+	q, ranged, err := parseRangeQuery(c, time.UTC)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := topMerchants(h.DB.Model(&models.Receipt{}), q.Range, 10)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merchants"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"merchants": results})
+	c.JSON(http.StatusOK, rangeResponse(q, ranged, gin.H{"merchants": results}))
 }
\ No newline at end of file
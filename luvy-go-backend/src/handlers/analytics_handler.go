@@ -1,20 +1,26 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"luvy-go-backend/internal/budgets"
+	"luvy-go-backend/internal/insights"
+	"luvy-go-backend/internal/streaks"
+	"luvy-go-backend/internal/timebucket"
 	"luvy-go-backend/src/models"
 )
 
 type AnalyticsHandler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Insights *insights.Service
 }
 
 func NewAnalyticsHandler(db *gorm.DB) *AnalyticsHandler {
-	return &AnalyticsHandler{DB: db}
+	return &AnalyticsHandler{DB: db, Insights: insights.NewService(db)}
 }
 
 func (h *AnalyticsHandler) GetOverview(c *gin.Context) {
@@ -37,9 +43,11 @@ overview.TotalReceipts = int(receiptCount)
 	h.DB.Model(&models.Receipt{}).Where("user_id = ?", userID).
 		Select("COALESCE(SUM(tokens_earned), 0)").Scan(&overview.TotalEarned)
 
-	startOfMonth := time.Now().AddDate(0, 0, -time.Now().Day()+1)
+	loc := h.userLocation(userID)
+	month := timebucket.LastN(timebucket.Month, 1, time.Now().In(loc))
+	cond, args := timebucket.RangeCond(timebucket.DialectOf(h.DB), "created_at", month)
 	h.DB.Model(&models.Receipt{}).
-		Where("user_id = ? AND created_at >= ?", userID, startOfMonth).
+		Where("user_id = ?", userID).Where(cond, args...).
 		Select("COALESCE(SUM(tokens_earned), 0)").Scan(&overview.ThisMonth)
 
 	var user models.User
@@ -49,61 +57,254 @@ overview.TotalReceipts = int(receiptCount)
 	c.JSON(http.StatusOK, overview)
 }
 
+// GetSpending is the original monthly chart: the user's last six months
+// that have receipts, newest first, as month ("YYYY-MM") and total.
+// GetSpendingV2 serves other periods and ranges.
 func (h *AnalyticsHandler) GetSpending(c *gin.Context) {
 	userID := c.GetUint("userID")
 
-	var results []struct {
+	loc := h.userLocation(userID)
+	points, err := timebucket.Series(h.DB.Model(&models.Receipt{}).Where("user_id = ?", userID),
+		timebucket.Month, "created_at", "amount", loc, timebucket.LastN(timebucket.Month, 6, time.Now().In(loc)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch spending"})
+		return
+	}
+
+	type month struct {
 		Month string  `json:"month"`
 		Total float64 `json:"total"`
 	}
-
-	h.DB.Raw(`
-		SELECT DATE_FORMAT(created_at, '%Y-%m') as month, SUM(amount) as total
-		FROM receipts
-		WHERE user_id = ?
-		GROUP BY month
-		ORDER BY month DESC
-		LIMIT 6
-	`, userID).Scan(&results)
+	results := []month{}
+	for i := len(points) - 1; i >= 0; i-- {
+		if points[i].Count > 0 {
+			results = append(results, month{Month: points[i].Start.Format("2006-01"), Total: points[i].Total})
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"spending": results})
 }
 
+// GetSpendingV2 sums receipt amounts per day, week, month or year in the
+// user's time zone, oldest first, zero-filling empty periods, and compares
+// the range with the one before it. Without from/to it covers the last six
+// periods.
+func (h *AnalyticsHandler) GetSpendingV2(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	q, err := parsePeriodQuery(c, h.userLocation(userID), timebucket.Month, 6)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	receipts := func() *gorm.DB { return h.DB.Model(&models.Receipt{}).Where("user_id = ?", userID) }
+
+	current, err := timebucket.Series(receipts(), q.Granularity, "created_at", "amount", q.Loc, q.Range)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prevRange := q.Range.Previous(q.Granularity)
+	previous, err := timebucket.Series(receipts(), q.Granularity, "created_at", "amount", q.Loc, prevRange)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch spending"})
+		return
+	}
+
+	type period struct {
+		timebucket.Point
+		PreviousTotal *float64 `json:"previous_total,omitempty"`
+	}
+	spending := make([]period, len(current))
+	for i, p := range current {
+		spending[i].Point = p
+		// periods line up one to one when the range is whole periods
+		if len(previous) == len(current) {
+			spending[i].PreviousTotal = &previous[i].Total
+		}
+	}
+
+	total, count := timebucket.Sum(current)
+	prevTotal, prevCount := timebucket.Sum(previous)
+	var change *float64
+	if prevTotal > 0 {
+		pct := (total - prevTotal) / prevTotal * 100
+		change = &pct
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"granularity": q.Granularity,
+		"time_zone":   q.Loc.String(),
+		"from":        q.Range.From,
+		"to":          q.Range.To,
+		"spending":    spending,
+		"total":       total,
+		"count":       count,
+		"previous": gin.H{
+			"from":  prevRange.From,
+			"to":    prevRange.To,
+			"total": prevTotal,
+			"count": prevCount,
+		},
+		"change_pct": change,
+	})
+}
+
+// GetCategories breaks the user's spending down by category, over all time
+// or over the range given as for the spending chart.
 func (h *AnalyticsHandler) GetCategories(c *gin.Context) {
 	userID := c.GetUint("userID")
 
-	var results []struct {
-		Category string  `json:"category"`
-		Total    float64 `json:"total"`
-		Count    int     `json:"count"`
+	q, ranged, err := parseRangeQuery(c, h.userLocation(userID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := budgets.SpendByCategory(h.DB, userID, q.Range)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
 	}
 
-	h.DB.Model(&models.Receipt{}).
-		Select("category, SUM(amount) as total, COUNT(*) as count").
-		Where("user_id = ?", userID).
-		Group("category").
-		Order("total DESC").
-		Scan(&results)
+	c.JSON(http.StatusOK, rangeResponse(q, ranged, gin.H{"categories": results}))
+}
+
+// GetInsights serves the cached insights report; ?refresh=true recomputes it.
+func (h *AnalyticsHandler) GetInsights(c *gin.Context) {
+	userID := c.GetUint("userID")
 
-	c.JSON(http.StatusOK, gin.H{"categories": results})
+	get := h.Insights.Get
+	if c.Query("refresh") == "true" {
+		get = h.Insights.Refresh
+	}
+	report, err := get(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch insights"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
 }
 
+// GetTopMerchants lists the user's five biggest merchants, over all time or
+// over the range given as for the spending chart.
 func (h *AnalyticsHandler) GetTopMerchants(c *gin.Context) {
 	userID := c.GetUint("userID")
 
-	var results []struct {
-		Merchant string  `json:"merchant"`
-		Total    float64 `json:"total"`
-		Count    int     `json:"count"`
+	q, ranged, err := parseRangeQuery(c, h.userLocation(userID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := topMerchants(h.DB.Model(&models.Receipt{}).Where("user_id = ?", userID), q.Range, 5)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merchants"})
+		return
 	}
 
-	h.DB.Model(&models.Receipt{}).
-		Select("merchant, SUM(amount) as total, COUNT(*) as count").
-		Where("user_id = ?", userID).
+	c.JSON(http.StatusOK, rangeResponse(q, ranged, gin.H{"merchants": results}))
+}
+
+type merchantTotal struct {
+	Merchant string  `json:"merchant"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+}
+
+// topMerchants ranks the receipts of q by merchant total. A zero range
+// covers all receipts.
+func topMerchants(q *gorm.DB, r timebucket.Range, limit int) ([]merchantTotal, error) {
+	if !r.From.IsZero() {
+		cond, args := timebucket.RangeCond(timebucket.DialectOf(q), "created_at", r)
+		q = q.Where(cond, args...)
+	}
+	results := []merchantTotal{}
+	err := q.Select("merchant, SUM(amount) as total, COUNT(*) as count").
 		Group("merchant").
 		Order("total DESC").
-		Limit(5).
-		Scan(&results)
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
 
-	c.JSON(http.StatusOK, gin.H{"merchants": results})
-}
\ No newline at end of file
+// userLocation is the user's time zone, or the default one.
+func (h *AnalyticsHandler) userLocation(userID uint) *time.Location {
+	var user models.User
+	h.DB.Select("time_zone").Where("id = ?", userID).Limit(1).Find(&user)
+	return streaks.Location(user.TimeZone)
+}
+
+type periodQuery struct {
+	Granularity timebucket.Granularity
+	Range       timebucket.Range
+	Loc         *time.Location
+}
+
+// parsePeriodQuery reads granularity, from, to and tz (overriding loc).
+// Dates are midnight in that zone and to is inclusive when it is a date;
+// without from/to the range is the last n periods.
+func parsePeriodQuery(c *gin.Context, loc *time.Location, def timebucket.Granularity, n int) (periodQuery, error) {
+	q := periodQuery{Granularity: def, Loc: loc}
+	if tz := c.Query("tz"); tz != "" {
+		if !streaks.ValidTimeZone(tz) {
+			return q, errors.New("unknown time zone")
+		}
+		q.Loc, _ = time.LoadLocation(tz)
+	}
+	if g := c.Query("granularity"); g != "" {
+		var err error
+		if q.Granularity, err = timebucket.ParseGranularity(g); err != nil {
+			return q, err
+		}
+	}
+
+	q.Range = timebucket.LastN(q.Granularity, n, time.Now().In(q.Loc))
+	if s := c.Query("from"); s != "" {
+		t, _, err := parseLocalTime(s, q.Loc)
+		if err != nil {
+			return q, errors.New("from must be YYYY-MM-DD or RFC 3339")
+		}
+		q.Range.From = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, dateOnly, err := parseLocalTime(s, q.Loc)
+		if err != nil {
+			return q, errors.New("to must be YYYY-MM-DD or RFC 3339")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		q.Range.To = t
+	}
+	return q, q.Range.Validate()
+}
+
+// parseRangeQuery reads the parameters of parsePeriodQuery for a total over
+// one range: granularity alone is the current period, from and to bound it
+// and tz sets the zone. With none of granularity, from and to it reports
+// false and the range is zero, meaning all time.
+func parseRangeQuery(c *gin.Context, loc *time.Location) (periodQuery, bool, error) {
+	if c.Query("granularity") == "" && c.Query("from") == "" && c.Query("to") == "" {
+		return periodQuery{Loc: loc}, false, nil
+	}
+	q, err := parsePeriodQuery(c, loc, timebucket.Month, 1)
+	return q, true, err
+}
+
+// rangeResponse adds the range a total covers to body, when it has one.
+func rangeResponse(q periodQuery, ranged bool, body gin.H) gin.H {
+	if ranged {
+		body["time_zone"] = q.Loc.String()
+		body["from"] = q.Range.From
+		body["to"] = q.Range.To
+	}
+	return body
+}
+
+func parseLocalTime(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), false, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	return t, true, err
+}
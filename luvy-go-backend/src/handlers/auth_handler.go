@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/account"
+	"luvy-go-backend/internal/referrals"
+	"luvy-go-backend/src/models"
+)
+
+type AuthHandler struct {
+	DB        *gorm.DB
+	Referrals *referrals.Service
+	Account   *account.Service
+}
+
+func NewAuthHandler(db *gorm.DB) *AuthHandler {
+	return &AuthHandler{DB: db, Referrals: referrals.NewService(db), Account: account.NewService(db)}
+}
+
+func (h *AuthHandler) Register(c *gin.Context) {
+	var input struct {
+		Name         string `json:"name" binding:"required"`
+		Email        string `json:"email" binding:"required,email"`
+		Phone        string `json:"phone"`
+		Password     string `json:"password" binding:"required,min=8"`
+		ReferralCode string `json:"referral_code"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := account.NormalizeEmail(input.Email)
+
+	var existing models.User
+	if err := h.DB.Where("LOWER(email) = ?", email).First(&existing).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already in use"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), 10)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	user := models.User{
+		Name:           input.Name,
+		Email:          email,
+		Phone:          input.Phone,
+		Password:       string(hash),
+		SignupDeviceID: c.GetHeader("X-Device-ID"),
+	}
+
+	var referral *models.Referral
+	var referralErr error
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := h.Account.SendVerification(tx, user, account.PurposeSignup, user.Email); err != nil {
+			return err
+		}
+		if input.ReferralCode == "" {
+			return nil
+		}
+
+		// A bad code must not block signup; redeem in a savepoint and report it.
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			ref, err := h.Referrals.Redeem(tx, user, input.ReferralCode)
+			if err != nil {
+				return err
+			}
+			referral = &ref
+			return nil
+		})
+		if isReferralRejection(err) {
+			referralErr = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	resp := gin.H{
+		"message": "User registered successfully; confirm your email address with the link we sent",
+		"user":    user,
+	}
+	if referral != nil {
+		resp["referral"] = referral
+	}
+	if referralErr != nil {
+		resp["referral_error"] = referralErr.Error()
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Login answers with a session token for the Authorization header
+// ("Bearer <token>").
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.Account.Login(input.Email, input.Password)
+	switch {
+	case errors.Is(err, account.ErrBadCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	case errors.Is(err, account.ErrDeactivated):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated; reactivate it to log in"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+	default:
+		c.JSON(http.StatusOK, sessionResponse(token, user))
+	}
+}
+
+// Logout ends the session the request carries.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	token := account.SessionToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+	if err := h.Account.Logout(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+func sessionResponse(token string, user models.User) gin.H {
+	return gin.H{
+		"token":      token,
+		"expires_in": int(account.SessionTTL.Seconds()),
+		"user":       user,
+	}
+}
+
+var verifyEmailPage = template.Must(template.New("verify").Parse(`<!DOCTYPE html>
+<html><head><meta charset="UTF-8"><title>LUVY</title></head>
+<body style="font-family:Helvetica,Arial,sans-serif;text-align:center;padding:48px;">
+{{if .Done}}<p>E-posta adresiniz doğrulandı. / E-Mail-Adresse bestätigt. / Your email address is verified.</p>
+{{else if .Failed}}<p>Bağlantı geçersiz veya süresi dolmuş. / Der Link ist ungültig oder abgelaufen. / This link is invalid or has expired.</p>
+{{else}}<form method="POST"><input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">E-postamı doğrula / E-Mail bestätigen / Verify my email</button></form>
+{{end}}</body></html>`))
+
+// VerifyEmail redeems the link from a verification email. GET only renders
+// a confirmation button, since mail scanners follow links and would use up
+// the token; POST redeems it, from that button (form) or from the app
+// ({"token": ...}), and answers in kind.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	render := func(status int, data gin.H) {
+		c.Status(status)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		_ = verifyEmailPage.Execute(c.Writer, data)
+	}
+
+	if c.Request.Method == http.MethodGet {
+		render(http.StatusOK, gin.H{"Token": c.Query("token")})
+		return
+	}
+
+	var token string
+	page := c.ContentType() != "application/json"
+	if page {
+		token = c.PostForm("token")
+	} else {
+		var input struct {
+			Token string `json:"token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		token = input.Token
+	}
+
+	user, err := h.Account.VerifyEmail(c.Request.Context(), token)
+	if page {
+		switch {
+		case errors.Is(err, account.ErrInvalidToken), errors.Is(err, account.ErrNotFound), errors.Is(err, account.ErrEmailTaken):
+			render(http.StatusBadRequest, gin.H{"Failed": true})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		default:
+			render(http.StatusOK, gin.H{"Done": true})
+		}
+		return
+	}
+	switch {
+	case errors.Is(err, account.ErrInvalidToken), errors.Is(err, account.ErrNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+	case errors.Is(err, account.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified", "user": user})
+	}
+}
+
+// ForgotPassword answers the same whether or not the address has an
+// account.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Account.RequestPasswordReset(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address has an account, a reset link is on its way"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.Account.ResetPassword(c.Request.Context(), input.Token, input.Password)
+	switch {
+	case errors.Is(err, account.ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+	}
+}
+
+func isReferralRejection(err error) bool {
+	return errors.Is(err, referrals.ErrCodeNotFound) ||
+		errors.Is(err, referrals.ErrSelfReferral) ||
+		errors.Is(err, referrals.ErrAlreadyReferred) ||
+		errors.Is(err, referrals.ErrSuspicious)
+}
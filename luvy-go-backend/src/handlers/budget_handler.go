@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/budgets"
+)
+
+type BudgetHandler struct {
+	Service *budgets.Service
+}
+
+func NewBudgetHandler(db *gorm.DB) *BudgetHandler {
+	return &BudgetHandler{Service: budgets.NewService(db)}
+}
+
+type budgetInput struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+	Rollover string  `json:"rollover"` // none | unspent | all
+}
+
+// List returns the user's budgets with this month's progress.
+func (h *BudgetHandler) List(c *gin.Context) {
+	summary, err := h.Service.Summary(c.GetUint("userID"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budgets"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"month": summary.Month, "budgets": summary.Budgets})
+}
+
+// Summary is the dashboard card: totals, per-budget progress, spending
+// outside any budget and the alerts sent. ?month=YYYY-MM picks another month.
+func (h *BudgetHandler) Summary(c *gin.Context) {
+	at := time.Now()
+	if m := c.Query("month"); m != "" {
+		t, err := time.Parse("2006-01", m)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month must be YYYY-MM"})
+			return
+		}
+		// mid-month, so the user's time zone cannot shift it
+		at = t.AddDate(0, 0, 14)
+	}
+
+	summary, err := h.Service.Summary(c.GetUint("userID"), at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budget summary"})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// Set creates the budget for a category or replaces the existing one.
+func (h *BudgetHandler) Set(c *gin.Context) {
+	var input budgetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget, err := h.Service.Set(c.GetUint("userID"), input.Category, input.Amount, input.Rollover)
+	if err != nil {
+		budgetError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"budget": budget})
+}
+
+func (h *BudgetHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		return
+	}
+	var input budgetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget, err := h.Service.Update(c.GetUint("userID"), uint(id), input.Amount, input.Rollover)
+	if err != nil {
+		budgetError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"budget": budget})
+}
+
+func (h *BudgetHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		return
+	}
+	if err := h.Service.Delete(c.GetUint("userID"), uint(id)); err != nil {
+		budgetError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Budget deleted"})
+}
+
+func budgetError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, budgets.ErrInvalidBudget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category, a positive amount and a rollover of none, unspent or all are required"})
+	case errors.Is(err, budgets.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save budget"})
+	}
+}
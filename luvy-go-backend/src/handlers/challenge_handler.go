@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/challenges"
+	"luvy-go-backend/src/models"
+)
+
+type ChallengeHandler struct {
+	DB      *gorm.DB
+	Service *challenges.Service
+}
+
+func NewChallengeHandler(db *gorm.DB) *ChallengeHandler {
+	return &ChallengeHandler{DB: db, Service: challenges.NewService(db)}
+}
+
+func (h *ChallengeHandler) GetProgress(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	progress, err := h.Service.ForUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenges": progress})
+}
+
+func (h *ChallengeHandler) CreateChallenge(c *gin.Context) {
+	var input struct {
+		Code        string    `json:"code" binding:"required"`
+		Name        string    `json:"name" binding:"required"`
+		Description string    `json:"description"`
+		GoalType    string    `json:"goal_type" binding:"required"`
+		GoalValue   float64   `json:"goal_value" binding:"required,gt=0"`
+		Category    string    `json:"category"`
+		Merchant    string    `json:"merchant"`
+		StartsAt    time.Time `json:"starts_at" binding:"required"`
+		EndsAt      time.Time `json:"ends_at" binding:"required"`
+		RewardLuvy  float64   `json:"reward_luvy"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge := models.Challenge{
+		Code:        input.Code,
+		Name:        input.Name,
+		Description: input.Description,
+		GoalType:    input.GoalType,
+		GoalValue:   input.GoalValue,
+		Category:    input.Category,
+		Merchant:    input.Merchant,
+		StartsAt:    input.StartsAt,
+		EndsAt:      input.EndsAt,
+		RewardLuvy:  input.RewardLuvy,
+		IsActive:    true,
+	}
+
+	if err := h.Service.Create(&challenge); err != nil {
+		if err == challenges.ErrInvalidChallenge {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid challenge definition"})
+			return
+		}
+		if err == challenges.ErrDuplicateCode {
+			c.JSON(http.StatusConflict, gin.H{"error": "A challenge with this code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"challenge": challenge})
+}
+
+func (h *ChallengeHandler) ListChallenges(c *gin.Context) {
+	var list []models.Challenge
+	if err := h.DB.Order("starts_at DESC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenges": list})
+}
+
+func (h *ChallengeHandler) DeactivateChallenge(c *gin.Context) {
+	res := h.DB.Model(&models.Challenge{}).Where("id = ?", c.Param("id")).Update("is_active", false)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate challenge"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Challenge deactivated"})
+}
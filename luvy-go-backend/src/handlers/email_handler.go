@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/notifications/email"
+)
+
+// EmailHandler lets admins inspect the email templates without sending.
+type EmailHandler struct{}
+
+func NewEmailHandler() *EmailHandler {
+	return &EmailHandler{}
+}
+
+func (h *EmailHandler) ListTemplates(c *gin.Context) {
+	specs := email.Templates()
+
+	names := make([]string, 0, len(specs))
+	for t := range specs {
+		names = append(names, string(t))
+	}
+	sort.Strings(names)
+
+	templates := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		spec := specs[email.Template(name)]
+		templates = append(templates, gin.H{
+			"template": name,
+			"required": spec.Required,
+			"sample":   spec.Sample,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates, "locales": email.Locales})
+}
+
+// Preview renders a template. GET uses the sample data; POST takes
+// {"data": {...}} so admins can check real payloads. ?format=html or text
+// returns the bare part, anything else the full JSON.
+func (h *EmailHandler) Preview(c *gin.Context) {
+	t := email.Template(c.Param("template"))
+	spec, ok := email.Templates()[t]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	data := spec.Sample
+	if c.Request.Method == http.MethodPost {
+		var input struct {
+			Data map[string]string `json:"data"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		data = input.Data
+	}
+
+	locale := c.DefaultQuery("locale", email.DefaultLocale)
+	msg, err := email.Render(t, locale, data)
+	var missing *email.MissingDataError
+	if errors.As(err, &missing) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "missing": missing.Keys})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render template"})
+		return
+	}
+
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.String(http.StatusOK, msg.Text)
+	default:
+		c.JSON(http.StatusOK, gin.H{"template": t, "locale": email.NormalizeLocale(locale), "email": msg})
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/analytics"
+)
+
+// EventsHandler ingests product analytics events and answers funnel and
+// retention queries over them. analytics_events is a SQL table, so both
+// fields are nil in SQLite mode and the endpoints answer 503.
+type EventsHandler struct {
+	Buffer *analytics.Buffer
+	Store  *analytics.Store
+}
+
+func NewEventsHandler(b *analytics.Buffer, s *analytics.Store) *EventsHandler {
+	return &EventsHandler{Buffer: b, Store: s}
+}
+
+// MaxEventBatch caps how many events one ingestion request may carry.
+const MaxEventBatch = 100
+
+// Client timestamps outside this range are replaced by the server time.
+const (
+	maxEventAge  = 7 * 24 * time.Hour
+	maxEventSkew = 5 * time.Minute
+)
+
+func (h *EventsHandler) available(c *gin.Context) bool {
+	if h.Store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Analytics events are not available"})
+		return false
+	}
+	return true
+}
+
+// Ingest accepts a batch of client events. Valid events are queued even if
+// others in the batch are rejected; rejections are reported by index.
+func (h *EventsHandler) Ingest(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	userID := c.GetUint("userID")
+
+	var input struct {
+		Events []struct {
+			Name       string         `json:"name" binding:"required"`
+			MerchantID uint           `json:"merchant_id"`
+			Properties map[string]any `json:"properties"`
+			Timestamp  *time.Time     `json:"timestamp"`
+		} `json:"events" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Events) > MaxEventBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At most " + strconv.Itoa(MaxEventBatch) + " events per batch"})
+		return
+	}
+
+	now := time.Now()
+	type rejection struct {
+		Index int    `json:"index"`
+		Error string `json:"error"`
+	}
+	rejected := []rejection{}
+	accepted := 0
+	for i, in := range input.Events {
+		e := analytics.Event{
+			UserID:     strconv.FormatUint(uint64(userID), 10),
+			Name:       in.Name,
+			Properties: in.Properties,
+			At:         now,
+		}
+		if in.MerchantID != 0 {
+			e.MerchantID = strconv.FormatUint(uint64(in.MerchantID), 10)
+		}
+		if ts := in.Timestamp; ts != nil && ts.After(now.Add(-maxEventAge)) && ts.Before(now.Add(maxEventSkew)) {
+			e.At = *ts
+		}
+
+		if err := analytics.Validate(e, true); err != nil {
+			rejected = append(rejected, rejection{Index: i, Error: err.Error()})
+			continue
+		}
+		if !h.Buffer.Track(e) {
+			rejected = append(rejected, rejection{Index: i, Error: "buffer full, event dropped"})
+			continue
+		}
+		accepted++
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"accepted": accepted, "rejected": rejected})
+}
+
+// Schemas lists the event names and their properties.
+func (h *EventsHandler) Schemas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"events": analytics.Schemas()})
+}
+
+// analyticsRange reads from/to, defaulting to the last 30 days.
+func analyticsRange(c *gin.Context) (time.Time, time.Time, error) {
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		return from, from, err
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		return from, to, err
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+	return from, to, nil
+}
+
+func (h *EventsHandler) queryError(c *gin.Context, err error) {
+	if errors.Is(err, analytics.ErrInvalidQuery) || errors.Is(err, analytics.ErrUnknownEvent) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run analytics query"})
+}
+
+// Funnel handles GET /admin/analytics/funnel?steps=a,b,c&window=24h.
+func (h *EventsHandler) Funnel(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	from, to, err := analyticsRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from/to must be YYYY-MM-DD or RFC 3339"})
+		return
+	}
+	window := 24 * time.Hour
+	if w := c.Query("window"); w != "" {
+		if window, err = time.ParseDuration(w); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be a duration like 24h"})
+			return
+		}
+	}
+	var steps []string
+	for _, s := range strings.Split(c.Query("steps"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			steps = append(steps, s)
+		}
+	}
+
+	funnel, err := h.Store.Funnel(c.Request.Context(), steps, from, to, window)
+	if err != nil {
+		h.queryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"steps": funnel, "from": from, "to": to, "window": window.String()})
+}
+
+// Retention handles GET /admin/analytics/retention?cohort_event=&return_event=&period=week&periods=8.
+func (h *EventsHandler) Retention(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	from, to, err := analyticsRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from/to must be YYYY-MM-DD or RFC 3339"})
+		return
+	}
+	cohortEvent := c.DefaultQuery("cohort_event", analytics.EventSignupCompleted)
+	returnEvent := c.DefaultQuery("return_event", analytics.EventAppOpened)
+	period := c.DefaultQuery("period", "week")
+	periods, _ := strconv.Atoi(c.DefaultQuery("periods", "8"))
+
+	cohorts, err := h.Store.Retention(c.Request.Context(), cohortEvent, returnEvent, period, periods, from, to)
+	if err != nil {
+		h.queryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cohorts": cohorts, "cohort_event": cohortEvent, "return_event": returnEvent, "period": period})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/exports"
+	"luvy-go-backend/internal/outbox"
+)
+
+// ExportHandler lets users export their data. Exports are generated by the
+// outbox worker, so both fields are nil in SQLite mode and the endpoints
+// answer 503.
+type ExportHandler struct {
+	Repo   *exports.Repo
+	Outbox *outbox.Repo
+}
+
+func NewExportHandler(repo *exports.Repo, ob *outbox.Repo) *ExportHandler {
+	return &ExportHandler{Repo: repo, Outbox: ob}
+}
+
+func (h *ExportHandler) available(c *gin.Context) bool {
+	if h.Repo == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Exports are not available"})
+		return false
+	}
+	return true
+}
+
+// Create queues an export and answers 202; the user is notified when it
+// is ready.
+func (h *ExportHandler) Create(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	var req exports.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	e, err := h.Repo.Create(c.Request.Context(), h.Outbox, c.GetUint("userID"), req)
+	if h.respond(c, err, "Failed to create export") {
+		c.JSON(http.StatusAccepted, gin.H{"export": e})
+	}
+}
+
+func (h *ExportHandler) List(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	list, err := h.Repo.List(c.Request.Context(), c.GetUint("userID"))
+	if h.respond(c, err, "Failed to fetch exports") {
+		c.JSON(http.StatusOK, gin.H{"exports": list})
+	}
+}
+
+func (h *ExportHandler) Get(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	e, err := h.Repo.Get(c.Request.Context(), c.GetUint("userID"), c.Param("id"))
+	if h.respond(c, err, "Failed to fetch export") {
+		c.JSON(http.StatusOK, gin.H{"export": e})
+	}
+}
+
+// Download serves the user's own export.
+func (h *ExportHandler) Download(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	h.serve(c, c.GetUint("userID"), c.Param("id"))
+}
+
+// DownloadByToken serves the link from the ready email; the token is the
+// credential.
+func (h *ExportHandler) DownloadByToken(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	h.serve(c, 0, c.Param("token"))
+}
+
+func (h *ExportHandler) serve(c *gin.Context, userID uint, key string) {
+	e, data, err := h.Repo.File(c.Request.Context(), userID, key)
+	if !h.respond(c, err, "Failed to fetch export") {
+		return
+	}
+	name := ""
+	if e.Filename != nil {
+		name = *e.Filename
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, *e.ContentType, data)
+}
+
+// respond maps repository errors; it reports whether err was nil.
+func (h *ExportHandler) respond(c *gin.Context, err error, failure string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, exports.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+	case errors.Is(err, exports.ErrNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is not ready or has expired"})
+	case errors.Is(err, exports.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+	}
+	return false
+}
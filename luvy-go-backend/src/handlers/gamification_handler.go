@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/gamification"
+)
+
+type GamificationHandler struct {
+	DB      *gorm.DB
+	Service *gamification.Service
+}
+
+func NewGamificationHandler(db *gorm.DB) *GamificationHandler {
+	return &GamificationHandler{DB: db, Service: gamification.NewService(db)}
+}
+
+func (h *GamificationHandler) GetLevel(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	lvl, err := h.Service.Level(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch level"})
+		return
+	}
+
+	resp := gin.H{
+		"level":              lvl.Level,
+		"current_xp":         lvl.CurrentXP,
+		"total_luvy_earned":  lvl.TotalLuvyEarned,
+		"receipts_submitted": lvl.ReceiptsSubmitted,
+		"consecutive_days":   lvl.ConsecutiveDays,
+		"next_level":         nil,
+		"luvy_to_next_level": 0,
+	}
+	if next, missing, ok := gamification.NextLevel(lvl.TotalLuvyEarned); ok {
+		resp["next_level"] = next
+		resp["luvy_to_next_level"] = missing
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *GamificationHandler) GetAchievements(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	achievements, err := h.Service.Achievements(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"achievements": achievements})
+}
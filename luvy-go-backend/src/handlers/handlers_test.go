@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"luvy-go-backend/internal/gamification"
+	"luvy-go-backend/internal/pgtest"
+	"luvy-go-backend/src/models"
+)
+
+// pgDB is a Postgres schema with every gorm model migrated, as main does,
+// and the achievement catalog seeded. Unlike SQLite it enforces the foreign
+// keys gorm derives from the models.
+func pgDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := pgtest.Gorm(t, pgtest.Open(t,
+		&models.User{}, &models.Receipt{}, &models.Transaction{}, &models.Merchant{},
+		&models.UserLevel{}, &models.Achievement{}, &models.UserAchievement{},
+		&models.Challenge{}, &models.UserChallenge{}, &models.ReferralCode{}, &models.Referral{},
+		&models.LeaderboardEntry{}, &models.UserStreak{}, &models.NotificationPreference{},
+		&models.Notification{}, &models.Budget{}, &models.BudgetAlert{}, &models.UserInsight{},
+		&models.AccountToken{},
+	))
+	for _, a := range gamification.DefaultAchievements {
+		if err := db.Create(&a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// serve runs h as userID, the way the auth middleware leaves the context.
+func serve(h gin.HandlerFunc, userID uint, method, path, route string, body any) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) {
+		c.Set("userID", userID)
+		h(c)
+	})
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/inbox"
+)
+
+type InboxHandler struct {
+	DB      *gorm.DB
+	Service *inbox.Service
+}
+
+func NewInboxHandler(db *gorm.DB) *InboxHandler {
+	return &InboxHandler{DB: db, Service: inbox.NewService(db)}
+}
+
+func (h *InboxHandler) List(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	before, _ := strconv.ParseUint(c.Query("before"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.Service.List(userID, inbox.Query{
+		Before:     uint(before),
+		Limit:      limit,
+		UnreadOnly: c.Query("unread") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *InboxHandler) UnreadCount(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	n, err := h.Service.UnreadCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": n})
+}
+
+func (h *InboxHandler) MarkRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	err = h.Service.MarkRead(userID, uint(id))
+	if err == inbox.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+func (h *InboxHandler) MarkAllRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	n, err := h.Service.MarkAllRead(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": n})
+}
+
+func (h *InboxHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	err = h.Service.Delete(userID, uint(id))
+	if err == inbox.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted"})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/leaderboard"
+)
+
+type LeaderboardHandler struct {
+	DB      *gorm.DB
+	Service *leaderboard.Service
+}
+
+func NewLeaderboardHandler(db *gorm.DB) *LeaderboardHandler {
+	return &LeaderboardHandler{DB: db, Service: leaderboard.NewService(db)}
+}
+
+func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	board, err := h.Service.Board(leaderboard.Query{
+		Period:   c.DefaultQuery("period", leaderboard.PeriodWeekly),
+		Metric:   c.DefaultQuery("metric", leaderboard.MetricLuvy),
+		Category: c.Query("category"),
+		City:     c.Query("city"),
+		Limit:    limit,
+	}, userID)
+	if err == leaderboard.ErrInvalidQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be weekly, monthly or all_time and metric luvy or receipts"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, board)
+}
+
+func (h *LeaderboardHandler) UpdatePrivacy(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var input struct {
+		OptOut *bool `json:"opt_out" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Service.SetOptOut(userID, *input.OptOut); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leaderboard privacy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"opt_out": *input.OptOut})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/notifications/prefs"
+)
+
+type NotificationHandler struct {
+	DB    *gorm.DB
+	Prefs *prefs.Service
+	Links *prefs.Links
+}
+
+func NewNotificationHandler(db *gorm.DB, links *prefs.Links) *NotificationHandler {
+	return &NotificationHandler{DB: db, Prefs: prefs.NewService(db), Links: links}
+}
+
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	settings, err := h.Prefs.Get(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var input struct {
+		Preferences []prefs.Change    `json:"preferences"`
+		QuietHours  *prefs.QuietHours `json:"quiet_hours"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.Prefs.Update(userID, input.Preferences, input.QuietHours)
+	if errors.Is(err, prefs.ErrInvalid) || errors.Is(err, prefs.ErrLocked) || errors.Is(err, prefs.ErrInvalidQuiet) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	h.GetPreferences(c)
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="UTF-8"><title>LUVY</title></head>
+<body style="font-family:Helvetica,Arial,sans-serif;text-align:center;padding:48px;">
+{{if .Done}}<p>{{.Category}} e-postalarından çıkış yapıldı. / Abgemeldet. / You have been unsubscribed.</p>
+{{else}}<form method="POST"><input type="hidden" name="token" value="{{.Token}}">
+<p>{{.Category}}</p>
+<button type="submit">Abonelikten çık / Abmelden / Unsubscribe</button></form>
+{{end}}</body></html>`))
+
+// Unsubscribe serves the signed link from emails. GET only renders a
+// confirmation button, since mail scanners follow links; POST (the button,
+// or a client's RFC 8058 one-click request) applies it.
+func (h *NotificationHandler) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+	userID, channel, category, err := h.Links.Verify(token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	done := c.Request.Method == http.MethodPost
+	if done {
+		if err := h.Prefs.Unsubscribe(userID, channel, category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = unsubscribePage.Execute(c.Writer, gin.H{"Done": done, "Token": token, "Category": category})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/outbox"
+)
+
+// OutboxHandler is the dead-letter console. Repo is nil in SQLite mode, where
+// there is no outbox, and every endpoint answers 503.
+type OutboxHandler struct {
+	Repo *outbox.Repo
+	// RetentionDays is the default age for Purge, the same the daily purge
+	// uses; zero means outbox.DefaultRetentionDays.
+	RetentionDays int
+}
+
+func NewOutboxHandler(repo *outbox.Repo) *OutboxHandler {
+	return &OutboxHandler{Repo: repo}
+}
+
+func (h *OutboxHandler) available(c *gin.Context) bool {
+	if h.Repo == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Outbox is not available"})
+		return false
+	}
+	return true
+}
+
+type outboxFilterInput struct {
+	Status    string `json:"status" form:"status"` // comma separated
+	EventType string `json:"event_type" form:"event_type"`
+	From      string `json:"from" form:"from"` // YYYY-MM-DD or RFC 3339
+	To        string `json:"to" form:"to"`
+}
+
+func (in outboxFilterInput) filter() (outbox.Filter, error) {
+	var f outbox.Filter
+	if in.Status != "" {
+		for _, s := range strings.Split(in.Status, ",") {
+			f.Statuses = append(f.Statuses, strings.ToUpper(strings.TrimSpace(s)))
+		}
+	}
+	f.EventType = in.EventType
+
+	var err error
+	if f.From, err = parseTimeParam(in.From); err != nil {
+		return f, err
+	}
+	if f.To, err = parseTimeParam(in.To); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func parseTimeParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func (h *OutboxHandler) List(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	var input outboxFilterInput
+	_ = c.ShouldBindQuery(&input)
+	f, err := input.filter()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from/to must be YYYY-MM-DD or RFC 3339"})
+		return
+	}
+	f.Limit, _ = strconv.Atoi(c.Query("limit"))
+	f.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	events, total, err := h.Repo.List(c.Request.Context(), f)
+	if errors.Is(err, outbox.ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown status"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outbox events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events, "total": total})
+}
+
+func (h *OutboxHandler) Get(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	ev, err := h.Repo.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, outbox.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Outbox event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outbox event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ev)
+}
+
+type outboxSelection struct {
+	IDs    []string          `json:"ids"`
+	Filter outboxFilterInput `json:"filter"`
+}
+
+// Requeue handles POST /outbox/requeue (bulk) and /outbox/:id/requeue.
+func (h *OutboxHandler) Requeue(c *gin.Context) {
+	h.bulk(c, (*outbox.Repo).Requeue, "requeued")
+}
+
+// Cancel handles POST /outbox/cancel (bulk) and /outbox/:id/cancel.
+func (h *OutboxHandler) Cancel(c *gin.Context) {
+	h.bulk(c, (*outbox.Repo).Cancel, "cancelled")
+}
+
+func (h *OutboxHandler) bulk(c *gin.Context, op func(*outbox.Repo, context.Context, []string, outbox.Filter, string) (int64, error), key string) {
+	if !h.available(c) {
+		return
+	}
+
+	var sel outboxSelection
+	if id := c.Param("id"); id != "" {
+		sel.IDs = []string{id}
+	} else if err := c.ShouldBindJSON(&sel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	f, err := sel.Filter.filter()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from/to must be YYYY-MM-DD or RFC 3339"})
+		return
+	}
+
+	actor := "admin:" + strconv.FormatUint(uint64(c.GetUint("userID")), 10)
+	n, err := op(h.Repo, c.Request.Context(), sel.IDs, f, actor)
+	if errors.Is(err, outbox.ErrEmptySelector) || errors.Is(err, outbox.ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update outbox events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{key: n})
+}
+
+// Purge deletes delivered events older than older_than_days (default: the
+// configured retention).
+func (h *OutboxHandler) Purge(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	var input struct {
+		OlderThanDays int `json:"older_than_days"`
+	}
+	_ = c.ShouldBindJSON(&input)
+	if input.OlderThanDays <= 0 {
+		input.OlderThanDays = h.RetentionDays
+	}
+	if input.OlderThanDays <= 0 {
+		input.OlderThanDays = outbox.DefaultRetentionDays
+	}
+
+	n, err := h.Repo.PurgeSent(c.Request.Context(), time.Now().AddDate(0, 0, -input.OlderThanDays))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge outbox events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": n})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/gdpr"
+)
+
+// PrivacyHandler serves GDPR access and erasure requests. Both run through
+// the outbox, so Service is nil in SQLite mode and the endpoints answer 503.
+type PrivacyHandler struct {
+	Service *gdpr.Service
+}
+
+func NewPrivacyHandler(s *gdpr.Service) *PrivacyHandler {
+	return &PrivacyHandler{Service: s}
+}
+
+func (h *PrivacyHandler) available(c *gin.Context) bool {
+	if h.Service == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Privacy requests are not available"})
+		return false
+	}
+	return true
+}
+
+func (h *PrivacyHandler) List(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	list, err := h.Service.List(c.Request.Context(), c.GetUint("userID"), 50)
+	if h.respond(c, err, "Failed to fetch privacy requests") {
+		c.JSON(http.StatusOK, gin.H{"requests": list})
+	}
+}
+
+// RequestAccess starts the export of everything stored about the user; the
+// download link is emailed when it is ready.
+func (h *PrivacyHandler) RequestAccess(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	req, err := h.Service.RequestAccess(c.Request.Context(), c.GetUint("userID"))
+	if h.respond(c, err, "Failed to request data access") {
+		c.JSON(http.StatusAccepted, gin.H{"request": req})
+	}
+}
+
+// RequestErasure schedules the account's anonymisation after the grace
+// period, during which it can be cancelled.
+func (h *PrivacyHandler) RequestErasure(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	req, err := h.Service.RequestErasure(c.Request.Context(), c.GetUint("userID"))
+	if h.respond(c, err, "Failed to request erasure") {
+		c.JSON(http.StatusAccepted, gin.H{"request": req})
+	}
+}
+
+func (h *PrivacyHandler) CancelErasure(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	req, err := h.Service.CancelErasure(c.Request.Context(), c.GetUint("userID"))
+	if h.respond(c, err, "Failed to cancel erasure") {
+		c.JSON(http.StatusOK, gin.H{"request": req})
+	}
+}
+
+// AdminList returns every user's requests, or one user's with ?user_id=.
+func (h *PrivacyHandler) AdminList(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	list, err := h.Service.List(c.Request.Context(), uint(userID), limit)
+	if h.respond(c, err, "Failed to fetch privacy requests") {
+		c.JSON(http.StatusOK, gin.H{"requests": list})
+	}
+}
+
+// respond maps service errors; it reports whether err was nil.
+func (h *PrivacyHandler) respond(c *gin.Context, err error, failure string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, gdpr.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No scheduled erasure"})
+	case errors.Is(err, gdpr.ErrAlreadyPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "An erasure is already scheduled"})
+	case errors.Is(err, gdpr.ErrUnknownUser):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+	}
+	return false
+}
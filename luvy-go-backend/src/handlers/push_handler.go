@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/notifications/push"
+	"luvy-go-backend/internal/outbox"
+)
+
+// PushHandler manages device tokens. Tokens live in the SQL push_tokens
+// table, so both fields are nil in SQLite mode and the endpoints answer 503.
+type PushHandler struct {
+	Tokens *push.TokenRepo
+	Outbox *outbox.Repo
+}
+
+func NewPushHandler(tokens *push.TokenRepo, repo *outbox.Repo) *PushHandler {
+	return &PushHandler{Tokens: tokens, Outbox: repo}
+}
+
+type pushTokenInput struct {
+	Platform string `json:"platform" binding:"required,oneof=ios android web"`
+	Token    string `json:"token" binding:"required"`
+}
+
+func (h *PushHandler) available(c *gin.Context) bool {
+	if h.Tokens == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Push notifications are not available"})
+		return false
+	}
+	return true
+}
+
+func (h *PushHandler) RegisterToken(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	userID := c.GetUint("userID")
+
+	var input pushTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid := strconv.FormatUint(uint64(userID), 10)
+	if err := h.Tokens.Upsert(c.Request.Context(), uid, input.Platform, input.Token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register push token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Push token registered"})
+}
+
+func (h *PushHandler) UnregisterToken(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	userID := c.GetUint("userID")
+
+	var input pushTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid := strconv.FormatUint(uint64(userID), 10)
+	removed, err := h.Tokens.DeleteForUser(c.Request.Context(), uid, input.Platform, input.Token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unregister push token"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Push token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Push token unregistered"})
+}
+
+// SendPush queues a PUSH_SEND for all devices of a user (admin).
+func (h *PushHandler) SendPush(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	var input struct {
+		UserID uint              `json:"user_id" binding:"required"`
+		Title  string            `json:"title" binding:"required"`
+		Body   string            `json:"body"`
+		Data   map[string]string `json:"data"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid := strconv.FormatUint(uint64(input.UserID), 10)
+	payload := outbox.PushSendPayload{UserID: uid, Title: input.Title, Body: input.Body, Data: input.Data}
+	if err := h.Outbox.Enqueue(c.Request.Context(), "user", nil, "PUSH_SEND", payload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue push notification"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"queued": true})
+}
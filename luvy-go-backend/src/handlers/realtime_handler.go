@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/realtime"
+)
+
+type RealtimeHandler struct {
+	Server *realtime.Server
+}
+
+func NewRealtimeHandler(server *realtime.Server) *RealtimeHandler {
+	return &RealtimeHandler{Server: server}
+}
+
+// IssueToken hands out a short-lived token for opening the event stream.
+func (h *RealtimeHandler) IssueToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	token, expires := h.Server.Tokens.Issue(userID)
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expires,
+		"websocket":  "/api/realtime/ws?token=" + token,
+		"sse":        "/api/realtime/events?token=" + token,
+	})
+}
+
+func (h *RealtimeHandler) WebSocket(c *gin.Context) {
+	h.Server.WebSocket().ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *RealtimeHandler) Events(c *gin.Context) {
+	h.Server.ServeSSE(c.Writer, c.Request)
+}
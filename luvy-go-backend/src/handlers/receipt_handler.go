@@ -1,20 +1,50 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"luvy-go-backend/internal/audit"
+	"luvy-go-backend/internal/budgets"
+	"luvy-go-backend/internal/challenges"
+	"luvy-go-backend/internal/gamification"
+	"luvy-go-backend/internal/insights"
+	"luvy-go-backend/internal/leaderboard"
+	"luvy-go-backend/internal/realtime"
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/internal/referrals"
+	"luvy-go-backend/internal/streaks"
 	"luvy-go-backend/src/models"
 )
 
 type ReceiptHandler struct {
-	DB *gorm.DB
+	DB           *gorm.DB
+	Gamification *gamification.Service
+	Challenges   *challenges.Service
+	Referrals    *referrals.Service
+	Leaderboard  *leaderboard.Service
+	Streaks      *streaks.Service
+	Budgets      *budgets.Service
+	Insights     *insights.Service
+	Events       realtime.Emitter
+	Audit        *audit.Log
 }
 
 func NewReceiptHandler(db *gorm.DB) *ReceiptHandler {
-	return &ReceiptHandler{DB: db}
+	return &ReceiptHandler{
+		DB:           db,
+		Gamification: gamification.NewService(db),
+		Challenges:   challenges.NewService(db),
+		Referrals:    referrals.NewService(db),
+		Leaderboard:  leaderboard.NewService(db),
+		Streaks:      streaks.NewService(db),
+		Budgets:      budgets.NewService(db),
+		Insights:     insights.NewService(db),
+	}
 }
 
 func (h *ReceiptHandler) SubmitReceipt(c *gin.Context) {
@@ -33,39 +63,99 @@ func (h *ReceiptHandler) SubmitReceipt(c *gin.Context) {
 		return
 	}
 
-	tokensEarned := input.Amount * 0.1
-
 	receipt := models.Receipt{
-		UserID:       userID,
-		Merchant:     input.Merchant,
-		Category:     input.Category,
-		Amount:       input.Amount,
-		TokensEarned: tokensEarned,
-		ImageURL:     input.ImageURL,
-		Status:       "completed",
-		ReceiptDate:  input.ReceiptDate,
+		UserID:      userID,
+		Merchant:    input.Merchant,
+		Category:    input.Category,
+		Amount:      input.Amount,
+		ImageURL:    input.ImageURL,
+		Status:      "completed",
+		ReceiptDate: input.ReceiptDate,
+		CreatedAt:   time.Now(),
 	}
 
-	if err := h.DB.Create(&receipt).Error; err != nil {
+	var streak streaks.State
+	var progress gamification.Result
+	var completed []models.Challenge
+	var events []userEvent
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if streak, err = h.Streaks.OnReceiptApproved(tx, userID, receipt.CreatedAt); err != nil {
+			return err
+		}
+		receipt.TokensEarned = input.Amount * 0.1 * streak.Multiplier
+
+		if err := tx.Create(&receipt).Error; err != nil {
+			return err
+		}
+
+		if err := ledger.Credit(tx, &models.Transaction{
+			UserID:    userID,
+			ReceiptID: &receipt.ID,
+			Type:      ledger.TypeEarn,
+			Amount:    receipt.TokensEarned,
+		}); err != nil {
+			return err
+		}
+
+		if progress, err = h.Gamification.OnReceiptApproved(tx, &receipt, streak.CurrentDays); err != nil {
+			return err
+		}
+		if completed, err = h.Challenges.OnReceiptApproved(tx, &receipt); err != nil {
+			return err
+		}
+		if _, err = h.Referrals.OnReceiptApproved(tx, &receipt); err != nil {
+			return err
+		}
+		if err := h.Budgets.OnReceiptApproved(tx, &receipt); err != nil {
+			return err
+		}
+		if err := h.Leaderboard.OnReceiptApproved(tx, &receipt); err != nil {
+			return err
+		}
+		if err := h.Insights.OnReceiptApproved(tx, &receipt); err != nil {
+			return err
+		}
+
+		events = []userEvent{
+			{realtime.TypeReceiptApproved, gin.H{"receipt": receipt}},
+			{realtime.TypeTokensEarned, gin.H{"receipt_id": receipt.ID, "amount": receipt.TokensEarned}},
+		}
+		if progress.LeveledUp {
+			events = append(events, userEvent{realtime.TypeLevelUp, gin.H{"old_level": progress.OldLevel, "level": progress.Level}})
+		}
+		if events, err = h.withBalance(tx, userID, events); err != nil {
+			return err
+		}
+		return h.queueEvents(c, tx, userID, events)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create receipt"})
 		return
 	}
 
-	h.DB.Model(&models.User{}).Where("id = ?", userID).
-		Update("luvy_balance", gorm.Expr("luvy_balance + ?", tokensEarned))
+	// receipts are approved automatically on submission
+	h.audit(c, audit.Entry{
+		ActorRole: audit.RoleSystem,
+		Action:    audit.ActionReceiptApprove,
+		Resource:  "receipt:" + strconv.FormatUint(uint64(receipt.ID), 10),
+		Metadata: map[string]any{
+			"user_id":       userID,
+			"merchant":      receipt.Merchant,
+			"amount":        receipt.Amount,
+			"tokens_earned": receipt.TokensEarned,
+		},
+	})
 
-	transaction := models.Transaction{
-		UserID:    userID,
-		ReceiptID: receipt.ID,
-		Type:      "earn",
-		Amount:    tokensEarned,
-	}
-	h.DB.Create(&transaction)
+	h.publishEvents(c, userID, events)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Receipt submitted successfully",
 		"receipt":       receipt,
-		"tokens_earned": tokensEarned,
+		"tokens_earned": receipt.TokensEarned,
+		"streak":        streak,
+		"gamification":  progress,
+		"challenges":    completed,
 	})
 }
 
@@ -127,10 +217,115 @@ func (h *ReceiptHandler) DeleteReceipt(c *gin.Context) {
 		return
 	}
 
-	h.DB.Model(&models.User{}).Where("id = ?", userID).
-		Update("luvy_balance", gorm.Expr("luvy_balance - ?", receipt.TokensEarned))
+	var events []userEvent
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// Ledger entries outlive the receipt: they let go of the foreign key
+		// but keep pointing at it by reference, and a reversal takes the
+		// earnings back off the balance.
+		if err := tx.Model(&models.Transaction{}).Where("receipt_id = ?", receipt.ID).
+			Updates(map[string]interface{}{"receipt_id": nil, "reference_type": "receipt", "reference_id": receipt.ID}).Error; err != nil {
+			return err
+		}
+		if receipt.TokensEarned > 0 {
+			if err := ledger.Debit(tx, &models.Transaction{
+				UserID:        userID,
+				Type:          ledger.TypeReversal,
+				Amount:        receipt.TokensEarned,
+				Description:   "Receipt deleted: " + receipt.Merchant,
+				ReferenceType: "receipt",
+				ReferenceID:   receipt.ID,
+			}); err != nil {
+				return err
+			}
+		}
+		if receipt.Status == "completed" {
+			if err := h.Leaderboard.OnReceiptDeleted(tx, &receipt); err != nil {
+				return err
+			}
+		}
+		if err := h.Insights.OnReceiptDeleted(tx, &receipt); err != nil {
+			return err
+		}
+		if err := tx.Delete(&receipt).Error; err != nil {
+			return err
+		}
 
-	h.DB.Delete(&receipt)
+		var err error
+		if events, err = h.withBalance(tx, userID, nil); err != nil {
+			return err
+		}
+		return h.queueEvents(c, tx, userID, events)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete receipt"})
+		return
+	}
+
+	h.audit(c, audit.Entry{
+		ActorID:   strconv.FormatUint(uint64(userID), 10),
+		ActorRole: audit.RoleCustomer,
+		Action:    audit.ActionReceiptDelete,
+		Resource:  "receipt:" + strconv.FormatUint(uint64(receipt.ID), 10),
+		Metadata: map[string]any{
+			"status":        receipt.Status,
+			"amount":        receipt.Amount,
+			"balance_delta": -receipt.TokensEarned,
+		},
+	})
+	h.publishEvents(c, userID, events)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Receipt deleted successfully"})
-}
\ No newline at end of file
+}
+
+// audit records after the commit; a failed write is logged, not returned.
+func (h *ReceiptHandler) audit(c *gin.Context, e audit.Entry) {
+	if err := h.Audit.Record(c.Request.Context(), e); err != nil {
+		log.Printf("audit %s failed: %v", e.Action, err)
+	}
+}
+
+type userEvent struct {
+	Type string
+	Data interface{}
+}
+
+// withBalance appends the user's balance as of tx, for clients to show.
+func (h *ReceiptHandler) withBalance(tx *gorm.DB, userID uint, events []userEvent) ([]userEvent, error) {
+	if h.Events == nil {
+		return events, nil
+	}
+	var user models.User
+	if err := tx.Select("luvy_balance").Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return nil, err
+	}
+	return append(events, userEvent{realtime.TypeBalanceChanged, gin.H{"balance": user.LuvyBalance}}), nil
+}
+
+// queueEvents writes the events into tx when the emitter is backed by the
+// outbox, so they commit or roll back with the change they describe.
+func (h *ReceiptHandler) queueEvents(c *gin.Context, tx *gorm.DB, userID uint, events []userEvent) error {
+	te, ok := h.Events.(realtime.TxEmitter)
+	if !ok {
+		return nil
+	}
+	for _, ev := range events {
+		if err := te.EmitTx(c.Request.Context(), tx.Statement.ConnPool, userID, ev.Type, ev.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishEvents notifies the user's connected clients after the commit when
+// the emitter could not take the events in the transaction. Delivery
+// problems never fail the request that caused them.
+func (h *ReceiptHandler) publishEvents(c *gin.Context, userID uint, events []userEvent) {
+	if _, queued := h.Events.(realtime.TxEmitter); queued || h.Events == nil {
+		return
+	}
+	for _, ev := range events {
+		if err := h.Events.Emit(c.Request.Context(), userID, ev.Type, ev.Data); err != nil {
+			log.Printf("realtime emit %s for user %d failed: %v", ev.Type, userID, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"luvy-go-backend/internal/ledger"
+	"luvy-go-backend/internal/outbox"
+	"luvy-go-backend/internal/realtime"
+	"luvy-go-backend/src/models"
+)
+
+func TestSubmitFirstReceiptCreditsTheAchievementBonus(t *testing.T) {
+	db := pgDB(t)
+	user := models.User{Name: "Begüm", Email: "begum@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(NewReceiptHandler(db).SubmitReceipt, user.ID, http.MethodPost, "/receipts", "/receipts", map[string]any{
+		"merchant": "Migros", "category": "groceries", "amount": 100, "receipt_date": time.Now(),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("submit: %d %s", w.Code, w.Body)
+	}
+
+	var entries []models.Transaction
+	if err := db.Where("user_id = ?", user.ID).Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Type != ledger.TypeEarn || entries[1].Type != ledger.TypeBonus {
+		t.Fatalf("ledger entries %+v, want the earn and the FIRST_RECEIPT bonus", entries)
+	}
+	if entries[0].ReceiptID == nil || entries[1].ReceiptID != nil {
+		t.Fatalf("receipt ids %v %v, want the earn linked and the bonus unlinked", entries[0].ReceiptID, entries[1].ReceiptID)
+	}
+
+	if err := db.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := entries[0].Amount + entries[1].Amount; user.LuvyBalance != want {
+		t.Fatalf("balance %v, want %v", user.LuvyBalance, want)
+	}
+}
+
+func TestDeleteReceiptReversesTheEarningsInTheLedger(t *testing.T) {
+	db := pgDB(t)
+	user := models.User{Name: "Begüm", Email: "begum@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := NewReceiptHandler(db)
+
+	w := serve(h.SubmitReceipt, user.ID, http.MethodPost, "/receipts", "/receipts", map[string]any{
+		"merchant": "Migros", "category": "groceries", "amount": 100, "receipt_date": time.Now(),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("submit: %d %s", w.Code, w.Body)
+	}
+	var receipt models.Receipt
+	if err := db.Where("user_id = ?", user.ID).First(&receipt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/receipts/" + strconv.FormatUint(uint64(receipt.ID), 10)
+	if w := serve(h.DeleteReceipt, user.ID, http.MethodDelete, path, "/receipts/:id", nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+
+	var left int64
+	db.Model(&models.Receipt{}).Where("id = ?", receipt.ID).Count(&left)
+	if left != 0 {
+		t.Fatal("receipt still there")
+	}
+
+	var earn, reversal models.Transaction
+	if err := db.Where("user_id = ? AND type = ?", user.ID, ledger.TypeEarn).First(&earn).Error; err != nil {
+		t.Fatal(err)
+	}
+	if earn.ReceiptID != nil || earn.ReferenceType != "receipt" || earn.ReferenceID != receipt.ID {
+		t.Fatalf("earn entry %+v, want it kept with a reference to the deleted receipt", earn)
+	}
+	if err := db.Where("user_id = ? AND type = ?", user.ID, ledger.TypeReversal).First(&reversal).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reversal.Amount != -receipt.TokensEarned || reversal.ReferenceID != receipt.ID {
+		t.Fatalf("reversal %+v, want -%v for receipt %d", reversal, receipt.TokensEarned, receipt.ID)
+	}
+
+	// the balance is still the sum of the ledger, which statements rely on
+	var sum float64
+	db.Model(&models.Transaction{}).Where("user_id = ?", user.ID).Select("COALESCE(SUM(amount), 0)").Scan(&sum)
+	if err := db.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.LuvyBalance != sum {
+		t.Fatalf("balance %v, ledger sums to %v", user.LuvyBalance, sum)
+	}
+}
+
+func TestSubmitReceiptQueuesUserEventsInItsTransaction(t *testing.T) {
+	db := pgDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Name: "Begüm", Email: "begum@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := NewReceiptHandler(db)
+	h.Events = realtime.OutboxEmitter{Repo: outbox.NewRepo(sqlDB)}
+
+	w := serve(h.SubmitReceipt, user.ID, http.MethodPost, "/receipts", "/receipts", map[string]any{
+		"merchant": "Migros", "category": "groceries", "amount": 100, "receipt_date": time.Now(),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("submit: %d %s", w.Code, w.Body)
+	}
+
+	rows, err := sqlDB.Query(`
+SELECT aggregate_type, aggregate_id, payload->>'type' FROM outbox_events
+WHERE event_type = $1 ORDER BY created_at, seq`, realtime.OutboxEventType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var types []string
+	for rows.Next() {
+		var aggType, aggID, typ string
+		if err := rows.Scan(&aggType, &aggID, &typ); err != nil {
+			t.Fatal(err)
+		}
+		if aggType != realtime.AggregateUserEvents || aggID != strconv.FormatUint(uint64(user.ID), 10) {
+			t.Fatalf("%s queued under %s/%s, want the user's aggregate", typ, aggType, aggID)
+		}
+		types = append(types, typ)
+	}
+	want := []string{realtime.TypeReceiptApproved, realtime.TypeTokensEarned, realtime.TypeBalanceChanged}
+	if len(types) != len(want) {
+		t.Fatalf("queued %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("queued %v, want %v", types, want)
+		}
+	}
+}
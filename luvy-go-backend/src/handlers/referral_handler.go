@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"luvy-go-backend/internal/referrals"
+)
+
+type ReferralHandler struct {
+	DB      *gorm.DB
+	Service *referrals.Service
+}
+
+func NewReferralHandler(db *gorm.DB) *ReferralHandler {
+	return &ReferralHandler{DB: db, Service: referrals.NewService(db)}
+}
+
+func (h *ReferralHandler) GetCode(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	rc, err := h.Service.CodeFor(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch referral code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": rc.Code})
+}
+
+func (h *ReferralHandler) GetReferrals(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	summary, err := h.Service.Summary(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch referrals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
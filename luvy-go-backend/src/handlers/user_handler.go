@@ -1,19 +1,26 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"luvy-go-backend/internal/account"
+	"luvy-go-backend/internal/gdpr"
+	"luvy-go-backend/internal/notifications/email"
+	"luvy-go-backend/internal/streaks"
 	"luvy-go-backend/src/models"
 )
 
 type UserHandler struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Streaks *streaks.Service
+	Account *account.Service
 }
 
 func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{DB: db}
+	return &UserHandler{DB: db, Streaks: streaks.NewService(db), Account: account.NewService(db)}
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
@@ -25,17 +32,26 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
+	streak, err := h.Streaks.Current(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch streak"})
+		return
+	}
+
 	user.Password = ""
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	c.JSON(http.StatusOK, gin.H{"user": user, "streak": streak})
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID := c.GetUint("userID")
 
 	var input struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
-		Phone string `json:"phone"`
+		Name     string `json:"name"`
+		Email    string `json:"email" binding:"omitempty,email"`
+		Phone    string `json:"phone"`
+		City     string `json:"city"`
+		TimeZone string `json:"time_zone"`
+		Locale   string `json:"locale"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -47,26 +63,126 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	if input.Name != "" {
 		updates["name"] = input.Name
 	}
-	if input.Email != "" {
-		var existing models.User
-		if err := h.DB.Where("email = ? AND id != ?", input.Email, userID).First(&existing).Error; err == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already in use"})
+	if input.Phone != "" {
+		updates["phone"] = input.Phone
+	}
+	if input.City != "" {
+		updates["city"] = input.City
+	}
+	if input.TimeZone != "" {
+		if !streaks.ValidTimeZone(input.TimeZone) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone"})
 			return
 		}
-		updates["email"] = input.Email
+		updates["time_zone"] = input.TimeZone
 	}
-	if input.Phone != "" {
-		updates["phone"] = input.Phone
+	if input.Locale != "" {
+		if !email.SupportedLocale(input.Locale) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Locale must be one of tr, de, en"})
+			return
+		}
+		updates["locale"] = input.Locale
 	}
 
-	if err := h.DB.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+	// Everything is validated by now; the address check and the writes go
+	// in one transaction so a taken address leaves the profile untouched.
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if input.Email != "" {
+			// A new address replaces the current one only once it is verified.
+			if err := h.Account.RequestEmailChangeTx(tx, userID, input.Email); err != nil {
+				return err
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+	})
+	if errors.Is(err, account.ErrEmailTaken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already in use"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
 
+	if input.Email != "" {
+		c.JSON(http.StatusOK, gin.H{"message": "Profile updated; confirm your new email address with the link we sent to it"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
 
+// ResendVerification mails a new link for the pending address.
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	err := h.Account.ResendVerification(c.GetUint("userID"))
+	switch {
+	case errors.Is(err, account.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, account.ErrAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+	}
+}
+
+func (h *UserHandler) Deactivate(c *gin.Context) {
+	if err := h.Account.Deactivate(c.Request.Context(), c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate account"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account deactivated"})
+}
+
+// Reactivate takes the account's email and password, as a deactivated
+// account has no other way in, and answers with a session like Login. It
+// also withdraws a pending deletion.
+func (h *UserHandler) Reactivate(c *gin.Context) {
+	var input struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.Account.Reactivate(c.Request.Context(), input.Email, input.Password)
+	if errors.Is(err, account.ErrBadCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate account"})
+		return
+	}
+	resp := sessionResponse(token, user)
+	resp["message"] = "Account reactivated"
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteAccount deactivates the account and schedules its erasure after the
+// GDPR grace period.
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	req, err := h.Account.Delete(c.Request.Context(), c.GetUint("userID"))
+	switch {
+	case errors.Is(err, account.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Account deletion is not available"})
+	case errors.Is(err, gdpr.ErrAlreadyPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is already scheduled"})
+	case errors.Is(err, gdpr.ErrUnknownUser):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Account scheduled for deletion", "request": req})
+	}
+}
+
 func (h *UserHandler) GetBalance(c *gin.Context) {
 	userID := c.GetUint("userID")
 
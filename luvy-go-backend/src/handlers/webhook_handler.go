@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"luvy-go-backend/internal/webhooks"
+)
+
+// WebhookHandler manages partner webhook subscriptions (admin). Both fields
+// are nil in SQLite mode, where webhooks are not delivered, and the
+// endpoints answer 503.
+type WebhookHandler struct {
+	Repo      *webhooks.Repo
+	Deliverer *webhooks.Deliverer
+}
+
+func NewWebhookHandler(repo *webhooks.Repo, d *webhooks.Deliverer) *WebhookHandler {
+	return &WebhookHandler{Repo: repo, Deliverer: d}
+}
+
+func (h *WebhookHandler) available(c *gin.Context) bool {
+	if h.Repo == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhooks are not available"})
+		return false
+	}
+	return true
+}
+
+type webhookInput struct {
+	URL         string   `json:"url" binding:"required"`
+	EventTypes  []string `json:"event_types" binding:"required"`
+	Merchant    *string  `json:"merchant"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+func (in webhookInput) subscription() webhooks.Subscription {
+	s := webhooks.Subscription{URL: in.URL, EventTypes: in.EventTypes, Merchant: in.Merchant, Description: in.Description, Active: true}
+	if in.Active != nil {
+		s.Active = *in.Active
+	}
+	return s
+}
+
+// respond maps repository errors; it reports whether err was nil.
+func (h *WebhookHandler) respond(c *gin.Context, err error, failure string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, webhooks.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+	case errors.Is(err, webhooks.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+	}
+	return false
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	subs, err := h.Repo.List(c.Request.Context())
+	if !h.respond(c, err, "Failed to fetch webhook subscriptions") {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subs, "event_types": webhooks.EventTypes})
+}
+
+// Create returns the new subscription with its secret. It is not shown again.
+func (h *WebhookHandler) Create(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := h.Repo.Create(c.Request.Context(), input.subscription())
+	if !h.respond(c, err, "Failed to create webhook subscription") {
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+func (h *WebhookHandler) Get(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	sub, err := h.Repo.Get(c.Request.Context(), c.Param("id"))
+	if !h.respond(c, err, "Failed to fetch webhook subscription") {
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s := input.subscription()
+	s.ID = c.Param("id")
+	sub, err := h.Repo.Update(c.Request.Context(), s)
+	if !h.respond(c, err, "Failed to update webhook subscription") {
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	err := h.Repo.Delete(c.Request.Context(), c.Param("id"))
+	if !h.respond(c, err, "Failed to delete webhook subscription") {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook subscription deleted"})
+}
+
+// RotateSecret returns the new secret; the old one keeps signing for
+// webhooks.RotationGrace.
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	sub, err := h.Repo.RotateSecret(c.Request.Context(), c.Param("id"))
+	if !h.respond(c, err, "Failed to rotate webhook secret") {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscription": sub, "previous_secret_valid_for": webhooks.RotationGrace.String()})
+}
+
+// Test sends a webhook.test event synchronously and reports how the
+// receiver answered. A failing receiver is not a failing request.
+func (h *WebhookHandler) Test(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	del, err := h.Deliverer.Test(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, webhooks.ErrNotFound) || (err != nil && del.WebhookEventID == "") {
+		h.respond(c, err, "Failed to send test webhook")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": err == nil, "delivery": del})
+}
+
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	if _, err := h.Repo.Get(c.Request.Context(), c.Param("id")); !h.respond(c, err, "Failed to fetch webhook deliveries") {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	dels, err := h.Repo.Deliveries(c.Request.Context(), c.Param("id"), limit)
+	if !h.respond(c, err, "Failed to fetch webhook deliveries") {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": dels})
+}
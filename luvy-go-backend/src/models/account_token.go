@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// AccountToken is a single-use link sent by email: verifying an address at
+// signup or on change, or resetting a password. Login sessions are tokens
+// too, ended by setting UsedAt. Only the SHA-256 of the token is stored.
+// Email is the address being verified.
+type AccountToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
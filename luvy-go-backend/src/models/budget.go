@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Budget is a user's monthly spending limit for one receipt category.
+// Rollover decides what happens to the difference at the end of a month.
+type Budget struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_budget_category"`
+	Category  string    `json:"category" gorm:"uniqueIndex:idx_budget_category"`
+	Amount    float64   `json:"amount"`
+	Rollover  string    `json:"rollover" gorm:"default:none"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BudgetAlert records that a budget crossed a threshold in a month (YYYY-MM
+// in the user's time zone), so each alert goes out once.
+type BudgetAlert struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	BudgetID  uint      `json:"budget_id" gorm:"uniqueIndex:idx_budget_alert"`
+	Month     string    `json:"month" gorm:"uniqueIndex:idx_budget_alert"`
+	Threshold int       `json:"threshold" gorm:"uniqueIndex:idx_budget_alert"`
+	Spent     float64   `json:"spent"`
+	Budgeted  float64   `json:"budgeted"`
+	CreatedAt time.Time `json:"created_at"`
+}
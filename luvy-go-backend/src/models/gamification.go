@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+type UserLevel struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	UserID            uint       `json:"user_id" gorm:"uniqueIndex"`
+	Level             string     `json:"level" gorm:"default:bronze"`
+	CurrentXP         int        `json:"current_xp" gorm:"default:0"`
+	TotalLuvyEarned   float64    `json:"total_luvy_earned" gorm:"default:0"`
+	ReceiptsSubmitted int        `json:"receipts_submitted" gorm:"default:0"`
+	ConsecutiveDays   int        `json:"consecutive_days" gorm:"default:0"`
+	LastActiveDate    *time.Time `json:"last_active_date"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+type Achievement struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Code             string    `json:"code" gorm:"uniqueIndex"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Category         string    `json:"category"`
+	Icon             string    `json:"icon"`
+	RequirementType  string    `json:"requirement_type"`
+	RequirementValue int       `json:"requirement_value"`
+	RewardLuvy       float64   `json:"reward_luvy"`
+	IsActive         bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type UserAchievement struct {
+	ID            uint        `json:"id" gorm:"primaryKey"`
+	UserID        uint        `json:"user_id" gorm:"uniqueIndex:idx_user_achievement"`
+	AchievementID uint        `json:"achievement_id" gorm:"uniqueIndex:idx_user_achievement"`
+	Achievement   Achievement `json:"achievement" gorm:"foreignKey:AchievementID"`
+	UnlockedAt    time.Time   `json:"unlocked_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type Challenge struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Code        string    `json:"code" gorm:"uniqueIndex"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	GoalType    string    `json:"goal_type"`
+	GoalValue   float64   `json:"goal_value"`
+	Category    string    `json:"category"`
+	Merchant    string    `json:"merchant"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	RewardLuvy  float64   `json:"reward_luvy"`
+	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type UserChallenge struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"uniqueIndex:idx_user_challenge"`
+	ChallengeID uint       `json:"challenge_id" gorm:"uniqueIndex:idx_user_challenge"`
+	Progress    float64    `json:"progress" gorm:"default:0"`
+	Target      float64    `json:"target"`
+	Status      string     `json:"status" gorm:"default:active"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
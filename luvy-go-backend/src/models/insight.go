@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// UserInsight caches a user's computed spending insights as JSON. Every
+// receipt written or deleted bumps ReceiptVersion; the report is out of date
+// while ComputedVersion lags behind it.
+type UserInsight struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	UserID          uint      `json:"user_id" gorm:"uniqueIndex"`
+	Report          string    `json:"-" gorm:"type:text"`
+	ReceiptVersion  int64     `json:"receipt_version" gorm:"not null;default:0"`
+	ComputedVersion int64     `json:"computed_version" gorm:"not null;default:0"`
+	ComputedAt      time.Time `json:"computed_at" gorm:"index"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LeaderboardEntry holds a user's running totals for one leaderboard bucket.
+// Category is empty for the across-all-categories board.
+type LeaderboardEntry struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Period     string    `json:"period" gorm:"uniqueIndex:idx_leaderboard_bucket;index:idx_leaderboard_rank,priority:1"`
+	PeriodKey  string    `json:"period_key" gorm:"uniqueIndex:idx_leaderboard_bucket;index:idx_leaderboard_rank,priority:2"`
+	Category   string    `json:"category" gorm:"uniqueIndex:idx_leaderboard_bucket;index:idx_leaderboard_rank,priority:3"`
+	UserID     uint      `json:"user_id" gorm:"uniqueIndex:idx_leaderboard_bucket"`
+	LuvyEarned float64   `json:"luvy_earned" gorm:"default:0"`
+	Receipts   int       `json:"receipts" gorm:"default:0"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
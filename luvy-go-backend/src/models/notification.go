@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// NotificationPreference overrides the default for one channel/category pair.
+// Pairs without a row use prefs.Default.
+type NotificationPreference struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_notification_pref"`
+	Channel   string    `json:"channel" gorm:"uniqueIndex:idx_notification_pref"`
+	Category  string    `json:"category" gorm:"uniqueIndex:idx_notification_pref"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Notification is one entry in a user's in-app inbox. Rows are written by
+// the outbox worker (INAPP_NOTIFY) and trimmed by inbox retention.
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index:idx_notifications_user,priority:1"`
+	Category  string     `json:"category"`
+	Type      string     `json:"type"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Data      string     `json:"data" gorm:"type:text"` // JSON object
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index:idx_notifications_user,priority:2"`
+}
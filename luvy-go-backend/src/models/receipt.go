@@ -18,14 +18,17 @@ type Receipt struct {
 }
 
 type Transaction struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    uint      `json:"user_id"`
-	// User      User      `json:"user" gorm:"foreignKey:UserID"`
-	ReceiptID uint      `json:"receipt_id"`
-	Receipt   Receipt   `json:"receipt" gorm:"foreignKey:ReceiptID"`
-	Type      string    `json:"type"`
-	Amount    float64   `json:"amount"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UserID        uint      `json:"user_id"`
+	// User          User      `json:"user" gorm:"foreignKey:UserID"`
+	ReceiptID     *uint     `json:"receipt_id"` // nil for entries not tied to a receipt, e.g. bonuses
+	Receipt       Receipt   `json:"receipt" gorm:"foreignKey:ReceiptID"`
+	Type          string    `json:"type"`
+	Amount        float64   `json:"amount"`
+	Description   string    `json:"description"`
+	ReferenceType string    `json:"reference_type"`
+	ReferenceID   uint      `json:"reference_id"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type Merchant struct {
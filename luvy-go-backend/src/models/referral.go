@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type ReferralCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex"`
+	Code      string    `json:"code" gorm:"uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Referral struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	ReferrerID     uint       `json:"referrer_id" gorm:"index"`
+	ReferredUserID uint       `json:"referred_user_id" gorm:"uniqueIndex"`
+	ReferralCode   string     `json:"referral_code" gorm:"index"`
+	Status         string     `json:"status" gorm:"default:pending"`
+	ReferrerReward float64    `json:"referrer_reward"`
+	ReferredReward float64    `json:"referred_reward"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
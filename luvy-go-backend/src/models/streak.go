@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// UserStreak tracks consecutive days and ISO weeks with an approved receipt.
+// Day and week keys are in the user's own time zone.
+type UserStreak struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"uniqueIndex"`
+	CurrentDays  int       `json:"current_days" gorm:"default:0"`
+	LongestDays  int       `json:"longest_days" gorm:"default:0"`
+	LastDay      string    `json:"last_day"`
+	CurrentWeeks int       `json:"current_weeks" gorm:"default:0"`
+	LongestWeeks int       `json:"longest_weeks" gorm:"default:0"`
+	LastWeek     string    `json:"last_week"`
+	FreezeMonth  string    `json:"freeze_month"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
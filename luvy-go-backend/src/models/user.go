@@ -5,12 +5,22 @@ import "time"
 // Minimal User model to satisfy handlers.
 // You can expand fields later to match your real DB schema.
 type User struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name"`
-	Email       string    `json:"email" gorm:"uniqueIndex"`
-	Phone       string    `json:"phone"`
-	Password    string    `json:"-"` // do not expose
-	LuvyBalance float64   `json:"luvy_balance" gorm:"default:0"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	Name              string     `json:"name"`
+	Email             string     `json:"email" gorm:"uniqueIndex"`
+	Phone             string     `json:"phone"`
+	City              string     `json:"city"`
+	TimeZone          string     `json:"time_zone" gorm:"default:Europe/Berlin"`
+	Locale            string     `json:"locale" gorm:"default:tr"`
+	Password          string     `json:"-"` // do not expose
+	SignupDeviceID    string     `json:"-"`
+	LeaderboardOptOut bool       `json:"leaderboard_opt_out" gorm:"default:false"`
+	QuietHoursStart   string     `json:"quiet_hours_start"` // HH:MM in TimeZone, empty = none
+	QuietHoursEnd     string     `json:"quiet_hours_end"`
+	LuvyBalance       float64    `json:"luvy_balance" gorm:"default:0"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+	DeactivatedAt     *time.Time `json:"deactivated_at,omitempty"`
+	ErasedAt          *time.Time `json:"erased_at,omitempty"` // set when a GDPR erasure anonymised the account
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 }